@@ -0,0 +1,110 @@
+package icanal
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+const (
+	MaxPendingDefault = 16 // 默认最多未确认的批次数
+)
+
+// AckTracker 异步确认跟踪器；批次可以乱序完成，按拉取顺序确认最长的连续完成前缀
+type AckTracker struct {
+	connector Connector
+	mutex     sync.Mutex
+	pending   []int64 // 未确认的批次，按拉取顺序
+	completed map[int64]bool
+	slots     chan struct{}
+}
+
+// NewAckTracker 新建确认跟踪器；maxPending限制未确认的批次数，小于1时使用默认值
+func NewAckTracker(connector Connector, maxPending int) *AckTracker {
+	if maxPending < 1 {
+		maxPending = MaxPendingDefault
+	}
+	return &AckTracker{
+		connector: connector,
+		completed: make(map[int64]bool),
+		slots:     make(chan struct{}, maxPending),
+	}
+}
+
+// Track 登记GetWithoutAck拿到的批次；需按拉取顺序调用，未确认批次达到上限时阻塞
+func (t *AckTracker) Track(ctx context.Context, batchId int64) error {
+	if batchId == -1 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case t.slots <- struct{}{}:
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.pending = append(t.pending, batchId)
+	t.completed[batchId] = false
+
+	return nil
+}
+
+// Complete 标记批次完成，并确认从最早批次开始连续完成的部分
+func (t *AckTracker) Complete(ctx context.Context, batchId int64) error {
+	if batchId == -1 {
+		return nil
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if _, ok := t.completed[batchId]; !ok {
+		return ErrBatchNotTracked
+	}
+	t.completed[batchId] = true
+
+	for len(t.pending) > 0 && t.completed[t.pending[0]] {
+		head := t.pending[0]
+		if err := t.connector.Ack(ctx, head); err != nil {
+			return err
+		}
+		t.pending = t.pending[1:]
+		delete(t.completed, head)
+		<-t.slots
+	}
+
+	return nil
+}
+
+// Fail 标记批次失败；canal回滚会作废所有未确认的批次，因此清空全部跟踪状态
+func (t *AckTracker) Fail(ctx context.Context, batchId int64) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if _, ok := t.completed[batchId]; !ok {
+		return ErrBatchNotTracked
+	}
+
+	if err := t.connector.Rollback(ctx, 0); err != nil {
+		return err
+	}
+
+	for range t.pending {
+		<-t.slots
+	}
+	t.pending = nil
+	clear(t.completed)
+
+	return nil
+}
+
+// Pending 未确认的批次，按拉取顺序
+func (t *AckTracker) Pending() []int64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return slices.Clone(t.pending)
+}
@@ -0,0 +1,98 @@
+package icanal
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordConnector 记录确认和回滚的连接器
+type recordConnector struct {
+	Connector
+	mutex     sync.Mutex
+	acks      []int64
+	rollbacks []int64
+}
+
+func (c *recordConnector) Ack(_ context.Context, batchId int64) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.acks = append(c.acks, batchId)
+	return nil
+}
+
+func (c *recordConnector) Rollback(_ context.Context, batchId int64) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.rollbacks = append(c.rollbacks, batchId)
+	return nil
+}
+
+func TestAckTracker_Complete(t *testing.T) {
+	ctx := context.Background()
+	connector := &recordConnector{}
+	tracker := NewAckTracker(connector, 4)
+
+	for _, id := range []int64{1, 2, 3, 4} {
+		if err := tracker.Track(ctx, id); err != nil {
+			t.Fatalf("Track() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		complete int64
+		acks     []int64
+	}{
+		{complete: 3, acks: nil},
+		{complete: 2, acks: nil},
+		{complete: 1, acks: []int64{1, 2, 3}},
+		{complete: 4, acks: []int64{1, 2, 3, 4}},
+	}
+	for _, tt := range tests {
+		if err := tracker.Complete(ctx, tt.complete); err != nil {
+			t.Fatalf("Complete(%d) error = %v", tt.complete, err)
+		}
+		if !reflect.DeepEqual(connector.acks, tt.acks) {
+			t.Errorf("Complete(%d) acks = %v, want %v", tt.complete, connector.acks, tt.acks)
+		}
+	}
+
+	if err := tracker.Complete(ctx, 4); !errors.Is(err, ErrBatchNotTracked) {
+		t.Errorf("Complete() error = %v, want %v", err, ErrBatchNotTracked)
+	}
+}
+
+func TestAckTracker_Fail(t *testing.T) {
+	ctx := context.Background()
+	connector := &recordConnector{}
+	tracker := NewAckTracker(connector, 2)
+
+	_ = tracker.Track(ctx, 1)
+	_ = tracker.Track(ctx, 2)
+
+	// 达到上限时阻塞
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := tracker.Track(timeoutCtx, 3); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Track() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	if err := tracker.Fail(ctx, 2); err != nil {
+		t.Fatalf("Fail() error = %v", err)
+	}
+	if !reflect.DeepEqual(connector.rollbacks, []int64{0}) {
+		t.Errorf("Fail() rollbacks = %v, want [0]", connector.rollbacks)
+	}
+	if pending := tracker.Pending(); len(pending) != 0 {
+		t.Errorf("Pending() = %v, want empty", pending)
+	}
+	if err := tracker.Complete(ctx, 1); !errors.Is(err, ErrBatchNotTracked) {
+		t.Errorf("Complete() error = %v, want %v", err, ErrBatchNotTracked)
+	}
+	if err := tracker.Track(ctx, 3); err != nil {
+		t.Errorf("Track() error = %v", err)
+	}
+}
@@ -0,0 +1,463 @@
+package icanal
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	AvroNamespaceDefault = "icanal" // 默认avro命名空间
+)
+
+// AvroRecord avro编码后的变更记录
+type AvroRecord struct {
+	Subject string // schema注册的subject，"schema.table-value"
+	Version int    // schema版本
+	Schema  string // 写入时使用的schema
+	Data    []byte // avro二进制数据
+}
+
+// avroSchema avro record schema
+type avroSchema struct {
+	Type      string      `json:"type"`
+	Name      string      `json:"name"`
+	Namespace string      `json:"namespace,omitempty"`
+	Fields    []avroField `json:"fields"`
+}
+
+type avroField struct {
+	Name    string          `json:"name"`
+	Type    any             `json:"type"`
+	Default json.RawMessage `json:"default,omitempty"`
+}
+
+// avroColumn 行record中的一列
+type avroColumn struct {
+	name   string // avro字段名
+	column string // 原始列名
+	typ    string // avro基本类型
+}
+
+// avroTable 表当前的schema
+type avroTable struct {
+	version int
+	schema  string
+	columns []avroColumn
+}
+
+// AvroEncoder avro编码器；按schema.table从列元数据生成schema并注册，列变化时生成新版本
+//
+// 每条记录是一个信封：before/after为可空的行record，op为c/u/d，position为binlog位点。
+// 行中所有字段都是["null", T]且默认null，因此增删列都前后兼容；列类型变化只允许int->long、float->double提升。
+// 首次编码一张表时以注册中心的最新版本为基准检查兼容性；列名转换为avro名称后冲突时返回ErrDuplicateField。
+type AvroEncoder struct {
+	registry  SchemaRegistry
+	namespace string
+	mutex     sync.Mutex
+	tables    map[string]*avroTable
+}
+
+type AvroOption func(*AvroEncoder)
+
+// WithAvroNamespace 设置avro命名空间
+func WithAvroNamespace(namespace string) AvroOption {
+	return func(e *AvroEncoder) {
+		e.namespace = namespace
+	}
+}
+
+// NewAvroEncoder 新建avro编码器
+func NewAvroEncoder(registry SchemaRegistry, opts ...AvroOption) *AvroEncoder {
+	e := &AvroEncoder{
+		registry:  registry,
+		namespace: AvroNamespaceDefault,
+		tables:    make(map[string]*avroTable),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Schema 表当前的schema和版本；未编码过该表时返回false
+func (e *AvroEncoder) Schema(schema, table string) (string, int, bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	t, ok := e.tables[schema+"."+table]
+	if !ok {
+		return "", 0, false
+	}
+	return t.schema, t.version, true
+}
+
+// Encode 编码一个变更事件；DDL和INSERT/UPDATE/DELETE以外的事件返回nil
+func (e *AvroEncoder) Encode(event ChangeEvent) (*AvroRecord, error) {
+	var op string
+	switch {
+	case event.IsDdl:
+		return nil, nil
+	case event.EventType == EventType_INSERT:
+		op = DebeziumOpCreate
+	case event.EventType == EventType_UPDATE:
+		op = DebeziumOpUpdate
+	case event.EventType == EventType_DELETE:
+		op = DebeziumOpDelete
+	default:
+		return nil, nil
+	}
+
+	subject := event.SchemaName() + "." + event.TableName() + "-value"
+	table, err := e.table(event, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	for _, columns := range [][]*Column{event.RowData.GetBeforeColumns(), event.RowData.GetAfterColumns()} {
+		if len(columns) == 0 {
+			data = appendAvroLong(data, 0) // null
+			continue
+		}
+		data = appendAvroLong(data, 1)
+		if data, err = appendAvroRow(data, table.columns, columns); err != nil {
+			return nil, err
+		}
+	}
+
+	header := event.Header
+	data = appendAvroString(data, op)
+	data = appendAvroString(data, header.GetLogfileName())
+	data = appendAvroLong(data, header.GetLogfileOffset())
+	if gtid := header.GetGtid(); gtid == "" {
+		data = appendAvroLong(data, 0)
+	} else {
+		data = appendAvroLong(data, 1)
+		data = appendAvroString(data, gtid)
+	}
+	data = appendAvroLong(data, header.GetServerId())
+	data = appendAvroLong(data, header.GetExecuteTime())
+
+	return &AvroRecord{
+		Subject: subject,
+		Version: table.version,
+		Schema:  table.schema,
+		Data:    data,
+	}, nil
+}
+
+// table 获取表的schema；列有变化时检查兼容性并注册新版本
+func (e *AvroEncoder) table(event ChangeEvent, subject string) (*avroTable, error) {
+	columns := event.RowData.GetAfterColumns()
+	if len(columns) == 0 {
+		columns = event.RowData.GetBeforeColumns()
+	}
+
+	avroColumns := make([]avroColumn, 0, len(columns))
+	names := make(map[string]string, len(columns))
+	for _, column := range columns {
+		name := avroName(column.GetName())
+		if other, ok := names[name]; ok {
+			return nil, errors.Join(ErrDuplicateField,
+				fmt.Errorf("columns %s and %s are both named %s in avro", other, column.GetName(), name))
+		}
+		names[name] = column.GetName()
+		avroColumns = append(avroColumns, avroColumn{
+			name:   name,
+			column: column.GetName(),
+			typ:    avroType(parseMysqlType(column.GetMysqlType())),
+		})
+	}
+
+	key := event.SchemaName() + "." + event.TableName()
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	old := e.tables[key]
+	if old == nil {
+		// 重启后以注册中心的最新版本为基准检查兼容性
+		latest, err := e.latest(subject)
+		if err != nil {
+			return nil, err
+		}
+		if latest != nil && equalAvroColumns(latest.columns, avroColumns) {
+			latest.columns = avroColumns
+			e.tables[key] = latest
+			return latest, nil
+		}
+		old = latest
+	}
+	if old != nil && equalAvroColumns(old.columns, avroColumns) {
+		return old, nil
+	}
+	if old != nil {
+		if err := checkAvroCompatible(old.columns, avroColumns); err != nil {
+			return nil, err
+		}
+	}
+
+	schema, err := e.schema(event.SchemaName(), event.TableName(), avroColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := e.registry.Register(subject, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	table := &avroTable{
+		version: version,
+		schema:  schema,
+		columns: avroColumns,
+	}
+	e.tables[key] = table
+
+	return table, nil
+}
+
+// latest 注册中心中subject的最新版本；没有注册过时返回nil
+func (e *AvroEncoder) latest(subject string) (*avroTable, error) {
+	schema, version, err := e.registry.Latest(subject)
+	if errors.Is(err, ErrSchemaNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns, err := parseAvroColumns(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	return &avroTable{
+		version: version,
+		schema:  schema,
+		columns: columns,
+	}, nil
+}
+
+// parseAvroColumns 从信封schema中解析行的列；原始列名未知，使用avro字段名
+func parseAvroColumns(schema string) ([]avroColumn, error) {
+	var envelope struct {
+		Fields []struct {
+			Name string          `json:"name"`
+			Type json.RawMessage `json:"type"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal([]byte(schema), &envelope); err != nil {
+		return nil, errors.Join(ErrIncompatibleSchema, err)
+	}
+
+	// before为["null", 行record]
+	var before []json.RawMessage
+	if len(envelope.Fields) == 0 || envelope.Fields[0].Name != "before" ||
+		json.Unmarshal(envelope.Fields[0].Type, &before) != nil || len(before) != 2 {
+		return nil, errors.Join(ErrIncompatibleSchema, fmt.Errorf("unexpected schema: %s", schema))
+	}
+	var row struct {
+		Fields []struct {
+			Name string   `json:"name"`
+			Type []string `json:"type"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal(before[1], &row); err != nil {
+		return nil, errors.Join(ErrIncompatibleSchema, err)
+	}
+
+	columns := make([]avroColumn, 0, len(row.Fields))
+	for _, field := range row.Fields {
+		if len(field.Type) != 2 {
+			return nil, errors.Join(ErrIncompatibleSchema, fmt.Errorf("unexpected field: %s", field.Name))
+		}
+		columns = append(columns, avroColumn{name: field.Name, column: field.Name, typ: field.Type[1]})
+	}
+
+	return columns, nil
+}
+
+// schema 生成信封schema
+func (e *AvroEncoder) schema(schemaName, tableName string, columns []avroColumn) (string, error) {
+	name := avroName(schemaName + "_" + tableName)
+	null := json.RawMessage("null")
+
+	fields := make([]avroField, 0, len(columns))
+	for _, column := range columns {
+		fields = append(fields, avroField{Name: column.name, Type: []string{"null", column.typ}, Default: null})
+	}
+	row := avroSchema{Type: "record", Name: name, Namespace: e.namespace, Fields: fields}
+
+	envelope := avroSchema{
+		Type:      "record",
+		Name:      name + "_envelope",
+		Namespace: e.namespace,
+		Fields: []avroField{
+			{Name: "before", Type: []any{"null", row}, Default: null},
+			{Name: "after", Type: []any{"null", name}, Default: null},
+			{Name: "op", Type: "string"},
+			{Name: "position", Type: avroSchema{
+				Type: "record",
+				Name: "position",
+				Fields: []avroField{
+					{Name: "file", Type: "string"},
+					{Name: "offset", Type: "long"},
+					{Name: "gtid", Type: []string{"null", "string"}, Default: null},
+					{Name: "server_id", Type: "long"},
+					{Name: "ts_ms", Type: "long"},
+				},
+			}},
+		},
+	}
+
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// avroType mysql类型对应的avro基本类型
+func avroType(t mysqlType) string {
+	switch t.base {
+	case "tinyint", "smallint", "mediumint", "year":
+		return "int"
+	case "int", "integer":
+		if t.unsigned {
+			return "long"
+		}
+		return "int"
+	case "bigint":
+		if t.unsigned {
+			return "string" // 超过long的范围
+		}
+		return "long"
+	case "float":
+		return "float"
+	case "double", "real":
+		return "double"
+	}
+
+	if t.isBinary() {
+		return "bytes"
+	}
+	return "string"
+}
+
+// avroName 把名称转换为合法的avro名称
+func avroName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// equalAvroColumns 生成的schema是否相同；avro字段名不会冲突，不比较原始列名
+func equalAvroColumns(a, b []avroColumn) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].name != b[i].name || a[i].typ != b[i].typ {
+			return false
+		}
+	}
+	return true
+}
+
+// checkAvroCompatible 检查新schema能否读取旧数据；增删列总是兼容，类型只允许提升
+func checkAvroCompatible(old, new []avroColumn) error {
+	types := make(map[string]string, len(new))
+	for _, column := range new {
+		types[column.name] = column.typ
+	}
+
+	for _, column := range old {
+		typ, ok := types[column.name]
+		if !ok || typ == column.typ {
+			continue
+		}
+		if column.typ == "int" && typ == "long" || column.typ == "float" && typ == "double" {
+			continue
+		}
+		return errors.Join(ErrIncompatibleSchema,
+			fmt.Errorf("column %s changed from %s to %s", column.column, column.typ, typ))
+	}
+
+	return nil
+}
+
+// appendAvroRow 按schema的列顺序编码一行；缺少的列编码为null
+func appendAvroRow(data []byte, avroColumns []avroColumn, columns []*Column) ([]byte, error) {
+	values := make(map[string]*Column, len(columns))
+	for _, column := range columns {
+		values[column.GetName()] = column
+	}
+
+	for _, avroColumn := range avroColumns {
+		column, ok := values[avroColumn.column]
+		if !ok || column.GetIsNull() {
+			data = appendAvroLong(data, 0)
+			continue
+		}
+
+		data = appendAvroLong(data, 1)
+		value := column.GetValue()
+		switch avroColumn.typ {
+		case "int", "long":
+			v, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", avroColumn.column, err)
+			}
+			data = appendAvroLong(data, v)
+		case "float":
+			v, err := strconv.ParseFloat(value, 32)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", avroColumn.column, err)
+			}
+			data = binary.LittleEndian.AppendUint32(data, math.Float32bits(float32(v)))
+		case "double":
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", avroColumn.column, err)
+			}
+			data = binary.LittleEndian.AppendUint64(data, math.Float64bits(v))
+		case "bytes":
+			raw := latin1Bytes(value)
+			data = appendAvroLong(data, int64(len(raw)))
+			data = append(data, raw...)
+		default:
+			data = appendAvroString(data, value)
+		}
+	}
+
+	return data, nil
+}
+
+// appendAvroLong zigzag变长编码，int和long相同
+func appendAvroLong(data []byte, v int64) []byte {
+	return binary.AppendUvarint(data, uint64((v<<1)^(v>>63)))
+}
+
+func appendAvroString(data []byte, s string) []byte {
+	data = appendAvroLong(data, int64(len(s)))
+	return append(data, s...)
+}
@@ -0,0 +1,261 @@
+package icanal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func Test_appendAvroLong(t *testing.T) {
+	tests := []struct {
+		v    int64
+		want []byte
+	}{
+		{v: 0, want: []byte{0x00}},
+		{v: -1, want: []byte{0x01}},
+		{v: 1, want: []byte{0x02}},
+		{v: -64, want: []byte{0x7f}},
+		{v: 64, want: []byte{0x80, 0x01}},
+	}
+	for _, tt := range tests {
+		if got := appendAvroLong(nil, tt.v); !bytes.Equal(got, tt.want) {
+			t.Errorf("appendAvroLong(%d) = %x, want %x", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestAvroEncoder_Encode(t *testing.T) {
+	registry, err := NewFileSchemaRegistry(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoder := NewAvroEncoder(registry)
+
+	event := func(columns ...*Column) ChangeEvent {
+		return ChangeEvent{
+			Header:    newTestHeader(EventType_INSERT),
+			EventType: EventType_INSERT,
+			RowData:   &RowData{AfterColumns: columns},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		event       ChangeEvent
+		wantVersion int
+		wantErr     error
+	}{
+		{
+			name:        "first",
+			event:       event(newTestColumn(0, "id", true, "1", true), newTestColumn(1, "name", false, "alice", true)),
+			wantVersion: 1,
+		},
+		{
+			name:        "same columns",
+			event:       event(newTestColumn(0, "id", true, "2", true), newTestColumn(1, "name", false, "NULL", true)),
+			wantVersion: 1,
+		},
+		{
+			name: "add column",
+			event: event(newTestColumn(0, "id", true, "3", true), newTestColumn(1, "name", false, "bob", true),
+				newTestColumn(2, "age", false, "20", true)),
+			wantVersion: 2,
+		},
+		{
+			name: "incompatible type",
+			event: event(newTestColumn(0, "id", true, "4", true), newTestColumn(1, "name", false, "bob", true),
+				&Column{Index: 2, Name: "age", MysqlType: "varchar(10)", Value: "20"}),
+			wantErr: ErrIncompatibleSchema,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, err := encoder.Encode(tt.event)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Encode() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if record.Version != tt.wantVersion {
+				t.Errorf("Encode() version = %d, want %d", record.Version, tt.wantVersion)
+			}
+			schema, version, err := registry.Latest(record.Subject)
+			if err != nil || schema != record.Schema || version != tt.wantVersion {
+				t.Errorf("Latest() = %d, %v, want %d", version, err, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestAvroEncoder_Restart(t *testing.T) {
+	registry, err := NewFileSchemaRegistry(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := ChangeEvent{
+		Header:    newTestHeader(EventType_INSERT),
+		EventType: EventType_INSERT,
+		RowData: &RowData{AfterColumns: []*Column{
+			newTestColumn(0, "id", true, "1", true),
+			newTestColumn(1, "age", false, "20", true),
+		}},
+	}
+	if _, err = NewAvroEncoder(registry).Encode(event); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	// 重启后沿用已注册的版本
+	record, err := NewAvroEncoder(registry).Encode(event)
+	if err != nil || record.Version != 1 {
+		t.Fatalf("Encode() after restart = %v, %v, want version 1", record, err)
+	}
+
+	// 重启后的第一次注册同样检查兼容性
+	event.RowData.AfterColumns[1] = &Column{Index: 1, Name: "age", MysqlType: "varchar(10)", Value: "20"}
+	if _, err = NewAvroEncoder(registry).Encode(event); !errors.Is(err, ErrIncompatibleSchema) {
+		t.Errorf("Encode() after restart error = %v, want %v", err, ErrIncompatibleSchema)
+	}
+}
+
+func TestAvroEncoder_DuplicateField(t *testing.T) {
+	registry, err := NewFileSchemaRegistry(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewAvroEncoder(registry).Encode(ChangeEvent{
+		Header:    newTestHeader(EventType_INSERT),
+		EventType: EventType_INSERT,
+		RowData: &RowData{AfterColumns: []*Column{
+			{Name: "user-name", MysqlType: "varchar(10)", Value: "a"},
+			{Name: "user_name", MysqlType: "varchar(10)", Value: "b"},
+		}},
+	})
+	if !errors.Is(err, ErrDuplicateField) {
+		t.Errorf("Encode() error = %v, want %v", err, ErrDuplicateField)
+	}
+}
+
+// avroDecoder 按schema解码avro二进制，用于校验编码结果
+type avroDecoder struct {
+	data  []byte
+	named map[string]any
+}
+
+func (d *avroDecoder) long() int64 {
+	v, n := binary.Uvarint(d.data)
+	d.data = d.data[n:]
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (d *avroDecoder) bytes() []byte {
+	n := d.long()
+	v := d.data[:n]
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *avroDecoder) decode(schema any) any {
+	switch s := schema.(type) {
+	case string:
+		switch s {
+		case "null":
+			return nil
+		case "int", "long":
+			return d.long()
+		case "float":
+			v := math.Float32frombits(binary.LittleEndian.Uint32(d.data))
+			d.data = d.data[4:]
+			return v
+		case "double":
+			v := math.Float64frombits(binary.LittleEndian.Uint64(d.data))
+			d.data = d.data[8:]
+			return v
+		case "string":
+			return string(d.bytes())
+		case "bytes":
+			return d.bytes()
+		default:
+			return d.decode(d.named[s])
+		}
+	case []any:
+		return d.decode(s[d.long()])
+	case map[string]any:
+		d.named[s["name"].(string)] = s
+		record := make(map[string]any)
+		for _, field := range s["fields"].([]any) {
+			field := field.(map[string]any)
+			record[field["name"].(string)] = d.decode(field["type"])
+		}
+		return record
+	}
+	panic("unknown schema")
+}
+
+func TestAvroEncoder_RoundTrip(t *testing.T) {
+	registry, err := NewFileSchemaRegistry(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := newTestHeader(EventType_UPDATE)
+	header.Gtid = ""
+	record, err := NewAvroEncoder(registry).Encode(ChangeEvent{
+		Header:    header,
+		EventType: EventType_UPDATE,
+		RowData: &RowData{
+			BeforeColumns: []*Column{
+				{Name: "id", IsKey: true, MysqlType: "bigint(20)", Value: "-9223372036854775808"},
+				{Name: "price", MysqlType: "decimal(10,2)", IsNullPresent: &Column_IsNull{IsNull: true}},
+				{Name: "score", MysqlType: "double", Value: "1.5"},
+				{Name: "ratio", MysqlType: "float", Value: "0.25"},
+				{Name: "data", MysqlType: "blob", Value: "ÿ\u0000"},
+				{Name: "count", MysqlType: "int(10) unsigned", Value: "4294967295"},
+			},
+			AfterColumns: []*Column{
+				{Name: "id", IsKey: true, MysqlType: "bigint(20)", Value: "9223372036854775807"},
+				{Name: "price", MysqlType: "decimal(10,2)", Value: "12345678.90"},
+				{Name: "score", MysqlType: "double", IsNullPresent: &Column_IsNull{IsNull: true}},
+				{Name: "ratio", MysqlType: "float", Value: "-2"},
+				{Name: "data", MysqlType: "blob", Value: ""},
+				{Name: "count", MysqlType: "int(10) unsigned", Value: "0"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	var schema any
+	if err = json.Unmarshal([]byte(record.Schema), &schema); err != nil {
+		t.Fatalf("schema is not valid json: %v", err)
+	}
+	decoder := &avroDecoder{data: record.Data, named: make(map[string]any)}
+	got := decoder.decode(schema)
+	if len(decoder.data) != 0 {
+		t.Errorf("%d bytes left after decoding", len(decoder.data))
+	}
+
+	want := map[string]any{
+		"before": map[string]any{
+			"id": int64(math.MinInt64), "price": nil, "score": 1.5, "ratio": float32(0.25),
+			"data": []byte{0xff, 0x00}, "count": int64(4294967295),
+		},
+		"after": map[string]any{
+			"id": int64(math.MaxInt64), "price": "12345678.90", "score": nil, "ratio": float32(-2),
+			"data": []byte{}, "count": int64(0),
+		},
+		"op": "u",
+		"position": map[string]any{
+			"file": "mysql-bin.000001", "offset": int64(1024), "gtid": nil,
+			"server_id": int64(1), "ts_ms": int64(1589373515000),
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decoded = %v, want %v", got, want)
+	}
+}
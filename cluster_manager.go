@@ -3,10 +3,13 @@ package icanal
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"math/rand/v2"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-zookeeper/zk"
@@ -16,34 +19,71 @@ import (
 type ClusterManager interface {
 	Init(ctx context.Context) error
 	GetNode(ctx context.Context) (string, error)
-	GetLock(ctx context.Context) error // 获取锁
+	GetLock(ctx context.Context) error     // 获取锁
+	ReleaseLock(ctx context.Context) error // 释放锁
 }
 
 type clusterManager struct {
 	destination    string
 	zkServer       []string
 	sessionTimeout time.Duration
-	zkConn         *zk.Conn
+	zkConn         zkClient
 	clusterAddress []string
 	init           bool
 	lockSequence   string
+	logger         *slog.Logger
+	connMutex      sync.RWMutex
+	conn           zkClient     // 供健康检查并发读取
+	lockSession    atomic.Int64 // 获取锁时的会话；会话失效后锁节点被删除，会话随之改变
+}
+
+type ClusterManagerOption func(*clusterManager)
+
+// WithClusterManagerLogger 设置日志，同时用于zookeeper连接
+func WithClusterManagerLogger(logger *slog.Logger) ClusterManagerOption {
+	return func(m *clusterManager) {
+		m.logger = logger
+	}
+}
+
+// NewSharedClusterNodeManager 基于已有的zookeeper连接新建集群节点经理；连接由调用方负责关闭
+func NewSharedClusterNodeManager(destination string, zkConn *zk.Conn, opts ...ClusterManagerOption) ClusterManager {
+	return newSharedClusterNodeManager(destination, zkConn, opts...)
+}
+
+func newSharedClusterNodeManager(destination string, zkConn zkClient, opts ...ClusterManagerOption) *clusterManager {
+	return newClusterNodeManager(&clusterManager{
+		destination: destination,
+		zkConn:      zkConn,
+	}, opts)
 }
 
 // NewClusterNodeManager 新建集群节点经理
-func NewClusterNodeManager(destination string, zkServer []string, sessionTimeout time.Duration) ClusterManager {
-	return &clusterManager{
+func NewClusterNodeManager(destination string, zkServer []string, sessionTimeout time.Duration, opts ...ClusterManagerOption) ClusterManager {
+	return newClusterNodeManager(&clusterManager{
 		destination:    destination,
 		zkServer:       zkServer,
 		sessionTimeout: sessionTimeout,
+	}, opts)
+}
+
+func newClusterNodeManager(m *clusterManager, opts []ClusterManagerOption) *clusterManager {
+	for _, opt := range opts {
+		opt(m)
 	}
+	m.logger = loggerOrDefault(m.logger).With(slog.String(LogKeyDestination, m.destination))
+	m.conn = m.zkConn
+	return m
 }
 
 func (m *clusterManager) Init(ctx context.Context) error {
 	if m.init {
 		return nil
 	}
-	if err := m.connectZookeeper(ctx); err != nil {
-		return err
+	if m.zkConn == nil {
+		if err := m.connectZookeeper(ctx); err != nil {
+			return err
+		}
 	}
 
 	if err := m.getClustersAndInit(ctx); err != nil {
@@ -61,9 +101,9 @@ func (m *clusterManager) Init(ctx context.Context) error {
 }
 
 func (m *clusterManager) connectZookeeper(ctx context.Context) error {
-	zkConn, _, err := zk.Connect(m.zkServer, m.sessionTimeout)
+	zkConn, _, err := zk.Connect(m.zkServer, m.sessionTimeout, zk.WithLogger(zkLogger{m.logger}))
 	if err != nil {
-		slog.ErrorContext(ctx, "connect zookeeper error",
+		m.logger.ErrorContext(ctx, "connect zookeeper error",
 			slog.Any("error", err),
 			slog.Any("zkServer", m.zkServer),
 		)
@@ -71,6 +111,9 @@ func (m *clusterManager) connectZookeeper(ctx context.Context) error {
 	}
 
 	m.zkConn = zkConn
+	m.connMutex.Lock()
+	m.conn = zkConn
+	m.connMutex.Unlock()
 
 	return nil
 }
@@ -78,7 +121,7 @@ func (m *clusterManager) connectZookeeper(ctx context.Context) error {
 func (m *clusterManager) getClustersAndInit(ctx context.Context) error {
 	cluster, _, _, err := m.zkConn.ChildrenW(getDestinationCluster(m.destination))
 	if err != nil {
-		slog.ErrorContext(ctx, "zookeeper get children error",
+		m.logger.ErrorContext(ctx, "zookeeper get children error",
 			slog.Any("error", err))
 		return err
 	}
@@ -114,13 +157,13 @@ func (m *clusterManager) getRunningServerData(ctx context.Context) (*serverRunni
 
 	body, _, err := m.zkConn.Get(getDestinationServerRunning(m.destination))
 	if err != nil {
-		slog.WarnContext(ctx, "get server running data error", slog.Any("error", err))
+		m.logger.WarnContext(ctx, "get server running data error", slog.Any("error", err))
 		return nil, err
 	}
 
 	serverInfo := serverRunningData{}
 	if err = json.Unmarshal(body, &serverInfo); err != nil {
-		slog.ErrorContext(ctx, "unmarshal server running data error", slog.Any("error", err))
+		m.logger.ErrorContext(ctx, "unmarshal server running data error", slog.Any("error", err))
 		return nil, err
 	}
 
@@ -139,6 +182,7 @@ func getDestinationCluster(destination string) string {
 func (m *clusterManager) GetLock(ctx context.Context) error {
 
 	lockPath := getLockPath(m.destination)
+	session := m.zkConn.SessionID()
 
 	sequence, err := checkAndCreateEphemeralSequence(m.zkConn, lockPath, m.lockSequence)
 	if err != nil {
@@ -155,11 +199,11 @@ func (m *clusterManager) GetLock(ctx context.Context) error {
 
 	sort.Strings(children)
 
-	if m.lockSequence != children[0] {
+	if len(children) == 0 || m.lockSequence != children[0] {
 		for i, child := range children {
 			if m.lockSequence == child {
 				previousPath := fmt.Sprintf("%s/%s", getLockPath(m.destination), children[i-1])
-				if err = waitDelete(m.zkConn, previousPath); err != nil {
+				if err = waitDelete(ctx, m.zkConn, previousPath); err != nil {
 					return err
 				}
 				return m.GetLock(ctx)
@@ -170,5 +214,66 @@ func (m *clusterManager) GetLock(ctx context.Context) error {
 		return m.GetLock(ctx)
 	}
 
+	// 获取锁期间会话失效时，临时节点可能已被删除
+	if m.zkConn.SessionID() != session {
+		return m.GetLock(ctx)
+	}
+
+	m.lockSession.Store(session)
 	return nil
 }
+
+// ReleaseLock 释放锁；删除自己创建的临时节点，让出消费权
+func (m *clusterManager) ReleaseLock(ctx context.Context) error {
+	if m.lockSequence == "" {
+		return nil
+	}
+
+	path := fmt.Sprintf("%s/%s", getLockPath(m.destination), m.lockSequence)
+	if err := m.zkConn.Delete(path, -1); err != nil && !errors.Is(err, zk.ErrNoNode) {
+		m.logger.ErrorContext(ctx, "release lock error",
+			slog.Any("error", err),
+			slog.String("path", path),
+		)
+		return err
+	}
+	m.lockSequence = ""
+	m.lockSession.Store(0)
+
+	return nil
+}
+
+// clusterStatus 集群经理的zookeeper和锁状态，用于健康检查
+type clusterStatus interface {
+	zookeeperState() string
+	lockHeld() bool
+	lockLost() bool
+}
+
+// zookeeperState zookeeper连接状态；未连接时为空
+func (m *clusterManager) zookeeperState() string {
+	m.connMutex.RLock()
+	conn := m.conn
+	m.connMutex.RUnlock()
+	if conn == nil {
+		return ""
+	}
+	return conn.State().String()
+}
+
+// lockHeld 是否持有锁；会话失效后临时节点被删除，不再视为持有
+func (m *clusterManager) lockHeld() bool {
+	m.connMutex.RLock()
+	conn := m.conn
+	m.connMutex.RUnlock()
+	return conn != nil && conn.State() == zk.StateHasSession && !m.lockLost()
+}
+
+// lockLost 是否没有持有锁：没有获取过、已释放或者获取锁的会话已失效
+func (m *clusterManager) lockLost() bool {
+	m.connMutex.RLock()
+	conn := m.conn
+	m.connMutex.RUnlock()
+	session := m.lockSession.Load()
+	return conn == nil || session == 0 || session != conn.SessionID()
+}
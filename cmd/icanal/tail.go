@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kalvinzhang/icanal"
+)
+
+const (
+	idleInterval        = 300 * time.Millisecond // 无数据时的等待时间
+	tailClientIdDefault = 1002                   // tail默认的client id，与消费者默认的1001区分
+)
+
+// errUsage 参数错误，用法已经打印
+var errUsage = errors.New("usage error")
+
+// printer 打印一个批次
+type printer func(w io.Writer, message *icanal.Message) error
+
+var printers = map[string]printer{
+	"table": printTable,
+	"json":  printJSON,
+	"flat":  printFlat,
+}
+
+// runTail tail子命令；ctx结束时正常返回
+func runTail(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	fs := flag.NewFlagSet("tail", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: icanal tail (-address host:port | -zk host:port,...) [flags]")
+		fs.PrintDefaults()
+	}
+
+	address := fs.String("address", "", "canal server地址，与-zk二选一")
+	zkServers := fs.String("zk", "", "zookeeper地址，逗号分隔；连接当前运行的canal server，不抢占消费锁")
+	zkTimeout := fs.Duration("zk-timeout", 10*time.Second, "zookeeper会话超时")
+	destination := fs.String("destination", "example", "destination名称")
+	username := fs.String("username", "", "用户名")
+	password := fs.String("password", "", "密码")
+	clientId := fs.Int("client-id", tailClientIdDefault, "client id；与消费者不同时不会拿走消费者的批次，也不会替换消费者订阅的filter")
+	filter := fs.String("filter", `.*\..*`, "订阅的filter")
+	format := fs.String("format", "table", "输出格式：table、json或flat(canal FlatMessage)")
+	batchSize := fs.Int("batch-size", icanal.BatchSizeDefault, "每次拉取的数量")
+	noAck := fs.Bool("no-ack", false, "只拉取不确认，退出时回滚；确认会推进destination共享的事件缓存，排查正在消费的destination时应开启")
+	verbose := fs.Bool("v", false, "输出连接日志")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+
+	usageError := func(format string, args ...any) error {
+		fmt.Fprintf(stderr, format+"\n", args...)
+		fs.Usage()
+		return errUsage
+	}
+	if (*address == "") == (*zkServers == "") {
+		return usageError("exactly one of -address and -zk is required")
+	}
+	if *clientId <= 0 || *clientId > math.MaxInt16 {
+		return usageError("invalid client id %d", *clientId)
+	}
+	printMessage, ok := printers[*format]
+	if !ok {
+		return usageError("unknown format %q", *format)
+	}
+
+	level := slog.LevelWarn
+	if *verbose {
+		level = slog.LevelInfo
+	}
+	logger := slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: level}))
+
+	opts := []icanal.Option{
+		icanal.WithUsername(*username),
+		icanal.WithPassword(*password),
+		icanal.WithClientId(*clientId),
+		icanal.WithLogger(logger),
+	}
+	if *noAck {
+		// 连接时回滚会让正在运行的消费者重新收到未确认的批次
+		opts = append(opts, icanal.WithRollbackOnConnect(false))
+	}
+
+	if *zkServers != "" {
+		node, err := runningNode(ctx, *destination, strings.Split(*zkServers, ","), *zkTimeout, logger)
+		if err != nil {
+			return err
+		}
+		*address = node
+	}
+
+	connector := icanal.NewSimpleConnector(*address, *destination, opts...)
+	if err := connector.Connect(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		if err := connector.Disconnect(context.WithoutCancel(ctx)); err != nil {
+			logger.WarnContext(ctx, "failed to disconnect", slog.Any("error", err))
+		}
+	}()
+
+	if err := connector.Subscribe(ctx, *filter); err != nil {
+		return err
+	}
+
+	get := connector.Get
+	if *noAck {
+		get = connector.GetWithoutAck
+		defer func() {
+			if err := connector.Rollback(context.WithoutCancel(ctx), 0); err != nil {
+				logger.WarnContext(ctx, "failed to rollback", slog.Any("error", err))
+			}
+		}()
+	}
+
+	for ctx.Err() == nil {
+		message, err := get(ctx, int32(*batchSize), time.Second)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		if message == nil || message.Id == -1 || len(message.Entries) == 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(idleInterval):
+			}
+			continue
+		}
+
+		if err = printMessage(stdout, message); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runningNode 从zookeeper读取当前运行的canal server地址；只读取不获取消费锁
+func runningNode(ctx context.Context, destination string, zkServers []string, zkTimeout time.Duration, logger *slog.Logger) (string, error) {
+	clusterManager := icanal.NewClusterNodeManager(destination, zkServers, zkTimeout, icanal.WithClusterManagerLogger(logger))
+	if err := clusterManager.Init(ctx); err != nil {
+		return "", err
+	}
+	return clusterManager.GetNode(ctx)
+}
+
+// printTable 每个变更打印一行摘要和一张列表格；主键列名前加*，变更的列后加~
+func printTable(w io.Writer, message *icanal.Message) error {
+	events, err := icanal.ParseChangeEvents(message)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, event := range events {
+		header := event.Header
+		summary := fmt.Sprintf("%s  %s  %s.%s  %s:%d  %s",
+			time.UnixMilli(header.GetExecuteTime()).Format("2006-01-02 15:04:05.000"),
+			event.EventType, event.SchemaName(), event.TableName(),
+			header.GetLogfileName(), header.GetLogfileOffset(), header.GetGtid())
+		fmt.Fprintln(tw, strings.TrimSpace(summary))
+
+		if event.IsDdl {
+			fmt.Fprintf(tw, "    %s\n\n", event.Sql)
+			continue
+		}
+
+		before := event.RowData.GetBeforeColumns()
+		after := event.RowData.GetAfterColumns()
+		fmt.Fprintln(tw, "    COLUMN\tBEFORE\tAFTER")
+		for i, column := range event.Columns() {
+			name := column.GetName()
+			if column.GetIsKey() {
+				name = "*" + name
+			}
+			var beforeValue, afterValue string
+			if i < len(before) {
+				beforeValue = columnString(before[i])
+			}
+			if i < len(after) {
+				afterValue = columnString(after[i])
+				if after[i].GetUpdated() && event.EventType == icanal.EventType_UPDATE {
+					afterValue += " ~"
+				}
+			}
+			fmt.Fprintf(tw, "    %s\t%s\t%s\n", name, beforeValue, afterValue)
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+// columnString 列值，NULL显示为NULL
+func columnString(column *icanal.Column) string {
+	if column.GetIsNull() {
+		return "NULL"
+	}
+	return column.GetValue()
+}
+
+// printJSON 每个变更输出一行原生JSON
+func printJSON(w io.Writer, message *icanal.Message) error {
+	events, err := icanal.ParseChangeEvents(message)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	for _, event := range events {
+		if err = encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printFlat 每个ROWDATA类型的Entry输出一行canal FlatMessage
+func printFlat(w io.Writer, message *icanal.Message) error {
+	flatMessages, err := icanal.NewFlatMessages(message)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	for _, flatMessage := range flatMessages {
+		if err = encoder.Encode(flatMessage); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/kalvinzhang/icanal"
+)
+
+func testMessage() *icanal.Message {
+	rowChange, _ := proto.Marshal(&icanal.RowChange{
+		EventTypePresent: &icanal.RowChange_EventType{EventType: icanal.EventType_UPDATE},
+		RowDatas: []*icanal.RowData{{
+			BeforeColumns: []*icanal.Column{
+				{Name: "id", IsKey: true, Value: "1"},
+				{Name: "name", Value: "alice"},
+			},
+			AfterColumns: []*icanal.Column{
+				{Name: "id", IsKey: true, Value: "1"},
+				{Name: "name", Value: "bob", Updated: true},
+			},
+		}},
+	})
+
+	return &icanal.Message{Id: 7, Entries: []*icanal.Entry{{
+		Header: &icanal.Header{
+			LogfileName:      "mysql-bin.000001",
+			LogfileOffset:    1024,
+			SchemaName:       "test",
+			TableName:        "user",
+			EventTypePresent: &icanal.Header_EventType{EventType: icanal.EventType_UPDATE},
+		},
+		EntryTypePresent: &icanal.Entry_EntryType{EntryType: icanal.EntryType_ROWDATA},
+		StoreValue:       rowChange,
+	}}}
+}
+
+func TestPrinters(t *testing.T) {
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{"table", []string{"UPDATE  test.user  mysql-bin.000001:1024", "*id     1       1\n", "name    alice   bob ~\n"}},
+		{"json", []string{`"table":"user"`, `"after":{"id":"1","name":"bob"}`}},
+		{"flat", []string{`"id":7`, `"old":[{"name":"alice"}]`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := printers[tt.format](&buf, testMessage()); err != nil {
+				t.Fatalf("print error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("output = %q, want containing %q", buf.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestRunTail_Usage(t *testing.T) {
+	tests := [][]string{
+		{},
+		{"-address", "127.0.0.1:11111", "-zk", "127.0.0.1:2181"},
+		{"-address", "127.0.0.1:11111", "-format", "xml"},
+		{"-address", "127.0.0.1:11111", "-client-id", "0"},
+	}
+	for _, args := range tests {
+		if err := runTail(context.Background(), args, io.Discard, io.Discard); !errors.Is(err, errUsage) {
+			t.Errorf("runTail(%v) error = %v, want %v", args, err, errUsage)
+		}
+	}
+}
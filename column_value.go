@@ -0,0 +1,93 @@
+package icanal
+
+import (
+	"strconv"
+	"strings"
+)
+
+// mysqlType 解析后的mysql列类型，如"int(10) unsigned"解析为{base: int, length: 10, unsigned: true}
+type mysqlType struct {
+	base     string
+	length   int // 括号内第一个数字，如varchar长度、datetime精度
+	unsigned bool
+}
+
+func parseMysqlType(s string) mysqlType {
+	s = strings.ToLower(strings.TrimSpace(s))
+	t := mysqlType{
+		unsigned: strings.Contains(s, "unsigned"),
+	}
+
+	base, _, _ := strings.Cut(s, " ")
+	if i := strings.IndexByte(base, '('); i >= 0 {
+		args := strings.TrimSuffix(base[i+1:], ")")
+		base = base[:i]
+		first, _, _ := strings.Cut(args, ",")
+		t.length, _ = strconv.Atoi(first)
+	}
+	t.base = base
+
+	return t
+}
+
+// isInteger 是否整数类型
+func (t mysqlType) isInteger() bool {
+	switch t.base {
+	case "tinyint", "smallint", "mediumint", "int", "integer", "bigint", "year":
+		return true
+	}
+	return false
+}
+
+// isFloat 是否浮点类型
+func (t mysqlType) isFloat() bool {
+	switch t.base {
+	case "float", "double", "real":
+		return true
+	}
+	return false
+}
+
+// isBinary 是否二进制类型
+func (t mysqlType) isBinary() bool {
+	switch t.base {
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob":
+		return true
+	}
+	return false
+}
+
+// columnValue 把canal的字符串列值转换为JSON值；NULL为nil，整数和浮点数转为数字，其他保持字符串
+func columnValue(column *Column) any {
+	if column.GetIsNull() {
+		return nil
+	}
+
+	value := column.GetValue()
+	t := parseMysqlType(column.GetMysqlType())
+	switch {
+	case t.isInteger():
+		if t.unsigned {
+			if v, err := strconv.ParseUint(value, 10, 64); err == nil {
+				return v
+			}
+		} else if v, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return v
+		}
+	case t.isFloat():
+		if v, err := strconv.ParseFloat(value, 64); err == nil {
+			return v
+		}
+	}
+
+	return value
+}
+
+// latin1Bytes canal用ISO-8859-1把二进制列编码为字符串，这里还原为原始字节
+func latin1Bytes(value string) []byte {
+	data := make([]byte, 0, len(value))
+	for _, r := range value {
+		data = append(data, byte(r))
+	}
+	return data
+}
@@ -13,10 +13,23 @@ type clusterConnector struct {
 	simpleConnector Connector
 	retryTimes      int
 	retryWait       time.Duration
+	configErr       error
+	logger          *slog.Logger
+	restarted       []func() // 内部重连成功后调用，供包装的连接器记录重连
 }
 
 // NewClusterConnector 新建集群连接器
 func NewClusterConnector(destination string, zkServer []string, zkSessionTimeout time.Duration, opts ...Option) Connector {
+	config := getDefaultConfig()
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	clusterManager := NewClusterNodeManager(destination, zkServer, zkSessionTimeout, WithClusterManagerLogger(config.Logger))
+	return newClusterConnector(destination, clusterManager, opts...)
+}
+
+func newClusterConnector(destination string, clusterManager ClusterManager, opts ...Option) Connector {
 
 	config := getDefaultConfig()
 
@@ -28,13 +41,22 @@ func NewClusterConnector(destination string, zkServer []string, zkSessionTimeout
 	return &clusterConnector{
 		destination:    destination,
 		opts:           opts,
-		clusterManager: NewClusterNodeManager(destination, zkServer, zkSessionTimeout),
+		clusterManager: clusterManager,
 		retryTimes:     config.RetryTimes,
 		retryWait:      config.RetryInterval,
+		configErr:      config.err,
+		logger: loggerOrDefault(config.Logger).With(
+			slog.String(LogKeyDestination, destination),
+			slog.Int(LogKeyClientId, config.ClientId),
+		),
 	}
 }
 
 func (c *clusterConnector) Connect(ctx context.Context) error {
+	if c.configErr != nil {
+		return c.configErr
+	}
+
 	if err := c.clusterManager.Init(ctx); err != nil {
 		return err
 	}
@@ -45,19 +67,22 @@ func (c *clusterConnector) Connect(ctx context.Context) error {
 	}
 
 	for times := 0; times < c.retryTimes; times++ {
-		if err := c.getNodeAndConnect(ctx); err == nil { // 成功直接返回
+		err := c.getNodeAndConnect(ctx)
+		if err == nil { // 成功直接返回
 			return nil
 		}
 
-		slog.WarnContext(ctx, "failed to connect to canal server after retry",
-			slog.Int("retry_times", times))
+		c.logger.WarnContext(ctx, "failed to connect to canal server after retry",
+			slog.Int("retryTimes", times),
+			slog.Any("error", err),
+		)
 
 		if times < c.retryTimes-1 {
 			time.Sleep(c.retryWait)
 		}
 	}
 
-	slog.ErrorContext(ctx, "failed to connect over retry times")
+	c.logger.ErrorContext(ctx, "failed to connect over retry times")
 	return ErrOverRetryTimes
 }
 
@@ -78,15 +103,18 @@ func (c *clusterConnector) getNodeAndConnect(ctx context.Context) error {
 
 func (c *clusterConnector) restart(ctx context.Context) error {
 	if err := c.Disconnect(ctx); err != nil {
-		slog.WarnContext(ctx, "failed to disconnect", slog.Any("error", err))
+		c.logger.WarnContext(ctx, "failed to disconnect", slog.Any("error", err))
 	}
 
 	time.Sleep(c.retryWait)
 
 	if err := c.Connect(ctx); err != nil {
-		slog.ErrorContext(ctx, "restart connector fail", slog.Any("error", err))
+		c.logger.ErrorContext(ctx, "restart connector fail", slog.Any("error", err))
 		return err
 	}
+	for _, f := range c.restarted {
+		f()
+	}
 
 	return nil
 }
@@ -104,6 +132,9 @@ func (c *clusterConnector) Disconnect(ctx context.Context) error {
 }
 
 func (c *clusterConnector) Subscribe(ctx context.Context, filter string) error {
+	if err := c.checkLock(ctx); err != nil {
+		return err
+	}
 	for times := 0; times < c.retryTimes; times++ {
 		if err := c.simpleConnector.Subscribe(ctx, filter); err == nil {
 			return nil
@@ -112,11 +143,14 @@ func (c *clusterConnector) Subscribe(ctx context.Context, filter string) error {
 			return err
 		}
 	}
-	slog.ErrorContext(ctx, "failed to subscribe over retry times")
+	c.logger.ErrorContext(ctx, "failed to subscribe over retry times")
 	return ErrOverRetryTimes
 }
 
 func (c *clusterConnector) Unsubscribe(ctx context.Context) error {
+	if err := c.checkLock(ctx); err != nil {
+		return err
+	}
 	for times := 0; times < c.retryTimes; times++ {
 		if err := c.simpleConnector.Unsubscribe(ctx); err == nil {
 			return nil
@@ -125,11 +159,14 @@ func (c *clusterConnector) Unsubscribe(ctx context.Context) error {
 			return err
 		}
 	}
-	slog.ErrorContext(ctx, "failed to unsubscribe over retry times")
+	c.logger.ErrorContext(ctx, "failed to unsubscribe over retry times")
 	return ErrOverRetryTimes
 }
 
 func (c *clusterConnector) Get(ctx context.Context, batchSize int32, timeout time.Duration) (*Message, error) {
+	if err := c.checkLock(ctx); err != nil {
+		return nil, err
+	}
 	for times := 0; times < c.retryTimes; times++ {
 		message, err := c.simpleConnector.Get(ctx, batchSize, timeout)
 		if err == nil {
@@ -139,11 +176,14 @@ func (c *clusterConnector) Get(ctx context.Context, batchSize int32, timeout tim
 			return nil, err
 		}
 	}
-	slog.ErrorContext(ctx, "failed to get over retry times")
+	c.logger.ErrorContext(ctx, "failed to get over retry times")
 	return nil, ErrOverRetryTimes
 }
 
 func (c *clusterConnector) GetWithoutAck(ctx context.Context, batchSize int32, timeout time.Duration) (*Message, error) {
+	if err := c.checkLock(ctx); err != nil {
+		return nil, err
+	}
 	for times := 0; times < c.retryTimes; times++ {
 		message, err := c.simpleConnector.GetWithoutAck(ctx, batchSize, timeout)
 		if err == nil {
@@ -153,11 +193,14 @@ func (c *clusterConnector) GetWithoutAck(ctx context.Context, batchSize int32, t
 			return nil, err
 		}
 	}
-	slog.ErrorContext(ctx, "failed to get without ack over retry times")
+	c.logger.ErrorContext(ctx, "failed to get without ack over retry times")
 	return nil, ErrOverRetryTimes
 }
 
 func (c *clusterConnector) Ack(ctx context.Context, batchId int64) error {
+	if err := c.checkLock(ctx); err != nil {
+		return err
+	}
 	for times := 0; times < c.retryTimes; times++ {
 		if err := c.simpleConnector.Ack(ctx, batchId); err == nil {
 			return nil
@@ -166,11 +209,14 @@ func (c *clusterConnector) Ack(ctx context.Context, batchId int64) error {
 			return err
 		}
 	}
-	slog.ErrorContext(ctx, "failed to ack over retry times")
+	c.logger.ErrorContext(ctx, "failed to ack over retry times", slog.Int64(LogKeyBatchId, batchId))
 	return ErrOverRetryTimes
 }
 
 func (c *clusterConnector) Rollback(ctx context.Context, batchId int64) error {
+	if err := c.checkLock(ctx); err != nil {
+		return err
+	}
 	for times := 0; times < c.retryTimes; times++ {
 		if err := c.simpleConnector.Rollback(ctx, batchId); err == nil {
 			return nil
@@ -179,6 +225,16 @@ func (c *clusterConnector) Rollback(ctx context.Context, batchId int64) error {
 			return err
 		}
 	}
-	slog.ErrorContext(ctx, "failed to rollback over retry times")
+	c.logger.ErrorContext(ctx, "failed to rollback over retry times", slog.Int64(LogKeyBatchId, batchId))
 	return ErrOverRetryTimes
 }
+
+// checkLock 会话失效后锁节点已被删除，其他实例可能已经开始消费；需要重新连接来重新获取锁
+func (c *clusterConnector) checkLock(ctx context.Context) error {
+	status, ok := c.clusterManager.(clusterStatus)
+	if !ok || !status.lockLost() {
+		return nil
+	}
+	c.logger.WarnContext(ctx, "consumer lock lost, reconnect to acquire it again")
+	return ErrLockLost
+}
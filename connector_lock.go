@@ -1,6 +1,7 @@
 package icanal
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -10,11 +11,30 @@ import (
 
 const consumerPath = "/canal-consumer"
 
+// zkClient 用到的zookeeper操作，*zk.Conn实现了该接口
+type zkClient interface {
+	Children(path string) ([]string, *zk.Stat, error)
+	ChildrenW(path string) ([]string, *zk.Stat, <-chan zk.Event, error)
+	Get(path string) ([]byte, *zk.Stat, error)
+	Exists(path string) (bool, *zk.Stat, error)
+	ExistsW(path string) (bool, *zk.Stat, <-chan zk.Event, error)
+	Create(path string, data []byte, flags int32, acl []zk.ACL) (string, error)
+	Delete(path string, version int32) error
+	State() zk.State
+	SessionID() int64
+	Close()
+}
+
+// isSessionExpired 是否为会话失效事件；失效后临时节点已被删除
+func isSessionExpired(event zk.Event) bool {
+	return event.Type == zk.EventSession && event.State == zk.StateExpired
+}
+
 func getLockPath(destination string) string {
 	return fmt.Sprintf("%s/%s", consumerPath, destination)
 }
 
-func checkAndCreateEphemeralSequence(zkConn *zk.Conn, lockPath string, sequence string) (string, error) {
+func checkAndCreateEphemeralSequence(zkConn zkClient, lockPath string, sequence string) (string, error) {
 	children, _, err := zkConn.Children(lockPath)
 	if err != nil {
 		return "", err
@@ -30,7 +50,7 @@ func checkAndCreateEphemeralSequence(zkConn *zk.Conn, lockPath string, sequence
 	return "", nil
 }
 
-func checkRootPath(zkConn *zk.Conn, rootPath string) error {
+func checkRootPath(zkConn zkClient, rootPath string) error {
 	parts := strings.Split(rootPath, "/")
 
 	for i := 1; i < len(parts); i++ {
@@ -54,7 +74,7 @@ const (
 	notRunningFlag = byte(0)
 )
 
-func createEphemeralSequence(zkConn *zk.Conn, path string) (string, error) {
+func createEphemeralSequence(zkConn zkClient, path string) (string, error) {
 	node, err := zkConn.Create(path+"/",
 		[]byte{notRunningFlag},
 		zk.FlagEphemeral|zk.FlagSequence,
@@ -67,19 +87,28 @@ func createEphemeralSequence(zkConn *zk.Conn, path string) (string, error) {
 }
 
 // 等待删除
-func waitDelete(zkConn *zk.Conn, previousPath string) error {
+func waitDelete(ctx context.Context, zkConn zkClient, previousPath string) error {
 	exists, _, events, err := zkConn.ExistsW(previousPath)
 	if err != nil {
 		return err
 	}
 
 	if exists {
-		event := <-events
+		var event zk.Event
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event = <-events:
+		}
 		if event.Type != zk.EventNodeDeleted {
-			return waitDelete(zkConn, previousPath)
+			return waitDelete(ctx, zkConn, previousPath)
 		} else {
-			time.Sleep(10 * time.Second)
-			return waitDelete(zkConn, previousPath)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(10 * time.Second):
+			}
+			return waitDelete(ctx, zkConn, previousPath)
 		}
 	}
 
@@ -0,0 +1,244 @@
+package icanal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+)
+
+// fakeZk 内存中的zookeeper，只实现zkClient用到的操作
+type fakeZk struct {
+	mutex     sync.Mutex
+	nodes     map[string]fakeZNode
+	sequence  int
+	session   int64
+	watches   map[string][]chan zk.Event
+	events    chan zk.Event
+	closeOnce sync.Once
+}
+
+type fakeZNode struct {
+	data    []byte
+	session int64 // 临时节点所属的会话，持久节点为0
+}
+
+func newFakeZk() *fakeZk {
+	return &fakeZk{
+		nodes:   map[string]fakeZNode{"/": {}},
+		session: 1,
+		watches: make(map[string][]chan zk.Event),
+		events:  make(chan zk.Event, 16),
+	}
+}
+
+// expire 模拟会话失效：删除该会话的临时节点并换一个新会话
+func (z *fakeZk) expire() {
+	z.mutex.Lock()
+	for p, node := range z.nodes {
+		if node.session == z.session {
+			z.deleteLocked(p)
+		}
+	}
+	z.session++
+	z.mutex.Unlock()
+
+	z.events <- zk.Event{Type: zk.EventSession, State: zk.StateExpired}
+	z.events <- zk.Event{Type: zk.EventSession, State: zk.StateHasSession}
+}
+
+func (z *fakeZk) Children(p string) ([]string, *zk.Stat, error) {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+
+	if _, ok := z.nodes[p]; !ok {
+		return nil, nil, zk.ErrNoNode
+	}
+	var children []string
+	for child := range z.nodes {
+		if child != "/" && path.Dir(child) == p {
+			children = append(children, path.Base(child))
+		}
+	}
+	sort.Strings(children)
+	return children, &zk.Stat{}, nil
+}
+
+func (z *fakeZk) ChildrenW(p string) ([]string, *zk.Stat, <-chan zk.Event, error) {
+	children, stat, err := z.Children(p)
+	return children, stat, make(chan zk.Event), err
+}
+
+func (z *fakeZk) Get(p string) ([]byte, *zk.Stat, error) {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+
+	node, ok := z.nodes[p]
+	if !ok {
+		return nil, nil, zk.ErrNoNode
+	}
+	return node.data, &zk.Stat{}, nil
+}
+
+func (z *fakeZk) Exists(p string) (bool, *zk.Stat, error) {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+
+	_, ok := z.nodes[p]
+	return ok, &zk.Stat{}, nil
+}
+
+func (z *fakeZk) ExistsW(p string) (bool, *zk.Stat, <-chan zk.Event, error) {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+
+	events := make(chan zk.Event, 1)
+	z.watches[p] = append(z.watches[p], events)
+	_, ok := z.nodes[p]
+	return ok, &zk.Stat{}, events, nil
+}
+
+func (z *fakeZk) Create(p string, data []byte, flags int32, _ []zk.ACL) (string, error) {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+
+	parent := path.Dir(strings.TrimSuffix(p, "/"))
+	if flags&zk.FlagSequence != 0 {
+		p = fmt.Sprintf("%s%010d", p, z.sequence)
+		z.sequence++
+	}
+	if _, ok := z.nodes[parent]; !ok {
+		return "", zk.ErrNoNode
+	}
+	if _, ok := z.nodes[p]; ok {
+		return "", zk.ErrNodeExists
+	}
+
+	node := fakeZNode{data: data}
+	if flags&zk.FlagEphemeral != 0 {
+		node.session = z.session
+	}
+	z.nodes[p] = node
+	z.fireLocked(p, zk.EventNodeCreated)
+	return p, nil
+}
+
+func (z *fakeZk) Delete(p string, _ int32) error {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+
+	if _, ok := z.nodes[p]; !ok {
+		return zk.ErrNoNode
+	}
+	z.deleteLocked(p)
+	return nil
+}
+
+func (z *fakeZk) State() zk.State {
+	return zk.StateHasSession
+}
+
+func (z *fakeZk) SessionID() int64 {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+	return z.session
+}
+
+func (z *fakeZk) Close() {
+	z.closeOnce.Do(func() { close(z.events) })
+}
+
+func (z *fakeZk) deleteLocked(p string) {
+	delete(z.nodes, p)
+	z.fireLocked(p, zk.EventNodeDeleted)
+}
+
+func (z *fakeZk) fireLocked(p string, eventType zk.EventType) {
+	for _, events := range z.watches[p] {
+		events <- zk.Event{Type: eventType, Path: p}
+	}
+	delete(z.watches, p)
+}
+
+func TestWaitDelete(t *testing.T) {
+	zkConn := newFakeZk()
+	if err := checkRootPath(zkConn, "/lock/node"); err != nil {
+		t.Fatalf("checkRootPath() error = %v", err)
+	}
+
+	if err := waitDelete(context.Background(), zkConn, "/lock/missing"); err != nil {
+		t.Errorf("waitDelete(missing) error = %v, want nil", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := waitDelete(ctx, zkConn, "/lock/node"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("waitDelete(exists) error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// 节点删除后等待期间ctx结束
+	ctx, cancel = context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- waitDelete(ctx, zkConn, "/lock/node") }()
+	time.Sleep(20 * time.Millisecond)
+	if err := zkConn.Delete("/lock/node", -1); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("waitDelete(deleted) error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waitDelete() did not return after cancel")
+	}
+}
+
+func TestClusterManager_LockLost(t *testing.T) {
+	ctx := context.Background()
+	zkConn := newFakeZk()
+	if err := checkRootPath(zkConn, getDestinationCluster("example")); err != nil {
+		t.Fatalf("checkRootPath() error = %v", err)
+	}
+
+	m := newSharedClusterNodeManager("example", zkConn)
+	if err := m.Init(ctx); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if !m.lockLost() {
+		t.Error("lockLost() = false before GetLock")
+	}
+	if err := m.GetLock(ctx); err != nil {
+		t.Fatalf("GetLock() error = %v", err)
+	}
+	if m.lockLost() || !m.lockHeld() {
+		t.Error("lock not held after GetLock")
+	}
+
+	zkConn.expire()
+	if !m.lockLost() {
+		t.Error("lockLost() = false after session expired")
+	}
+	if err := m.GetLock(ctx); err != nil {
+		t.Fatalf("GetLock() after expire error = %v", err)
+	}
+	if m.lockLost() {
+		t.Error("lockLost() = true after re-acquiring")
+	}
+
+	if err := m.ReleaseLock(ctx); err != nil {
+		t.Fatalf("ReleaseLock() error = %v", err)
+	}
+	if children, _, _ := zkConn.Children(getLockPath("example")); len(children) != 0 {
+		t.Errorf("lock nodes = %v after ReleaseLock, want none", children)
+	}
+}
@@ -1,6 +1,12 @@
 package icanal
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+)
 
 const (
 	BatchSizeDefault     = 1000                  // 默认batch size
@@ -15,18 +21,41 @@ const (
 type ConnectorConfig struct {
 	Username             string        // 用户名
 	Password             string        // 密码
+	ClientId             int           // client id；同一destination的不同消费者使用不同的client id
 	SoTimeout            time.Duration // 网络超时
 	IdleTimeout          time.Duration // 空闲超时
 	RollbackOnConnect    bool          // 是否在connect链接成功后，自动执行rollback操作
 	RollbackOnDisconnect bool          // 是否在connect链接断开后，自动执行rollback操作
 	LazyParseEntry       bool          // 是否自动化解析Entry对象,如果考虑最大化性能可以延后解析
 	Filter               string        // 记录上一次的filter提交值,便于自动重试时提交
+	BlackFilter          string        // 客户端表黑名单，语法同filter
+	FieldFilter          string        // 客户端列白名单，如"schema.table:col1/col2"
+	FieldBlackFilter     string        // 客户端列黑名单，语法同FieldFilter
 	RetryTimes           int
 	RetryInterval        time.Duration
+	ReplaySpeed          float64      // 回放倍速，按录制时的间隔返回批次；为0时不等待
+	Logger               *slog.Logger // 日志，为nil时使用slog.Default()
+	err                  error        // 选项校验错误，在Connect时返回
+}
+
+// newFilter 按配置新建客户端过滤器
+func (c *ConnectorConfig) newFilter(filter string) (*Filter, error) {
+	f, err := NewFilter(filter, c.BlackFilter)
+	if err != nil {
+		return nil, err
+	}
+	if err = f.IncludeColumns(c.FieldFilter); err != nil {
+		return nil, err
+	}
+	if err = f.ExcludeColumns(c.FieldBlackFilter); err != nil {
+		return nil, err
+	}
+	return f, nil
 }
 
 func getDefaultConfig() *ConnectorConfig {
 	return &ConnectorConfig{
+		ClientId:             ClientIdDefault,
 		SoTimeout:            SoTimeoutDefault,
 		IdleTimeout:          IdleTimeoutDefault,
 		RollbackOnConnect:    true,
@@ -51,6 +80,16 @@ func WithPassword(password string) Option {
 	}
 }
 
+// WithClientId 设置client id，canal中为short类型；超出范围时Connect返回ErrInvalidClientId
+func WithClientId(clientId int) Option {
+	return func(c *ConnectorConfig) {
+		c.ClientId = clientId
+		if clientId <= 0 || clientId > math.MaxInt16 {
+			c.err = errors.Join(c.err, fmt.Errorf("%w: %d", ErrInvalidClientId, clientId))
+		}
+	}
+}
+
 func WithIdleTimeout(idleTimeout time.Duration) Option {
 	return func(c *ConnectorConfig) {
 		c.IdleTimeout = idleTimeout
@@ -63,9 +102,37 @@ func WithSoTimeout(soTimeout time.Duration) Option {
 	}
 }
 
+// WithFilter 设置filter；语法错误会在Connect时返回ErrInvalidFilter
 func WithFilter(filter string) Option {
 	return func(c *ConnectorConfig) {
 		c.Filter = filter
+		c.err = errors.Join(c.err, ValidateFilter(filter))
+	}
+}
+
+// WithBlackFilter 设置客户端表黑名单
+func WithBlackFilter(blackFilter string) Option {
+	return func(c *ConnectorConfig) {
+		c.BlackFilter = blackFilter
+		c.err = errors.Join(c.err, ValidateFilter(blackFilter))
+	}
+}
+
+// WithFieldFilter 设置客户端列白名单
+func WithFieldFilter(fieldFilter string) Option {
+	return func(c *ConnectorConfig) {
+		c.FieldFilter = fieldFilter
+		_, err := parseFieldFilter(fieldFilter)
+		c.err = errors.Join(c.err, err)
+	}
+}
+
+// WithFieldBlackFilter 设置客户端列黑名单
+func WithFieldBlackFilter(fieldBlackFilter string) Option {
+	return func(c *ConnectorConfig) {
+		c.FieldBlackFilter = fieldBlackFilter
+		_, err := parseFieldFilter(fieldBlackFilter)
+		c.err = errors.Join(c.err, err)
 	}
 }
 
@@ -87,6 +154,13 @@ func WithLazyParseEntry(lazyParseEntry bool) Option {
 	}
 }
 
+// WithReplaySpeed 设置回放倍速，1为原始速度
+func WithReplaySpeed(replaySpeed float64) Option {
+	return func(c *ConnectorConfig) {
+		c.ReplaySpeed = replaySpeed
+	}
+}
+
 func WithRetryTimes(retryTimes int) Option {
 	return func(c *ConnectorConfig) {
 		c.RetryTimes = retryTimes
@@ -98,3 +172,10 @@ func WithRetryInterval(retryInterval time.Duration) Option {
 		c.RetryInterval = retryInterval
 	}
 }
+
+// WithLogger 设置日志；集群连接器同时用于zookeeper，QuietLogger()可关闭日志
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *ConnectorConfig) {
+		c.Logger = logger
+	}
+}
@@ -0,0 +1,210 @@
+package icanal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/kalvinzhang/icanal/protocol/canal"
+)
+
+type replayConnector struct {
+	path   string
+	config *ConnectorConfig
+	filter *Filter
+
+	mutex       sync.Mutex
+	packets     []recordedPacket
+	acked       int       // 已确认的包数
+	outstanding int       // 已获取未确认的包数，紧跟在已确认的包之后
+	lastTime    time.Time // 上一个返回的包的录制时间
+}
+
+// NewReplayConnector 新建回放连接器，从Recorder录制的文件中读取消息
+//
+// 每次Get返回一个录制的批次，batch id与录制时相同，batchSize被忽略；录制的批次取完后返回空批次。
+// 确认必须按获取的顺序进行；回滚后该批次及之后获取的批次会重新投递，重新连接后所有未确认的批次会重新投递。
+// 设置ReplaySpeed后按录制时的间隔(除以倍速)返回批次，否则立即返回。
+func NewReplayConnector(path string, opts ...Option) Connector {
+	config := getDefaultConfig()
+
+	// 应用所有选项
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	// 选项有误时filter为nil，错误在Connect时返回
+	filter, _ := config.newFilter(config.Filter)
+
+	return &replayConnector{
+		path:   path,
+		config: config,
+		filter: filter,
+	}
+}
+
+// Connect 第一次连接时读取录制文件；之后的连接会重新投递未确认的批次
+func (c *replayConnector) Connect(_ context.Context) error {
+	if c.config.err != nil {
+		return c.config.err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.packets == nil {
+		file, err := os.Open(c.path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		if c.packets, err = readRecords(file); err != nil {
+			return err
+		}
+	}
+	c.outstanding = 0
+	c.lastTime = time.Time{}
+
+	return nil
+}
+
+func (c *replayConnector) Disconnect(_ context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.outstanding = 0
+	return nil
+}
+
+func (c *replayConnector) Subscribe(_ context.Context, filter string) error {
+	f, err := c.config.newFilter(filter)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.config.Filter = filter
+	c.filter = f
+	return nil
+}
+
+func (c *replayConnector) Unsubscribe(_ context.Context) error {
+	return nil
+}
+
+func (c *replayConnector) Get(ctx context.Context, batchSize int32, timeout time.Duration) (*Message, error) {
+	message, err := c.GetWithoutAck(ctx, batchSize, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = c.Ack(ctx, message.Id); err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+func (c *replayConnector) GetWithoutAck(ctx context.Context, _ int32, _ time.Duration) (*Message, error) {
+	var packet recordedPacket
+	for {
+		c.mutex.Lock()
+		next := c.acked + c.outstanding
+		if next >= len(c.packets) {
+			c.mutex.Unlock()
+			return &Message{Id: -1}, nil
+		}
+		packet = c.packets[next]
+		var wait time.Duration
+		if c.config.ReplaySpeed > 0 && !c.lastTime.IsZero() {
+			wait = time.Duration(float64(packet.timestamp.Sub(c.lastTime)) / c.config.ReplaySpeed)
+		}
+		c.mutex.Unlock()
+
+		// 等待时不持有锁，Ack和Rollback不会被阻塞
+		if wait > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+
+		// 等待期间回滚或确认改变了下一个批次时重新计算
+		c.mutex.Lock()
+		if c.acked+c.outstanding == next {
+			break
+		}
+		c.mutex.Unlock()
+	}
+	defer c.mutex.Unlock()
+
+	c.lastTime = packet.timestamp
+
+	pk := &canal.Packet{}
+	if err := proto.Unmarshal(packet.data, pk); err != nil {
+		return nil, errors.Join(ErrUnmarshal, err)
+	}
+	message, err := decodeMessages(pk, c.config.LazyParseEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.filter != nil {
+		if err = c.filter.filterMessage(message); err != nil {
+			return nil, err
+		}
+	}
+
+	c.outstanding++
+	return message, nil
+}
+
+// Ack 确认第一个未确认的批次；空批次直接返回
+func (c *replayConnector) Ack(_ context.Context, batchId int64) error {
+	if batchId == -1 {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.outstanding == 0 || c.packets[c.acked].batchId != batchId {
+		return fmt.Errorf("%w: batch %d is not the first outstanding batch", ErrBatchNotTracked, batchId)
+	}
+	c.acked++
+	c.outstanding--
+
+	return nil
+}
+
+// Rollback 回滚batchId及之后获取的批次，batchId为0时回滚所有未确认的批次
+func (c *replayConnector) Rollback(_ context.Context, batchId int64) error {
+	if batchId == -1 {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if batchId == 0 {
+		c.outstanding = 0
+		return nil
+	}
+	for i := 0; i < c.outstanding; i++ {
+		if c.packets[c.acked+i].batchId == batchId {
+			c.outstanding = i
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: batch %d is not outstanding", ErrBatchNotTracked, batchId)
+}
@@ -0,0 +1,265 @@
+package icanal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// messageConnector 依次返回给定消息的连接器
+type messageConnector struct {
+	Connector
+	messages []*Message
+}
+
+func (c *messageConnector) GetWithoutAck(context.Context, int32, time.Duration) (*Message, error) {
+	if len(c.messages) == 0 {
+		return &Message{Id: -1}, nil
+	}
+	message := c.messages[0]
+	c.messages = c.messages[1:]
+	return message, nil
+}
+
+func (c *messageConnector) Get(ctx context.Context, batchSize int32, timeout time.Duration) (*Message, error) {
+	return c.GetWithoutAck(ctx, batchSize, timeout)
+}
+
+// failedWriter 写入总是失败
+type failedWriter struct{}
+
+func (failedWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestReplayConnector(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "session.rec")
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	recorder := NewRecorder(&messageConnector{messages: []*Message{
+		{Id: 1, Entries: testEntries()},
+		{Id: -1},
+		{Id: 2, Entries: testEntries()[:2]},
+	}}, file)
+	for i := 0; i < 4; i++ {
+		if _, err = recorder.GetWithoutAck(ctx, 100, time.Second); err != nil {
+			t.Fatalf("GetWithoutAck() error = %v", err)
+		}
+	}
+	file.Close()
+
+	connector := NewReplayConnector(path)
+	if err = connector.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+
+	get := func(wantId int64, wantEntries int) {
+		t.Helper()
+		message, err := connector.GetWithoutAck(ctx, 100, time.Second)
+		if err != nil {
+			t.Fatalf("GetWithoutAck() error = %v", err)
+		}
+		if message.Id != wantId || len(message.Entries) != wantEntries {
+			t.Fatalf("GetWithoutAck() = (%d, %d entries), want (%d, %d entries)",
+				message.Id, len(message.Entries), wantId, wantEntries)
+		}
+	}
+
+	get(1, len(testEntries()))
+	get(2, 2)
+	if err = connector.Ack(ctx, 2); !errors.Is(err, ErrBatchNotTracked) {
+		t.Errorf("Ack(2) error = %v, want %v", err, ErrBatchNotTracked)
+	}
+
+	// 回滚后重新投递
+	if err = connector.Rollback(ctx, 0); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	get(1, len(testEntries()))
+	if err = connector.Ack(ctx, 1); err != nil {
+		t.Fatalf("Ack(1) error = %v", err)
+	}
+
+	// 重新连接后从第一个未确认的批次开始
+	if err = connector.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	get(2, 2)
+	if err = connector.Ack(ctx, 2); err != nil {
+		t.Fatalf("Ack(2) error = %v", err)
+	}
+	get(-1, 0)
+}
+
+func TestReplayConnector_Rollback(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "session.rec")
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	recorder := NewRecorder(&messageConnector{messages: []*Message{
+		{Id: 1, Entries: testEntries()},
+		{Id: 2, Entries: testEntries()},
+		{Id: 3, Entries: testEntries()},
+	}}, file)
+	for i := 0; i < 3; i++ {
+		if _, err = recorder.GetWithoutAck(ctx, 100, time.Second); err != nil {
+			t.Fatalf("GetWithoutAck() error = %v", err)
+		}
+	}
+	file.Close()
+
+	connector := NewReplayConnector(path)
+	if err = connector.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	get := func(wantId int64) {
+		t.Helper()
+		message, err := connector.GetWithoutAck(ctx, 100, time.Second)
+		if err != nil {
+			t.Fatalf("GetWithoutAck() error = %v", err)
+		}
+		if message.Id != wantId {
+			t.Fatalf("GetWithoutAck() = batch %d, want %d", message.Id, wantId)
+		}
+	}
+	get(1)
+	get(2)
+	get(3)
+
+	// 只重新投递回滚的批次及之后的批次
+	if err = connector.Rollback(ctx, 2); err != nil {
+		t.Fatalf("Rollback(2) error = %v", err)
+	}
+	get(2)
+	if err = connector.Rollback(ctx, 3); !errors.Is(err, ErrBatchNotTracked) {
+		t.Errorf("Rollback(3) error = %v, want %v", err, ErrBatchNotTracked)
+	}
+	if err = connector.Ack(ctx, 1); err != nil {
+		t.Fatalf("Ack(1) error = %v", err)
+	}
+	if err = connector.Ack(ctx, 2); err != nil {
+		t.Fatalf("Ack(2) error = %v", err)
+	}
+	get(3)
+}
+
+func TestRecorder_WriteFailed(t *testing.T) {
+	ctx := context.Background()
+	recorder := NewRecorder(&messageConnector{messages: []*Message{
+		{Id: 1, Entries: testEntries()},
+		{Id: 2, Entries: testEntries()},
+	}}, failedWriter{}, WithRecorderLogger(QuietLogger()))
+
+	// Get返回时批次已经确认，录制失败不能丢掉消息
+	message, err := recorder.Get(ctx, 100, time.Second)
+	if err != nil || message.Id != 1 {
+		t.Errorf("Get() = (%v, %v), want batch 1", message, err)
+	}
+	if _, err = recorder.GetWithoutAck(ctx, 100, time.Second); err == nil {
+		t.Error("GetWithoutAck() error = nil, want write error")
+	}
+}
+
+func TestRecorder_Raw(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "session.rec")
+
+	header := newTestHeader(EventType_INSERT)
+	header.TableName = "orders"
+	orders := newRowDataEntry(header, &RowChange{
+		EventTypePresent: &RowChange_EventType{EventType: EventType_INSERT},
+		RowDatas:         []*RowData{{AfterColumns: []*Column{newTestColumn(0, "id", true, "1", true)}}},
+	})
+	server := newFakeCanalServer(t, append(testEntries(), orders))
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	// 客户端过滤掉orders，录制的仍是服务端发送的全部Entry
+	connector := NewSimpleConnector(server.Address(), "example", WithFilter(`test\.user`), WithLogger(QuietLogger()))
+	if err = connector.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer connector.Disconnect(ctx)
+	message, err := NewRecorder(connector, file).GetWithoutAck(ctx, 100, time.Second)
+	if err != nil {
+		t.Fatalf("GetWithoutAck() error = %v", err)
+	}
+	file.Close()
+	if len(message.Entries) != len(testEntries()) {
+		t.Fatalf("GetWithoutAck() = %d entries, want %d", len(message.Entries), len(testEntries()))
+	}
+
+	replay := NewReplayConnector(path)
+	if err = replay.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	if message, err = replay.GetWithoutAck(ctx, 100, time.Second); err != nil {
+		t.Fatalf("GetWithoutAck() error = %v", err)
+	}
+	if len(message.Entries) != len(testEntries())+1 {
+		t.Errorf("replay = %d entries, want %d", len(message.Entries), len(testEntries())+1)
+	}
+}
+
+func TestReplayConnector_Speed(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "session.rec")
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	recorder := NewRecorder(&messageConnector{messages: []*Message{
+		{Id: 1, Entries: testEntries()},
+		{Id: 2, Entries: testEntries()},
+	}}, file)
+	for i := 0; i < 2; i++ {
+		if i > 0 {
+			time.Sleep(50 * time.Millisecond)
+		}
+		if _, err = recorder.GetWithoutAck(ctx, 100, time.Second); err != nil {
+			t.Fatalf("GetWithoutAck() error = %v", err)
+		}
+	}
+	file.Close()
+
+	// 0.1倍速，第二个批次等待约500ms
+	connector := NewReplayConnector(path, WithReplaySpeed(0.1))
+	if err = connector.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	if _, err = connector.GetWithoutAck(ctx, 100, time.Second); err != nil {
+		t.Fatalf("GetWithoutAck() error = %v", err)
+	}
+
+	done := make(chan *Message, 1)
+	go func() {
+		message, _ := connector.GetWithoutAck(ctx, 100, time.Second)
+		done <- message
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// 等待下一个批次时确认不被阻塞
+	start := time.Now()
+	if err = connector.Ack(ctx, 1); err != nil {
+		t.Fatalf("Ack(1) error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Ack() blocked for %v", elapsed)
+	}
+	if message := <-done; message == nil || message.Id != 2 {
+		t.Errorf("GetWithoutAck() = %v, want batch 2", message)
+	}
+}
@@ -26,6 +26,8 @@ type simpleConnector struct {
 	config         *ConnectorConfig
 	address        string
 	clientIdentity ClientIdentity
+	filter         *Filter
+	logger         *slog.Logger
 }
 
 // NewSimpleConnector 新建简单连接器
@@ -38,19 +40,32 @@ func NewSimpleConnector(address string, destination string, opts ...Option) Conn
 		opt(config)
 	}
 
+	// 选项有误时filter为nil，错误在Connect时返回
+	filter, _ := config.newFilter(config.Filter)
+
 	return &simpleConnector{
 		config:  config,
 		address: address,
+		filter:  filter,
 		clientIdentity: ClientIdentity{
 			Destination: destination,
-			ClientId:    ClientIdDefault,
+			ClientId:    config.ClientId,
 			Filter:      "",
 		},
+		logger: loggerOrDefault(config.Logger).With(
+			slog.String(LogKeyDestination, destination),
+			slog.Int(LogKeyClientId, config.ClientId),
+			slog.String(LogKeyAddress, address),
+		),
 	}
 }
 
 // Connect 连接到server
 func (c *simpleConnector) Connect(ctx context.Context) error {
+	if c.config.err != nil {
+		return c.config.err
+	}
+
 	if c.connected {
 		return nil
 	}
@@ -109,7 +124,9 @@ func (c *simpleConnector) doConnect(ctx context.Context) error {
 	}
 
 	if packet.GetType() != canal.PacketType_ACK {
-		slog.ErrorContext(ctx, "expect packet type but found other type")
+		c.logger.ErrorContext(ctx, "expect packet type but found other type",
+			slog.String("packetType", packet.GetType().String()),
+		)
 		return ErrExpectedPacketType
 	}
 
@@ -123,9 +140,7 @@ func (c *simpleConnector) doConnect(ctx context.Context) error {
 
 	c.connected = true
 
-	slog.InfoContext(ctx, "connected",
-		slog.String("destination", c.clientIdentity.Destination),
-	)
+	c.logger.InfoContext(ctx, "connected")
 
 	return nil
 }
@@ -157,13 +172,18 @@ func (c *simpleConnector) Subscribe(ctx context.Context, filter string) error {
 		return nil
 	}
 
+	clientFilter, err := c.config.newFilter(filter)
+	if err != nil {
+		return err
+	}
+
 	data := marshalPacketIgnoreError(canal.PacketType_SUBSCRIPTION, &canal.Sub{
 		Destination: c.clientIdentity.Destination,
 		ClientId:    strconv.Itoa(c.clientIdentity.ClientId),
 		Filter:      filter,
 	})
 
-	if err := c.writeWithHeader(data); err != nil {
+	if err = c.writeWithHeader(data); err != nil {
 		return err
 	}
 
@@ -182,11 +202,9 @@ func (c *simpleConnector) Subscribe(ctx context.Context, filter string) error {
 	}
 
 	c.clientIdentity.Filter = filter
+	c.filter = clientFilter
 
-	slog.InfoContext(ctx, "subscribe",
-		slog.String("destination", c.clientIdentity.Destination),
-		slog.String("filter", filter),
-	)
+	c.logger.InfoContext(ctx, "subscribe", slog.String("filter", filter))
 
 	return nil
 }
@@ -225,6 +243,14 @@ func (c *simpleConnector) GetWithoutAck(ctx context.Context, batchSize int32, ti
 	if err != nil {
 		return nil, err
 	}
+
+	// 服务端可能忽略filter，客户端再过滤一次
+	if c.filter != nil {
+		if err = c.filter.filterMessage(message); err != nil {
+			return nil, err
+		}
+	}
+
 	return message, nil
 }
 
@@ -346,7 +372,7 @@ func (c *simpleConnector) Disconnect(ctx context.Context) error {
 	c.connected = false
 
 	if err := c.conn.Close(); err != nil {
-		slog.ErrorContext(ctx, "failed to disconnect",
+		c.logger.ErrorContext(ctx, "failed to disconnect",
 			slog.Any("error", err))
 		return err
 	}
@@ -0,0 +1,296 @@
+package icanal
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/kalvinzhang/icanal/protocol/canal"
+)
+
+// fakeCanalServer 按canal协议依次投递给定批次的服务端
+//
+// 未确认的批次在回滚或者连接断开后重新投递；回滚不区分batch id，与canal一致
+type fakeCanalServer struct {
+	listener    net.Listener
+	mutex       sync.Mutex
+	pending     [][]*Entry // 未投递的批次
+	outstanding []fakeBatch
+	acked       []int64
+	nextId      int64
+	connections int
+	conns       []net.Conn
+	clientId    string // 最后一次拉取的client id
+}
+
+type fakeBatch struct {
+	id      int64
+	entries []*Entry
+}
+
+func newFakeCanalServer(t *testing.T, batches ...[]*Entry) *fakeCanalServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	s := &fakeCanalServer{listener: listener, pending: batches, nextId: 1}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *fakeCanalServer) Address() string {
+	return s.listener.Addr().String()
+}
+
+// Push 追加待投递的批次
+func (s *fakeCanalServer) Push(batches ...[]*Entry) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.pending = append(s.pending, batches...)
+}
+
+// Acked 已确认的batch id
+func (s *fakeCanalServer) Acked() []int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]int64(nil), s.acked...)
+}
+
+// ClientId 最后一次拉取的client id
+func (s *fakeCanalServer) ClientId() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.clientId
+}
+
+// Kick 断开所有连接
+func (s *fakeCanalServer) Kick() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+// Connections 建立过的连接数
+func (s *fakeCanalServer) Connections() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.connections
+}
+
+func (s *fakeCanalServer) serve(conn net.Conn) {
+	defer conn.Close()
+	defer s.rollback()
+
+	s.mutex.Lock()
+	s.connections++
+	s.conns = append(s.conns, conn)
+	s.mutex.Unlock()
+
+	if err := writeFakePacket(conn, canal.PacketType_HANDSHAKE, &canal.Handshake{Seeds: []byte("12345678")}); err != nil {
+		return
+	}
+	for {
+		packet, err := readFakePacket(conn)
+		if err != nil {
+			return
+		}
+
+		switch packet.GetType() {
+		case canal.PacketType_CLIENTAUTHENTICATION, canal.PacketType_SUBSCRIPTION, canal.PacketType_UNSUBSCRIPTION:
+			err = writeFakePacket(conn, canal.PacketType_ACK, &canal.Ack{})
+		case canal.PacketType_GET:
+			get := &canal.Get{}
+			_ = proto.Unmarshal(packet.GetBody(), get)
+			s.mutex.Lock()
+			s.clientId = get.GetClientId()
+			s.mutex.Unlock()
+			err = writeFakePacket(conn, canal.PacketType_MESSAGES, s.next())
+		case canal.PacketType_CLIENTACK:
+			ack := &canal.ClientAck{}
+			_ = proto.Unmarshal(packet.GetBody(), ack)
+			s.ack(ack.GetBatchId())
+		case canal.PacketType_CLIENTROLLBACK:
+			s.rollback()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *fakeCanalServer) next() *canal.Messages {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.pending) == 0 {
+		return &canal.Messages{BatchId: -1}
+	}
+
+	batch := fakeBatch{id: s.nextId, entries: s.pending[0]}
+	s.nextId++
+	s.pending = s.pending[1:]
+	s.outstanding = append(s.outstanding, batch)
+
+	messages := &canal.Messages{BatchId: batch.id}
+	for _, entry := range batch.entries {
+		data, _ := proto.Marshal(entry)
+		messages.Messages = append(messages.Messages, data)
+	}
+	return messages
+}
+
+func (s *fakeCanalServer) ack(batchId int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i, batch := range s.outstanding {
+		if batch.id == batchId {
+			s.outstanding = append(s.outstanding[:i], s.outstanding[i+1:]...)
+			s.acked = append(s.acked, batchId)
+			return
+		}
+	}
+}
+
+func (s *fakeCanalServer) rollback() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	batches := make([][]*Entry, 0, len(s.outstanding)+len(s.pending))
+	for _, batch := range s.outstanding {
+		batches = append(batches, batch.entries)
+	}
+	s.pending = append(batches, s.pending...)
+	s.outstanding = nil
+}
+
+func writeFakePacket(conn net.Conn, packetType canal.PacketType, payload proto.Message) error {
+	body, err := proto.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	data, err := proto.Marshal(&canal.Packet{
+		VersionPresent: &canal.Packet_Version{Version: CanalVersion1},
+		Type:           packetType,
+		Body:           body,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err = conn.Write(generateWriteHeader(len(data))); err != nil {
+		return err
+	}
+	_, err = conn.Write(data)
+	return err
+}
+
+func readFakePacket(conn net.Conn) (*canal.Packet, error) {
+	header := make([]byte, lengthOfHeader)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	data := make([]byte, binary.BigEndian.Uint32(header))
+	if _, err := io.ReadFull(conn, data); err != nil {
+		return nil, err
+	}
+	packet := &canal.Packet{}
+	if err := proto.Unmarshal(data, packet); err != nil {
+		return nil, errors.Join(ErrUnmarshal, err)
+	}
+	return packet, nil
+}
+
+func TestSimpleConnector(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeCanalServer(t, testEntries(), testEntries()[:2])
+
+	connector := NewSimpleConnector(server.Address(), "example", WithUsername("canal"), WithPassword("canal"))
+	if err := connector.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer connector.Disconnect(ctx)
+	if err := connector.Subscribe(ctx, `.*\..*`); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	get := func(wantId int64, wantEntries int) {
+		t.Helper()
+		message, err := connector.GetWithoutAck(ctx, 100, time.Second)
+		if err != nil {
+			t.Fatalf("GetWithoutAck() error = %v", err)
+		}
+		if message.Id != wantId || len(message.Entries) != wantEntries {
+			t.Fatalf("GetWithoutAck() = (%d, %d entries), want (%d, %d entries)",
+				message.Id, len(message.Entries), wantId, wantEntries)
+		}
+	}
+
+	get(1, len(testEntries()))
+	if err := connector.Rollback(ctx, 1); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	get(2, len(testEntries()))
+	if err := connector.Ack(ctx, 2); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	get(3, 2)
+}
+
+func TestSimpleConnector_ClientId(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeCanalServer(t, testEntries())
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		want    string
+		wantErr error
+	}{
+		{name: "default", want: "1001"},
+		{name: "custom", opts: []Option{WithClientId(1002)}, want: "1002"},
+		{name: "invalid", opts: []Option{WithClientId(40000)}, wantErr: ErrInvalidClientId},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connector := NewSimpleConnector(server.Address(), "example", append(tt.opts, WithLogger(QuietLogger()))...)
+			err := connector.Connect(ctx)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Connect() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer connector.Disconnect(ctx)
+
+			if _, err = connector.GetWithoutAck(ctx, 100, time.Second); err != nil {
+				t.Fatalf("GetWithoutAck() error = %v", err)
+			}
+			if got := server.ClientId(); got != tt.want {
+				t.Errorf("client id = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package icanal
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	IdleIntervalDefault = 300 * time.Millisecond // 默认无数据时的等待时间
+)
+
+// Handler 消息处理函数；返回错误时整批回滚
+type Handler func(ctx context.Context, message *Message) error
+
+// ConsumerConfig 消费者配置
+type ConsumerConfig struct {
+	Destination  string        // 批次span的destination属性，为空时取连接器的destination
+	Filter       string        // 订阅的filter，为空时不主动订阅
+	BatchSize    int32         // 每次拉取的数量
+	Timeout      time.Duration // 每次拉取的超时时间
+	IdleInterval time.Duration // 无数据时的等待时间
+	// TracerProvider 每个批次的span，为nil时使用全局TracerProvider
+	TracerProvider trace.TracerProvider
+	Logger         *slog.Logger // 日志，为nil时使用slog.Default()
+}
+
+func getDefaultConsumerConfig() *ConsumerConfig {
+	return &ConsumerConfig{
+		BatchSize:    BatchSizeDefault,
+		Timeout:      time.Second,
+		IdleInterval: IdleIntervalDefault,
+	}
+}
+
+type ConsumerOption func(*ConsumerConfig)
+
+func WithConsumerFilter(filter string) ConsumerOption {
+	return func(c *ConsumerConfig) {
+		c.Filter = filter
+	}
+}
+
+func WithBatchSize(batchSize int32) ConsumerOption {
+	return func(c *ConsumerConfig) {
+		c.BatchSize = batchSize
+	}
+}
+
+func WithGetTimeout(timeout time.Duration) ConsumerOption {
+	return func(c *ConsumerConfig) {
+		c.Timeout = timeout
+	}
+}
+
+func WithIdleInterval(idleInterval time.Duration) ConsumerOption {
+	return func(c *ConsumerConfig) {
+		c.IdleInterval = idleInterval
+	}
+}
+
+// WithConsumerDestination 设置批次span的destination属性
+func WithConsumerDestination(destination string) ConsumerOption {
+	return func(c *ConsumerConfig) {
+		c.Destination = destination
+	}
+}
+
+// WithTracerProvider 设置批次span的TracerProvider
+func WithTracerProvider(tracerProvider trace.TracerProvider) ConsumerOption {
+	return func(c *ConsumerConfig) {
+		c.TracerProvider = tracerProvider
+	}
+}
+
+// WithConsumerLogger 设置日志
+func WithConsumerLogger(logger *slog.Logger) ConsumerOption {
+	return func(c *ConsumerConfig) {
+		c.Logger = logger
+	}
+}
+
+// Consumer 消费者；负责单个destination的拉取、处理、确认循环
+type Consumer struct {
+	connector Connector
+	handler   Handler
+	config    *ConsumerConfig
+	logger    *slog.Logger
+}
+
+// NewConsumer 新建消费者
+func NewConsumer(connector Connector, handler Handler, opts ...ConsumerOption) *Consumer {
+	config := getDefaultConsumerConfig()
+
+	// 应用所有选项
+	for _, opt := range opts {
+		opt(config)
+	}
+	if config.Destination == "" {
+		config.Destination = connectorDestination(connector)
+	}
+
+	return &Consumer{
+		connector: connector,
+		handler:   handler,
+		config:    config,
+		logger:    loggerOrDefault(config.Logger),
+	}
+}
+
+// Run 连接并循环消费，直到ctx结束或者出现错误；ctx结束时返回nil
+func (c *Consumer) Run(ctx context.Context) error {
+	if err := c.connector.Connect(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		if err := c.connector.Disconnect(context.WithoutCancel(ctx)); err != nil {
+			c.logger.WarnContext(ctx, "failed to disconnect", slog.Any("error", err))
+		}
+	}()
+
+	if c.config.Filter != "" {
+		if err := c.connector.Subscribe(ctx, c.config.Filter); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		if err := c.consume(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// consume 拉取并处理一批消息；处理成功确认，失败回滚
+//
+// 非空批次创建一个从拉取开始到确认结束的span，处理函数可以通过ctx创建子span
+func (c *Consumer) consume(ctx context.Context) (err error) {
+	start := time.Now()
+	message, err := c.connector.GetWithoutAck(ctx, c.config.BatchSize, c.config.Timeout)
+	if err != nil {
+		return err
+	}
+
+	if message == nil || message.Id == -1 {
+		select {
+		case <-ctx.Done():
+		case <-time.After(c.config.IdleInterval):
+		}
+		return nil
+	}
+
+	attributes := messageAttributes(message)
+	if c.config.Destination != "" {
+		attributes = append(attributes, AttributeDestination.String(c.config.Destination))
+	}
+	ctx, span := tracer(c.config.TracerProvider).Start(ctx, "icanal.batch",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(attributes...),
+	)
+	defer func() {
+		if span.IsRecording() {
+			if latency, ok := commitLatency(message); ok {
+				span.SetAttributes(AttributeCommitLatency.Int64(latency.Milliseconds()))
+			}
+		}
+		endSpan(span, err)
+	}()
+
+	if err = c.handler(ctx, message); err != nil {
+		c.logger.WarnContext(ctx, "handle message error, rollback",
+			slog.Int64(LogKeyBatchId, message.Id),
+			slog.Any("error", err),
+		)
+		if rbErr := c.connector.Rollback(ctx, message.Id); rbErr != nil {
+			return rbErr
+		}
+		return err
+	}
+
+	return c.connector.Ack(ctx, message.Id)
+}
+
+// connectorDestination 包装前的连接器的destination；回放等没有destination的连接器返回空串
+func connectorDestination(connector Connector) string {
+	if c, ok := connector.(*tracingConnector); ok {
+		return c.destination
+	}
+	switch c := unwrapConnector(connector).(type) {
+	case *simpleConnector:
+		return c.clientIdentity.Destination
+	case *clusterConnector:
+		return c.destination
+	}
+	return ""
+}
@@ -0,0 +1,484 @@
+package icanal
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Debezium的op取值；canal只有增量数据，不会产生快照读取(r)
+const (
+	DebeziumOpCreate = "c"
+	DebeziumOpUpdate = "u"
+	DebeziumOpDelete = "d"
+)
+
+const debeziumDateTimeLayout = "2006-01-02 15:04:05.999999999"
+
+// DebeziumEvent Debezium格式的变更事件；Schema为nil时只输出payload，等同于schemas.enable=false
+type DebeziumEvent struct {
+	Schema    *DebeziumSchema
+	Payload   DebeziumPayload
+	Key       map[string]any // 主键列，用作Kafka记录的key
+	Tombstone bool           // 删除后的墓碑消息，值为null，用于Kafka日志压缩
+}
+
+// MarshalJSON 墓碑消息输出null；有schema时输出{"schema":...,"payload":...}，否则只输出payload
+func (e *DebeziumEvent) MarshalJSON() ([]byte, error) {
+	if e.Tombstone {
+		return []byte("null"), nil
+	}
+	if e.Schema == nil {
+		return json.Marshal(e.Payload)
+	}
+	return json.Marshal(struct {
+		Schema  *DebeziumSchema `json:"schema"`
+		Payload DebeziumPayload `json:"payload"`
+	}{e.Schema, e.Payload})
+}
+
+// DebeziumPayload Debezium的变更信封
+type DebeziumPayload struct {
+	Before map[string]any `json:"before"`
+	After  map[string]any `json:"after"`
+	Source DebeziumSource `json:"source"`
+	Op     string         `json:"op"`
+	TsMs   int64          `json:"ts_ms"`
+}
+
+// DebeziumSource Debezium MySQL connector的source信息
+type DebeziumSource struct {
+	Version   string  `json:"version"`
+	Connector string  `json:"connector"`
+	Name      string  `json:"name"`
+	TsMs      int64   `json:"ts_ms"`
+	Snapshot  string  `json:"snapshot"`
+	Db        string  `json:"db"`
+	Table     string  `json:"table"`
+	ServerId  int64   `json:"server_id"`
+	Gtid      *string `json:"gtid"`
+	File      string  `json:"file"`
+	Pos       int64   `json:"pos"`
+	Row       int     `json:"row"`
+	Query     *string `json:"query"`
+}
+
+// DebeziumSchema Kafka Connect格式的schema
+type DebeziumSchema struct {
+	Type       string            `json:"type"`
+	Fields     []DebeziumSchema  `json:"fields,omitempty"`
+	Optional   bool              `json:"optional"`
+	Name       string            `json:"name,omitempty"`
+	Parameters map[string]string `json:"parameters,omitempty"`
+	Field      string            `json:"field,omitempty"`
+}
+
+// DebeziumConverter 把canal的行变更转换为Debezium事件
+//
+// 类型映射与Debezium MySQL connector的默认配置一致：decimal和无符号bigint按字符串输出(decimal.handling.mode=string)，
+// date为距epoch的天数，datetime为毫秒(精度大于3时为微秒)，timestamp为ISO-8601字符串，time为微秒，
+// bit(1)为布尔值，其他bit为小端字节，二进制列为字节(JSON中为base64)。
+//
+// canal不携带列的NOT NULL约束：schema中主键列和WithDebeziumNotNull声明的列为必填，其他列为optional；
+// 行中出现NULL的列总是optional。
+type DebeziumConverter struct {
+	serverName string
+	withSchema bool
+	tombstones bool
+	location   *time.Location
+	notNull    func(schema, table, column string) bool
+}
+
+type DebeziumOption func(*DebeziumConverter)
+
+// WithDebeziumSchema 是否输出schema部分
+func WithDebeziumSchema(withSchema bool) DebeziumOption {
+	return func(c *DebeziumConverter) {
+		c.withSchema = withSchema
+	}
+}
+
+// WithDebeziumTombstones ConvertEntry是否在每个删除事件后追加墓碑消息，对应Debezium的tombstones.on.delete
+func WithDebeziumTombstones(tombstones bool) DebeziumOption {
+	return func(c *DebeziumConverter) {
+		c.tombstones = tombstones
+	}
+}
+
+// WithDebeziumNotNull 声明NOT NULL的列，schema中这些列不是optional
+func WithDebeziumNotNull(notNull func(schema, table, column string) bool) DebeziumOption {
+	return func(c *DebeziumConverter) {
+		c.notNull = notNull
+	}
+}
+
+// WithDebeziumLocation mysql服务端时区，用于转换timestamp列；默认UTC
+func WithDebeziumLocation(location *time.Location) DebeziumOption {
+	return func(c *DebeziumConverter) {
+		c.location = location
+	}
+}
+
+// NewDebeziumConverter 新建Debezium转换器；serverName对应Debezium的逻辑服务名(topic.prefix)
+func NewDebeziumConverter(serverName string, opts ...DebeziumOption) *DebeziumConverter {
+	c := &DebeziumConverter{
+		serverName: serverName,
+		location:   time.UTC,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// ConvertEntry 转换一个ROWDATA类型的Entry；DDL和非行变更返回空
+func (c *DebeziumConverter) ConvertEntry(entry *Entry) ([]*DebeziumEvent, error) {
+	if entry.GetEntryType() != EntryType_ROWDATA {
+		return nil, nil
+	}
+
+	rowChange, err := ParseRowChange(entry)
+	if err != nil {
+		return nil, err
+	}
+	if rowChange.GetIsDdl() {
+		return nil, nil
+	}
+
+	var events []*DebeziumEvent
+	for i, rowData := range rowChange.GetRowDatas() {
+		events = append(events, c.ConvertEvents(ChangeEvent{
+			Header:    entry.GetHeader(),
+			EventType: rowChange.GetEventType(),
+			Sql:       rowChange.GetSql(),
+			RowData:   rowData,
+			Row:       i,
+		})...)
+	}
+
+	return events, nil
+}
+
+// ConvertEvents 转换一个变更事件，开启墓碑时在删除后追加墓碑消息；
+// 与Debezium一致，修改了主键的UPDATE转换为旧主键的删除(及墓碑)和新主键的创建
+func (c *DebeziumConverter) ConvertEvents(event ChangeEvent) []*DebeziumEvent {
+	changes := []ChangeEvent{event}
+	if event.keyChanged() {
+		changes = splitKeyChange(event)
+	}
+
+	var events []*DebeziumEvent
+	for _, change := range changes {
+		converted := c.Convert(change)
+		if converted == nil {
+			continue
+		}
+		events = append(events, converted)
+		if c.tombstones {
+			if tombstone := c.Tombstone(change); tombstone != nil {
+				events = append(events, tombstone)
+			}
+		}
+	}
+
+	return events
+}
+
+// Convert 转换一个变更事件；DDL和INSERT/UPDATE/DELETE以外的事件返回nil。不拆分修改主键的UPDATE，需要时使用ConvertEvents
+func (c *DebeziumConverter) Convert(event ChangeEvent) *DebeziumEvent {
+	if event.IsDdl {
+		return nil
+	}
+
+	payload := DebeziumPayload{
+		TsMs: time.Now().UnixMilli(),
+	}
+	switch event.EventType {
+	case EventType_INSERT:
+		payload.Op = DebeziumOpCreate
+		payload.After = c.row(event.RowData.GetAfterColumns())
+	case EventType_UPDATE:
+		payload.Op = DebeziumOpUpdate
+		payload.Before = c.row(event.RowData.GetBeforeColumns())
+		payload.After = c.row(event.RowData.GetAfterColumns())
+	case EventType_DELETE:
+		payload.Op = DebeziumOpDelete
+		payload.Before = c.row(event.RowData.GetBeforeColumns())
+	default:
+		return nil
+	}
+
+	header := event.Header
+	payload.Source = DebeziumSource{
+		Version:   "icanal",
+		Connector: "mysql",
+		Name:      c.serverName,
+		TsMs:      header.GetExecuteTime(),
+		Snapshot:  "false",
+		Db:        header.GetSchemaName(),
+		Table:     header.GetTableName(),
+		ServerId:  header.GetServerId(),
+		File:      header.GetLogfileName(),
+		Pos:       header.GetLogfileOffset(),
+		Row:       event.Row,
+	}
+	if gtid := header.GetGtid(); gtid != "" {
+		payload.Source.Gtid = &gtid
+	}
+	if event.Sql != "" {
+		payload.Source.Query = &event.Sql
+	}
+
+	result := &DebeziumEvent{Payload: payload, Key: c.key(event)}
+	if c.withSchema {
+		result.Schema = c.schema(event)
+	}
+
+	return result
+}
+
+// Tombstone 删除事件之后的墓碑消息，key与删除事件相同；其他事件返回nil
+func (c *DebeziumConverter) Tombstone(event ChangeEvent) *DebeziumEvent {
+	if event.IsDdl || event.EventType != EventType_DELETE {
+		return nil
+	}
+	return &DebeziumEvent{Key: c.key(event), Tombstone: true}
+}
+
+// key 主键列；UPDATE取变更后的主键
+func (c *DebeziumConverter) key(event ChangeEvent) map[string]any {
+	keys := event.KeyColumns()
+	if len(keys) == 0 {
+		return nil
+	}
+	return c.row(keys)
+}
+
+func (c *DebeziumConverter) row(columns []*Column) map[string]any {
+	row := make(map[string]any, len(columns))
+	for _, column := range columns {
+		row[column.GetName()] = c.value(column)
+	}
+	return row
+}
+
+// value 按Debezium的类型映射转换列值；解析失败时保留原始字符串
+func (c *DebeziumConverter) value(column *Column) any {
+	if column.GetIsNull() {
+		return nil
+	}
+
+	value := column.GetValue()
+	t := parseMysqlType(column.GetMysqlType())
+	switch t.base {
+	case "date":
+		if v, err := time.ParseInLocation(time.DateOnly, value, time.UTC); err == nil {
+			return int32(v.Unix() / 86400)
+		}
+	case "datetime":
+		if v, err := time.ParseInLocation(debeziumDateTimeLayout, value, time.UTC); err == nil {
+			if t.length > 3 {
+				return v.UnixMicro()
+			}
+			return v.UnixMilli()
+		}
+	case "timestamp":
+		if v, err := time.ParseInLocation(debeziumDateTimeLayout, value, c.location); err == nil {
+			return v.UTC().Format(time.RFC3339Nano)
+		}
+	case "time":
+		if v, ok := parseMysqlTime(value); ok {
+			return v
+		}
+	case "decimal", "numeric":
+		return value
+	case "bigint":
+		// 无符号bigint可能超出int64，与decimal一样按字符串输出
+		if t.unsigned {
+			return value
+		}
+	case "bit":
+		if v, err := strconv.ParseUint(value, 10, 64); err == nil {
+			if t.length <= 1 {
+				return v != 0
+			}
+			// io.debezium.data.Bits：小端字节
+			data := make([]byte, (t.length+7)/8)
+			for i := range data {
+				data[i] = byte(v >> (8 * i))
+			}
+			return data
+		}
+	default:
+		if t.isBinary() {
+			return latin1Bytes(value)
+		}
+	}
+
+	return columnValue(column)
+}
+
+// parseMysqlTime 把"[-]HHH:MM:SS[.ffffff]"转换为微秒
+func parseMysqlTime(value string) (int64, bool) {
+	negative := len(value) > 0 && value[0] == '-'
+	if negative {
+		value = value[1:]
+	}
+
+	var parts [3]int64
+	clock, fraction, _ := strings.Cut(value, ".")
+	for i := range parts {
+		var part string
+		part, clock, _ = strings.Cut(clock, ":")
+		v, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		parts[i] = v
+	}
+
+	micros := ((parts[0]*60+parts[1])*60 + parts[2]) * 1_000_000
+	if fraction != "" {
+		fraction = (fraction + "000000")[:6]
+		v, err := strconv.ParseInt(fraction, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		micros += v
+	}
+
+	if negative {
+		micros = -micros
+	}
+	return micros, true
+}
+
+// schema 生成Kafka Connect格式的信封schema
+func (c *DebeziumConverter) schema(event ChangeEvent) *DebeziumSchema {
+	prefix := c.serverName + "." + event.SchemaName() + "." + event.TableName()
+
+	columns := event.RowData.GetAfterColumns()
+	if len(columns) == 0 {
+		columns = event.RowData.GetBeforeColumns()
+	}
+
+	nulls := make(map[string]bool)
+	for _, column := range append(event.RowData.GetBeforeColumns(), event.RowData.GetAfterColumns()...) {
+		if column.GetIsNull() {
+			nulls[column.GetName()] = true
+		}
+	}
+
+	fields := make([]DebeziumSchema, 0, len(columns))
+	for _, column := range columns {
+		field := debeziumFieldSchema(parseMysqlType(column.GetMysqlType()))
+		field.Field = column.GetName()
+		field.Optional = nulls[column.GetName()] || !c.isNotNull(event, column)
+		fields = append(fields, field)
+	}
+
+	value := func(name string) DebeziumSchema {
+		return DebeziumSchema{Type: "struct", Fields: fields, Optional: true, Name: prefix + ".Value", Field: name}
+	}
+
+	return &DebeziumSchema{
+		Type: "struct",
+		Name: prefix + ".Envelope",
+		Fields: []DebeziumSchema{
+			value("before"),
+			value("after"),
+			{
+				Type: "struct",
+				Name: "io.debezium.connector.mysql.Source",
+				Fields: []DebeziumSchema{
+					{Type: "string", Field: "version"},
+					{Type: "string", Field: "connector"},
+					{Type: "string", Field: "name"},
+					{Type: "int64", Field: "ts_ms"},
+					{Type: "string", Optional: true, Field: "snapshot"},
+					{Type: "string", Field: "db"},
+					{Type: "string", Optional: true, Field: "table"},
+					{Type: "int64", Field: "server_id"},
+					{Type: "string", Optional: true, Field: "gtid"},
+					{Type: "string", Field: "file"},
+					{Type: "int64", Field: "pos"},
+					{Type: "int32", Field: "row"},
+					{Type: "string", Optional: true, Field: "query"},
+				},
+				Field: "source",
+			},
+			{Type: "string", Field: "op"},
+			{Type: "int64", Optional: true, Field: "ts_ms"},
+		},
+	}
+}
+
+// isNotNull 列是否NOT NULL；mysql的主键列总是NOT NULL
+func (c *DebeziumConverter) isNotNull(event ChangeEvent, column *Column) bool {
+	if column.GetIsKey() {
+		return true
+	}
+	return c.notNull != nil && c.notNull(event.SchemaName(), event.TableName(), column.GetName())
+}
+
+// debeziumFieldSchema 列类型对应的Kafka Connect类型
+func debeziumFieldSchema(t mysqlType) DebeziumSchema {
+	switch t.base {
+	case "tinyint":
+		if t.unsigned {
+			return DebeziumSchema{Type: "int16"}
+		}
+		return DebeziumSchema{Type: "int8"}
+	case "smallint":
+		if t.unsigned {
+			return DebeziumSchema{Type: "int32"}
+		}
+		return DebeziumSchema{Type: "int16"}
+	case "mediumint":
+		return DebeziumSchema{Type: "int32"}
+	case "int", "integer":
+		if t.unsigned {
+			return DebeziumSchema{Type: "int64"}
+		}
+		return DebeziumSchema{Type: "int32"}
+	case "bigint":
+		if t.unsigned {
+			return DebeziumSchema{Type: "string"}
+		}
+		return DebeziumSchema{Type: "int64"}
+	case "float":
+		return DebeziumSchema{Type: "float"}
+	case "double", "real":
+		return DebeziumSchema{Type: "double"}
+	case "year":
+		return DebeziumSchema{Type: "int32", Name: "io.debezium.time.Year"}
+	case "date":
+		return DebeziumSchema{Type: "int32", Name: "io.debezium.time.Date"}
+	case "datetime":
+		if t.length > 3 {
+			return DebeziumSchema{Type: "int64", Name: "io.debezium.time.MicroTimestamp"}
+		}
+		return DebeziumSchema{Type: "int64", Name: "io.debezium.time.Timestamp"}
+	case "timestamp":
+		return DebeziumSchema{Type: "string", Name: "io.debezium.time.ZonedTimestamp"}
+	case "time":
+		return DebeziumSchema{Type: "int64", Name: "io.debezium.time.MicroTime"}
+	case "json":
+		return DebeziumSchema{Type: "string", Name: "io.debezium.data.Json"}
+	case "bit":
+		if t.length <= 1 {
+			return DebeziumSchema{Type: "boolean"}
+		}
+		return DebeziumSchema{
+			Type:       "bytes",
+			Name:       "io.debezium.data.Bits",
+			Parameters: map[string]string{"length": strconv.Itoa(t.length)},
+		}
+	}
+
+	if t.isBinary() {
+		return DebeziumSchema{Type: "bytes"}
+	}
+	return DebeziumSchema{Type: "string"}
+}
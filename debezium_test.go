@@ -0,0 +1,251 @@
+package icanal
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDebeziumConverter_Value(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	tests := []struct {
+		name      string
+		mysqlType string
+		value     string
+		location  *time.Location
+		want      any
+		wantJSON  string
+	}{
+		{"date", "date", "2020-05-13", nil, int32(18395), "18395"},
+		{"date before epoch", "date", "1969-12-31", nil, int32(-1), "-1"},
+		{"datetime", "datetime", "2020-05-13 12:38:35", nil, int64(1589373515000), "1589373515000"},
+		{"datetime millis", "datetime(3)", "2020-05-13 12:38:35.123", nil, int64(1589373515123), "1589373515123"},
+		{"datetime micros", "datetime(6)", "2020-05-13 12:38:35.123456", nil, int64(1589373515123456), "1589373515123456"},
+		{"timestamp utc", "timestamp", "2020-05-13 12:38:35", nil, "2020-05-13T12:38:35Z", `"2020-05-13T12:38:35Z"`},
+		{"timestamp with timezone", "timestamp(3)", "2020-05-13 20:38:35.5", shanghai, "2020-05-13T12:38:35.5Z", `"2020-05-13T12:38:35.5Z"`},
+		{"time", "time", "12:38:35", nil, int64(45515000000), "45515000000"},
+		{"time fraction", "time(6)", "838:59:59.000001", nil, int64(3020399000001), "3020399000001"},
+		{"time negative", "time", "-01:00:00", nil, int64(-3600000000), "-3600000000"},
+		{"decimal", "decimal(10,2)", "12345678.90", nil, "12345678.90", `"12345678.90"`},
+		{"bit(1)", "bit(1)", "1", nil, true, "true"},
+		{"bit(10)", "bit(10)", "513", nil, []byte{0x01, 0x02}, `"AQI="`},
+		{"binary", "varbinary(16)", "ÿ\u0000a", nil, []byte{0xff, 0x00, 'a'}, `"/wBh"`},
+		{"blob", "blob", "hi", nil, []byte("hi"), `"aGk="`},
+		{"unsigned bigint", "bigint(20) unsigned", "18446744073709551615", nil, "18446744073709551615", `"18446744073709551615"`},
+		{"unsigned int", "int(10) unsigned", "4294967295", nil, uint64(4294967295), "4294967295"},
+		{"invalid date kept", "date", "0000-00-00", nil, "0000-00-00", `"0000-00-00"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []DebeziumOption
+			if tt.location != nil {
+				opts = append(opts, WithDebeziumLocation(tt.location))
+			}
+			c := NewDebeziumConverter("dbserver1", opts...)
+
+			got := c.value(&Column{Name: "c", MysqlType: tt.mysqlType, Value: tt.value})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("value() = %#v, want %#v", got, tt.want)
+			}
+			data, err := json.Marshal(got)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			if string(data) != tt.wantJSON {
+				t.Errorf("json = %s, want %s", data, tt.wantJSON)
+			}
+		})
+	}
+
+	c := NewDebeziumConverter("dbserver1")
+	if got := c.value(&Column{MysqlType: "int", IsNullPresent: &Column_IsNull{IsNull: true}}); got != nil {
+		t.Errorf("value(NULL) = %v, want nil", got)
+	}
+}
+
+func TestDebeziumConverter_ConvertEntry(t *testing.T) {
+	c := NewDebeziumConverter("dbserver1", WithDebeziumSchema(true), WithDebeziumTombstones(true))
+
+	var events []*DebeziumEvent
+	for _, entry := range testEntries() {
+		converted, err := c.ConvertEntry(entry)
+		if err != nil {
+			t.Fatalf("ConvertEntry() error = %v", err)
+		}
+		events = append(events, converted...)
+	}
+
+	// INSERT两行、UPDATE、DELETE及其墓碑；DDL和事务头尾被忽略
+	var ops []string
+	for _, event := range events {
+		if event.Tombstone {
+			ops = append(ops, "tombstone")
+			continue
+		}
+		ops = append(ops, event.Payload.Op)
+	}
+	if want := []string{"c", "c", "u", "d", "tombstone"}; !reflect.DeepEqual(ops, want) {
+		t.Fatalf("ops = %v, want %v", ops, want)
+	}
+
+	if rows := []int{events[0].Payload.Source.Row, events[1].Payload.Source.Row}; !reflect.DeepEqual(rows, []int{0, 1}) {
+		t.Errorf("source.row = %v, want [0 1]", rows)
+	}
+
+	data, err := json.Marshal(events[1])
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var envelope struct {
+		Schema struct {
+			Name   string `json:"name"`
+			Fields []struct {
+				Field  string `json:"field"`
+				Fields []struct {
+					Field    string `json:"field"`
+					Type     string `json:"type"`
+					Optional bool   `json:"optional"`
+				} `json:"fields"`
+			} `json:"fields"`
+		} `json:"schema"`
+		Payload map[string]any `json:"payload"`
+	}
+	if err = json.Unmarshal(data, &envelope); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if envelope.Schema.Name != "dbserver1.test.user.Envelope" {
+		t.Errorf("schema.name = %q", envelope.Schema.Name)
+	}
+	var fields []string
+	for _, field := range envelope.Schema.Fields {
+		fields = append(fields, field.Field)
+	}
+	if want := []string{"before", "after", "source", "op", "ts_ms"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("schema fields = %v, want %v", fields, want)
+	}
+	optional := make(map[string]bool)
+	for _, field := range envelope.Schema.Fields[1].Fields {
+		optional[field.Field] = field.Optional
+	}
+	if want := map[string]bool{"id": false, "name": true, "age": true}; !reflect.DeepEqual(optional, want) {
+		t.Errorf("after optional = %v, want %v", optional, want)
+	}
+
+	payload := envelope.Payload
+	if payload["op"] != "c" || payload["before"] != nil {
+		t.Errorf("payload = %v, want op c without before", payload)
+	}
+	source, _ := payload["source"].(map[string]any)
+	wantSource := map[string]any{
+		"connector": "mysql", "name": "dbserver1", "db": "test", "table": "user",
+		"file": "mysql-bin.000001", "pos": float64(1024), "row": float64(1), "server_id": float64(1),
+		"ts_ms": float64(1589373515000), "gtid": "3e11fa47-71ca-11e1-9e33-c80aa9429562:23",
+	}
+	for key, want := range wantSource {
+		if source[key] != want {
+			t.Errorf("source.%s = %v, want %v", key, source[key], want)
+		}
+	}
+
+	tombstone := events[4]
+	data, err = json.Marshal(tombstone)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(data) != "null" {
+		t.Errorf("tombstone json = %s, want null", data)
+	}
+	if !reflect.DeepEqual(tombstone.Key, events[3].Key) || tombstone.Key["id"] != int64(2) {
+		t.Errorf("tombstone key = %v, want delete key %v", tombstone.Key, events[3].Key)
+	}
+
+	// 不输出schema时只有payload
+	data, err = json.Marshal(NewDebeziumConverter("dbserver1").Convert(ChangeEvent{
+		Header:    newTestHeader(EventType_DELETE),
+		EventType: EventType_DELETE,
+		RowData:   &RowData{BeforeColumns: []*Column{newTestColumn(0, "id", true, "2", false)}},
+	}))
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var plain map[string]any
+	if err = json.Unmarshal(data, &plain); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if _, ok := plain["schema"]; ok || plain["op"] != "d" || plain["after"] != nil {
+		t.Errorf("payload = %s, want delete payload without schema", data)
+	}
+}
+
+func TestDebeziumConverter_Optional(t *testing.T) {
+	event := ChangeEvent{
+		Header:    newTestHeader(EventType_UPDATE),
+		EventType: EventType_UPDATE,
+		RowData: &RowData{
+			BeforeColumns: []*Column{
+				{Name: "id", IsKey: true, MysqlType: "int", Value: "1"},
+				{Name: "name", MysqlType: "varchar(10)", IsNullPresent: &Column_IsNull{IsNull: true}},
+				{Name: "age", MysqlType: "int", Value: "1"},
+			},
+			AfterColumns: []*Column{
+				{Name: "id", IsKey: true, MysqlType: "int", Value: "1"},
+				{Name: "name", MysqlType: "varchar(10)", Value: "alice"},
+				{Name: "age", MysqlType: "int", Value: "2"},
+			},
+		},
+	}
+	notNull := func(schema, table, column string) bool { return column != "email" }
+	c := NewDebeziumConverter("dbserver1", WithDebeziumSchema(true), WithDebeziumNotNull(notNull))
+
+	optional := make(map[string]bool)
+	for _, field := range c.Convert(event).Schema.Fields[0].Fields {
+		optional[field.Field] = field.Optional
+	}
+	// name在变更前为NULL，即使声明NOT NULL也是optional
+	if want := map[string]bool{"id": false, "name": true, "age": false}; !reflect.DeepEqual(optional, want) {
+		t.Errorf("optional = %v, want %v", optional, want)
+	}
+}
+
+func TestDebeziumConverter_KeyChange(t *testing.T) {
+	column := func(name string, isKey bool, value string) *Column {
+		return &Column{Name: name, IsKey: isKey, MysqlType: "bigint(20) unsigned", Value: value}
+	}
+	event := ChangeEvent{
+		Header:    newTestHeader(EventType_UPDATE),
+		EventType: EventType_UPDATE,
+		RowData: &RowData{
+			BeforeColumns: []*Column{column("id", true, "1"), column("age", false, "18")},
+			AfterColumns:  []*Column{column("id", true, "2"), column("age", false, "18")},
+		},
+	}
+	c := NewDebeziumConverter("dbserver1", WithDebeziumSchema(true), WithDebeziumTombstones(true))
+
+	// 旧主键的删除和墓碑，新主键的创建
+	events := c.ConvertEvents(event)
+	var got []string
+	for _, event := range events {
+		op := "tombstone"
+		if !event.Tombstone {
+			op = event.Payload.Op
+		}
+		got = append(got, fmt.Sprintf("%s:%v", op, event.Key["id"]))
+	}
+	if want := []string{"d:1", "tombstone:1", "c:2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ConvertEvents() = %v, want %v", got, want)
+	}
+	if events[0].Payload.After != nil || events[2].Payload.Before != nil {
+		t.Errorf("ConvertEvents() delete after = %v, create before = %v, want nil", events[0].Payload.After, events[2].Payload.Before)
+	}
+	if field := events[2].Schema.Fields[1].Fields[0]; field.Type != "string" {
+		t.Errorf("unsigned bigint schema type = %s, want string", field.Type)
+	}
+
+	// 主键不变时仍然是一条更新
+	event.RowData.AfterColumns[0] = column("id", true, "1")
+	if events = c.ConvertEvents(event); len(events) != 1 || events[0].Payload.Op != DebeziumOpUpdate {
+		t.Errorf("ConvertEvents() = %d events, want one update", len(events))
+	}
+}
@@ -0,0 +1,115 @@
+package icanal
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sync"
+)
+
+// EventHandler 变更事件处理函数
+type EventHandler func(ctx context.Context, event ChangeEvent) error
+
+// Dispatcher 按库表和主键把变更事件分发到多个worker并行处理；相同主键的事件保持顺序
+type Dispatcher struct {
+	workers int
+	handler EventHandler
+}
+
+// NewDispatcher 新建分发器；workers小于1时按1处理
+func NewDispatcher(workers int, handler EventHandler) *Dispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Dispatcher{
+		workers: workers,
+		handler: handler,
+	}
+}
+
+// Handle 处理一批消息；所有worker处理完成才返回，任一worker失败则返回错误，由调用方回滚整批
+func (d *Dispatcher) Handle(ctx context.Context, message *Message) error {
+	events, err := ParseChangeEvents(message)
+	if err != nil {
+		return err
+	}
+	return d.Dispatch(ctx, events)
+}
+
+// Dispatch 分发变更事件；DDL和修改主键的UPDATE作为屏障，等待之前的事件处理完成后单独执行。
+// 修改主键的UPDATE前后两个主键落在不同分区，单独执行才能保证两个主键各自的顺序
+func (d *Dispatcher) Dispatch(ctx context.Context, events []ChangeEvent) error {
+	start := 0
+	for i, event := range events {
+		if !event.IsDdl && !event.keyChanged() {
+			continue
+		}
+		if err := d.dispatchRows(ctx, events[start:i]); err != nil {
+			return err
+		}
+		if err := d.handler(ctx, event); err != nil {
+			return err
+		}
+		start = i + 1
+	}
+
+	return d.dispatchRows(ctx, events[start:])
+}
+
+func (d *Dispatcher) dispatchRows(ctx context.Context, events []ChangeEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	partitions := make([][]ChangeEvent, d.workers)
+	for _, event := range events {
+		i := partitionOf(event, d.workers)
+		partitions[i] = append(partitions[i], event)
+	}
+
+	workerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, d.workers)
+	)
+	for i, partition := range partitions {
+		if len(partition) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, event := range partition {
+				if workerCtx.Err() != nil {
+					return
+				}
+				if err := d.handler(workerCtx, event); err != nil {
+					errs[i] = err
+					cancel() // 通知其他worker尽快退出
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// partitionOf 按schema.table加主键值计算分区；没有主键的表整表落在同一分区
+func partitionOf(event ChangeEvent, partitions int) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(event.SchemaName()))
+	hash.Write([]byte{'.'})
+	hash.Write([]byte(event.TableName()))
+	for _, column := range event.KeyColumns() {
+		hash.Write([]byte{0})
+		hash.Write([]byte(column.GetValue()))
+	}
+	return int(hash.Sum32() % uint32(partitions))
+}
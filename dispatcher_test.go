@@ -0,0 +1,121 @@
+package icanal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newRowEvent(table string, id string, seq string) ChangeEvent {
+	return ChangeEvent{
+		Header:    &Header{SchemaName: "db", TableName: table},
+		EventType: EventType_UPDATE,
+		RowData: &RowData{
+			AfterColumns: []*Column{
+				{Name: "id", IsKey: true, Value: id},
+				{Name: "seq", Value: seq},
+			},
+		},
+	}
+}
+
+func TestDispatcher_Dispatch(t *testing.T) {
+	var events []ChangeEvent
+	for seq := 0; seq < 10; seq++ {
+		for id := 0; id < 5; id++ {
+			events = append(events, newRowEvent("orders", fmt.Sprint(id), fmt.Sprint(seq)))
+		}
+	}
+
+	var (
+		mutex sync.Mutex
+		got   = make(map[string][]string)
+	)
+	dispatcher := NewDispatcher(4, func(ctx context.Context, event ChangeEvent) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		columns := event.Columns()
+		got[columns[0].GetValue()] = append(got[columns[0].GetValue()], columns[1].GetValue())
+		return nil
+	})
+
+	if err := dispatcher.Dispatch(context.Background(), events); err != nil {
+		t.Fatalf("Dispatch() error = %v", err)
+	}
+
+	want := []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}
+	for id := 0; id < 5; id++ {
+		if !reflect.DeepEqual(got[fmt.Sprint(id)], want) {
+			t.Errorf("Dispatch() key %d order = %v, want %v", id, got[fmt.Sprint(id)], want)
+		}
+	}
+}
+
+func TestDispatcher_DispatchError(t *testing.T) {
+	errHandle := errors.New("handle error")
+	events := []ChangeEvent{
+		newRowEvent("orders", "1", "1"),
+		newRowEvent("orders", "2", "1"),
+		newRowEvent("users", "1", "1"),
+	}
+
+	dispatcher := NewDispatcher(2, func(ctx context.Context, event ChangeEvent) error {
+		if event.TableName() == "users" {
+			return errHandle
+		}
+		return nil
+	})
+
+	if err := dispatcher.Dispatch(context.Background(), events); !errors.Is(err, errHandle) {
+		t.Errorf("Dispatch() error = %v, want %v", err, errHandle)
+	}
+}
+
+func TestDispatcher_DispatchKeyChange(t *testing.T) {
+	// 先更新id 1再把1改为2：按变更后主键分区时，主键变更可能先于id 1的更新执行
+	var events []ChangeEvent
+	for seq := 0; seq < 20; seq++ {
+		events = append(events, newRowEvent("orders", "1", fmt.Sprint(seq)))
+	}
+	keyChange := newRowEvent("orders", "2", "change")
+	keyChange.RowData.BeforeColumns = []*Column{
+		{Name: "id", IsKey: true, Value: "1"},
+		{Name: "seq", Value: "19"},
+	}
+	events = append(events, keyChange)
+	for seq := 0; seq < 20; seq++ {
+		events = append(events, newRowEvent("orders", "2", fmt.Sprint(seq)))
+	}
+
+	var (
+		mutex sync.Mutex
+		got   []string
+	)
+	dispatcher := NewDispatcher(4, func(ctx context.Context, event ChangeEvent) error {
+		columns := event.Columns()
+		if columns[0].GetValue() == "1" {
+			time.Sleep(time.Millisecond)
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		got = append(got, columns[0].GetValue()+":"+columns[1].GetValue())
+		return nil
+	})
+
+	if err := dispatcher.Dispatch(context.Background(), events); err != nil {
+		t.Fatalf("Dispatch() error = %v", err)
+	}
+	for i, value := range got {
+		if value == "2:change" {
+			if i != 20 {
+				t.Errorf("Dispatch() order = %v, want the key change after all updates of id 1 and before id 2", got)
+			}
+			return
+		}
+	}
+	t.Errorf("Dispatch() order = %v, key change not handled", got)
+}
@@ -13,6 +13,24 @@ var (
 	ErrOverRetryTimes        = errors.New("over retry times")
 	ErrSubscribe             = errors.New("subscribe error")
 	ErrUnsubscribe           = errors.New("unsubscribe error")
+	ErrManagerNotStarted     = errors.New("manager is not started")
+	ErrDestinationExists     = errors.New("destination already exists")
+	ErrDestinationNotFound   = errors.New("destination not found")
+	ErrBatchNotTracked       = errors.New("batch is not tracked")
+	ErrInvalidFilter         = errors.New("invalid filter")
+	ErrIncompatibleSchema    = errors.New("incompatible schema")
+	ErrSchemaNotFound        = errors.New("schema not found")
+	ErrNoPrimaryKey          = errors.New("no primary key")
+	ErrDeadLetter            = errors.New("dead letter write error")
+	ErrWebhookStatus         = errors.New("webhook unexpected status")
+	ErrKeyTemplate           = errors.New("invalid key template")
+	ErrBulkItem              = errors.New("bulk item error")
+	ErrInvalidRecord         = errors.New("invalid record file")
+	ErrInvalidClientId       = errors.New("invalid client id")
+	ErrLockLost              = errors.New("consumer lock lost")
+	ErrDuplicateField        = errors.New("duplicate field name")
+	ErrPermanent             = errors.New("permanent error")
+	ErrDDLTable              = errors.New("table not found in ddl")
 )
 
 type CanalError struct {
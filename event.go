@@ -0,0 +1,209 @@
+package icanal
+
+import (
+	"encoding/json"
+	"errors"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ChangeEvent 变更事件；对应一行数据变更或者一条DDL
+type ChangeEvent struct {
+	BatchId   int64
+	Header    *Header
+	EventType EventType
+	IsDdl     bool
+	Sql       string
+	RowData   *RowData // DDL时为nil
+	Row       int      // 行在所属Entry中的序号，从0开始
+	// TransactionId 事务id，取自同一消息中的TransactionEnd；事务在后续批次结束时为空
+	TransactionId string
+}
+
+// SchemaName 库名
+func (e ChangeEvent) SchemaName() string {
+	return e.Header.GetSchemaName()
+}
+
+// TableName 表名
+func (e ChangeEvent) TableName() string {
+	return e.Header.GetTableName()
+}
+
+// Columns 当前行的列；DELETE取变更前的列，其他取变更后的列
+func (e ChangeEvent) Columns() []*Column {
+	if e.EventType == EventType_DELETE {
+		return e.RowData.GetBeforeColumns()
+	}
+	return e.RowData.GetAfterColumns()
+}
+
+// KeyColumns 当前行的主键列
+func (e ChangeEvent) KeyColumns() []*Column {
+	var keys []*Column
+	for _, column := range e.Columns() {
+		if column.GetIsKey() {
+			keys = append(keys, column)
+		}
+	}
+	return keys
+}
+
+// keyChanged 是否为修改了主键的UPDATE
+func (e ChangeEvent) keyChanged() bool {
+	if e.EventType != EventType_UPDATE {
+		return false
+	}
+	before := make(map[string]*Column)
+	for _, column := range e.RowData.GetBeforeColumns() {
+		if column.GetIsKey() {
+			before[column.GetName()] = column
+		}
+	}
+	for _, column := range e.KeyColumns() {
+		old, ok := before[column.GetName()]
+		if !ok {
+			continue
+		}
+		if old.GetIsNull() != column.GetIsNull() || old.GetValue() != column.GetValue() {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalJSON 原生JSON格式；before/after为列名到值的映射，值按mysql类型转换为数字或字符串
+func (e ChangeEvent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		BatchId       int64          `json:"batchId"`
+		TransactionId string         `json:"transactionId,omitempty"`
+		Database      string         `json:"database"`
+		Table         string         `json:"table"`
+		Type          string         `json:"type"`
+		IsDdl         bool           `json:"isDdl"`
+		Sql           string         `json:"sql,omitempty"`
+		LogfileName   string         `json:"logfileName"`
+		LogfileOffset int64          `json:"logfileOffset"`
+		Gtid          string         `json:"gtid,omitempty"`
+		ExecuteTime   int64          `json:"executeTime"` // 毫秒
+		Before        map[string]any `json:"before,omitempty"`
+		After         map[string]any `json:"after,omitempty"`
+	}{
+		BatchId:       e.BatchId,
+		TransactionId: e.TransactionId,
+		Database:      e.SchemaName(),
+		Table:         e.TableName(),
+		Type:          e.EventType.String(),
+		IsDdl:         e.IsDdl,
+		Sql:           e.Sql,
+		LogfileName:   e.Header.GetLogfileName(),
+		LogfileOffset: e.Header.GetLogfileOffset(),
+		Gtid:          e.Header.GetGtid(),
+		ExecuteTime:   e.Header.GetExecuteTime(),
+		Before:        columnValues(e.RowData.GetBeforeColumns()),
+		After:         columnValues(e.RowData.GetAfterColumns()),
+	})
+}
+
+// columnValues 列名到值的映射；没有列时返回nil
+func columnValues(columns []*Column) map[string]any {
+	if len(columns) == 0 {
+		return nil
+	}
+	values := make(map[string]any, len(columns))
+	for _, column := range columns {
+		values[column.GetName()] = columnValue(column)
+	}
+	return values
+}
+
+// GetEntries 获取Entry列表；开启LazyParseEntry时在此解析并应用客户端过滤
+func (m *Message) GetEntries() ([]*Entry, error) {
+	if !m.Raw {
+		return m.Entries, nil
+	}
+
+	raws, _ := m.RawEntries.([][]byte)
+	entries := make([]*Entry, 0, len(raws))
+	for _, raw := range raws {
+		entry := &Entry{}
+		if err := proto.Unmarshal(raw, entry); err != nil {
+			return nil, errors.Join(ErrUnmarshal, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if m.filter != nil {
+		return m.filter.FilterEntries(entries)
+	}
+
+	return entries, nil
+}
+
+// ParseRowChange 解析ROWDATA类型Entry中的RowChange
+func ParseRowChange(entry *Entry) (*RowChange, error) {
+	rowChange := &RowChange{}
+	if err := proto.Unmarshal(entry.GetStoreValue(), rowChange); err != nil {
+		return nil, errors.Join(ErrUnmarshal, err)
+	}
+	return rowChange, nil
+}
+
+// ParseChangeEvents 把消息打散为变更事件；事务头尾等非ROWDATA的Entry会被忽略
+func ParseChangeEvents(message *Message) ([]ChangeEvent, error) {
+	entries, err := message.GetEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		events  []ChangeEvent
+		pending int // 当前事务的第一个事件
+	)
+	for _, entry := range entries {
+		switch entry.GetEntryType() {
+		case EntryType_TRANSACTIONBEGIN:
+			pending = len(events)
+			continue
+		case EntryType_TRANSACTIONEND:
+			end := &TransactionEnd{}
+			if err := proto.Unmarshal(entry.GetStoreValue(), end); err != nil {
+				return nil, errors.Join(ErrUnmarshal, err)
+			}
+			for i := pending; i < len(events); i++ {
+				events[i].TransactionId = end.GetTransactionId()
+			}
+			pending = len(events)
+			continue
+		case EntryType_ROWDATA:
+		default:
+			continue
+		}
+
+		rowChange, err := ParseRowChange(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		event := ChangeEvent{
+			BatchId:   message.Id,
+			Header:    entry.GetHeader(),
+			EventType: rowChange.GetEventType(),
+			IsDdl:     rowChange.GetIsDdl(),
+			Sql:       rowChange.GetSql(),
+		}
+
+		if event.IsDdl {
+			events = append(events, event)
+			continue
+		}
+
+		for i, rowData := range rowChange.GetRowDatas() {
+			event.RowData = rowData
+			event.Row = i
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kalvinzhang/icanal"
+	"github.com/kalvinzhang/icanal/example/util"
+)
+
+func main() {
+	// 收到SIGINT后停止所有destination
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT)
+	defer stop()
+
+	manager := icanal.NewManager([]string{"127.0.0.1:2181"}, time.Second*10)
+	if err := manager.Start(ctx); err != nil {
+		slog.ErrorContext(ctx, "start manager error", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	handler := func(ctx context.Context, message *icanal.Message) error {
+		util.PrintEntry(ctx, message.Entries)
+		return nil
+	}
+
+	for _, name := range []string{"example", "example2"} {
+		if err := manager.Add(ctx, icanal.Destination{
+			Name:    name,
+			Filter:  ".*\\..*",
+			Handler: handler,
+			Options: []icanal.Option{
+				icanal.WithUsername("canal"),
+				icanal.WithPassword("canal"),
+			},
+		}); err != nil {
+			slog.ErrorContext(ctx, "add destination error", slog.Any("error", err))
+		}
+	}
+
+	<-ctx.Done()
+
+	if err := manager.Stop(context.Background()); err != nil {
+		slog.Error("stop manager error", slog.Any("error", err))
+	}
+}
@@ -0,0 +1,242 @@
+package icanal
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Filter 客户端过滤器；兼容canal的filter语法
+//
+// 表过滤：逗号分隔的正则，匹配"schema.table"，忽略大小写并整体匹配，如"canal\\..*,test\\.t1"；
+// 黑名单使用相同语法，命中黑名单的表会被过滤。
+// 列过滤：逗号分隔的"schema.table:col1/col2"，白名单只保留列出的列，黑名单去掉列出的列。
+type Filter struct {
+	include        *regexp.Regexp // nil表示全部匹配
+	exclude        *regexp.Regexp // nil表示不排除
+	includeColumns map[string]map[string]bool
+	excludeColumns map[string]map[string]bool
+	cache          sync.Map     // schema.table -> bool
+	cacheSize      atomic.Int64 // cache中的库表数
+}
+
+// filterCacheSizeMax Match最多缓存的库表数；库表很多(如按日期分表)时超出部分不缓存，避免缓存无限增长
+const filterCacheSizeMax = 4096
+
+// NewFilter 新建过滤器
+func NewFilter(filter string, blackFilter string) (*Filter, error) {
+	include, err := compileFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compileFilter(blackFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Filter{
+		include: include,
+		exclude: exclude,
+	}, nil
+}
+
+// ValidateFilter 校验canal的filter语法
+func ValidateFilter(filter string) error {
+	_, err := compileFilter(filter)
+	return err
+}
+
+// compileFilter 把canal的filter编译为正则；空filter返回nil
+func compileFilter(filter string) (*regexp.Regexp, error) {
+	var patterns []string
+	for _, pattern := range strings.Split(filter, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, errors.Join(ErrInvalidFilter, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	// 与canal一致，长的模式优先匹配
+	sort.SliceStable(patterns, func(i, j int) bool {
+		return len(patterns[i]) > len(patterns[j])
+	})
+
+	return regexp.Compile("(?i)^(?:" + strings.Join(patterns, "|") + ")$")
+}
+
+// IncludeColumns 设置列白名单
+func (f *Filter) IncludeColumns(fieldFilter string) error {
+	columns, err := parseFieldFilter(fieldFilter)
+	if err != nil {
+		return err
+	}
+	f.includeColumns = columns
+	return nil
+}
+
+// ExcludeColumns 设置列黑名单
+func (f *Filter) ExcludeColumns(fieldBlackFilter string) error {
+	columns, err := parseFieldFilter(fieldBlackFilter)
+	if err != nil {
+		return err
+	}
+	f.excludeColumns = columns
+	return nil
+}
+
+// parseFieldFilter 解析"schema.table:col1/col2,schema.table2:col3"格式的列过滤
+func parseFieldFilter(fieldFilter string) (map[string]map[string]bool, error) {
+	if strings.TrimSpace(fieldFilter) == "" {
+		return nil, nil
+	}
+
+	tables := make(map[string]map[string]bool)
+	for _, part := range strings.Split(fieldFilter, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		table, fields, ok := strings.Cut(part, ":")
+		if !ok || table == "" || fields == "" {
+			return nil, errors.Join(ErrInvalidFilter, fmt.Errorf("invalid field filter: %s", part))
+		}
+
+		columns := make(map[string]bool)
+		for _, field := range strings.Split(fields, "/") {
+			if field = strings.TrimSpace(field); field != "" {
+				columns[strings.ToLower(field)] = true
+			}
+		}
+		tables[strings.ToLower(strings.TrimSpace(table))] = columns
+	}
+
+	return tables, nil
+}
+
+// Match 库表是否通过过滤
+func (f *Filter) Match(schema, table string) bool {
+	name := schema + "." + table
+	if matched, ok := f.cache.Load(name); ok {
+		return matched.(bool)
+	}
+
+	matched := (f.include == nil || f.include.MatchString(name)) &&
+		(f.exclude == nil || !f.exclude.MatchString(name))
+	if f.cacheSize.Load() < filterCacheSizeMax {
+		if _, loaded := f.cache.LoadOrStore(name, matched); !loaded {
+			f.cacheSize.Add(1)
+		}
+	}
+
+	return matched
+}
+
+// FilterColumns 按列过滤规则过滤列
+func (f *Filter) FilterColumns(schema, table string, columns []*Column) []*Column {
+	name := strings.ToLower(schema + "." + table)
+	include, hasInclude := f.includeColumns[name]
+	exclude, hasExclude := f.excludeColumns[name]
+	if !hasInclude && !hasExclude {
+		return columns
+	}
+
+	filtered := make([]*Column, 0, len(columns))
+	for _, column := range columns {
+		columnName := strings.ToLower(column.GetName())
+		if hasInclude && !include[columnName] {
+			continue
+		}
+		if hasExclude && exclude[columnName] {
+			continue
+		}
+		filtered = append(filtered, column)
+	}
+
+	return filtered
+}
+
+// hasColumnFilter 库表是否配置了列过滤
+func (f *Filter) hasColumnFilter(schema, table string) bool {
+	name := strings.ToLower(schema + "." + table)
+	_, hasInclude := f.includeColumns[name]
+	_, hasExclude := f.excludeColumns[name]
+	return hasInclude || hasExclude
+}
+
+// FilterEntries 过滤Entry；事务头尾等非ROWDATA的Entry和没有表名的DDL原样保留
+func (f *Filter) FilterEntries(entries []*Entry) ([]*Entry, error) {
+	filtered := make([]*Entry, 0, len(entries))
+	for _, entry := range entries {
+		header := entry.GetHeader()
+		if entry.GetEntryType() != EntryType_ROWDATA || header.GetTableName() == "" {
+			filtered = append(filtered, entry)
+			continue
+		}
+
+		if !f.Match(header.GetSchemaName(), header.GetTableName()) {
+			continue
+		}
+
+		if f.hasColumnFilter(header.GetSchemaName(), header.GetTableName()) {
+			if err := f.filterEntryColumns(entry); err != nil {
+				return nil, err
+			}
+		}
+
+		filtered = append(filtered, entry)
+	}
+
+	return filtered, nil
+}
+
+// filterMessage 过滤消息；延迟解析的消息在GetEntries解析时过滤
+func (f *Filter) filterMessage(message *Message) error {
+	if message.Raw {
+		message.filter = f
+		return nil
+	}
+
+	entries, err := f.FilterEntries(message.Entries)
+	if err != nil {
+		return err
+	}
+	message.Entries = entries
+
+	return nil
+}
+
+func (f *Filter) filterEntryColumns(entry *Entry) error {
+	rowChange, err := ParseRowChange(entry)
+	if err != nil {
+		return err
+	}
+
+	header := entry.GetHeader()
+	for _, rowData := range rowChange.GetRowDatas() {
+		rowData.BeforeColumns = f.FilterColumns(header.GetSchemaName(), header.GetTableName(), rowData.GetBeforeColumns())
+		rowData.AfterColumns = f.FilterColumns(header.GetSchemaName(), header.GetTableName(), rowData.GetAfterColumns())
+	}
+
+	storeValue, err := proto.Marshal(rowChange)
+	if err != nil {
+		return err
+	}
+	entry.StoreValue = storeValue
+
+	return nil
+}
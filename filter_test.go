@@ -0,0 +1,140 @@
+package icanal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFilter_Match(t *testing.T) {
+	type args struct {
+		schema string
+		table  string
+	}
+	tests := []struct {
+		name   string
+		filter string
+		black  string
+		args   args
+		want   bool
+	}{
+		{name: "empty filter", args: args{"db", "t1"}, want: true},
+		{name: "all tables", filter: ".*\\..*", args: args{"db", "t1"}, want: true},
+		{name: "schema tables", filter: "canal\\..*", args: args{"canal", "t1"}, want: true},
+		{name: "other schema", filter: "canal\\..*", args: args{"db", "t1"}, want: false},
+		{name: "full match", filter: "canal\\.t1", args: args{"canal", "t10"}, want: false},
+		{name: "ignore case", filter: "canal\\.t1", args: args{"CANAL", "T1"}, want: true},
+		{name: "multiple", filter: "canal\\.t1, db\\.t2", args: args{"db", "t2"}, want: true},
+		{name: "black", filter: ".*\\..*", black: "db\\.tmp_.*", args: args{"db", "tmp_1"}, want: false},
+		{name: "not black", filter: ".*\\..*", black: "db\\.tmp_.*", args: args{"db", "t1"}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewFilter(tt.filter, tt.black)
+			if err != nil {
+				t.Fatalf("NewFilter() error = %v", err)
+			}
+			if got := f.Match(tt.args.schema, tt.args.table); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateFilter(t *testing.T) {
+	if err := ValidateFilter("db\\.(t1"); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("ValidateFilter() error = %v, want %v", err, ErrInvalidFilter)
+	}
+	if err := NewSimpleConnector("127.0.0.1:11111", "example", WithFilter("db\\.[")).Connect(context.Background()); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("Connect() error = %v, want %v", err, ErrInvalidFilter)
+	}
+}
+
+func TestFilter_FilterColumns(t *testing.T) {
+	columns := []*Column{{Name: "id"}, {Name: "name"}, {Name: "password"}}
+	names := func(columns []*Column) []string {
+		var got []string
+		for _, column := range columns {
+			got = append(got, column.GetName())
+		}
+		return got
+	}
+
+	tests := []struct {
+		name  string
+		field string
+		black string
+		table string
+		want  []string
+	}{
+		{name: "no rule", table: "t1", want: []string{"id", "name", "password"}},
+		{name: "include", field: "db.t1:id/name", table: "t1", want: []string{"id", "name"}},
+		{name: "exclude", black: "db.t1:password", table: "t1", want: []string{"id", "name"}},
+		{name: "other table", field: "db.t2:id", table: "t1", want: []string{"id", "name", "password"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, _ := NewFilter("", "")
+			if err := f.IncludeColumns(tt.field); err != nil {
+				t.Fatalf("IncludeColumns() error = %v", err)
+			}
+			if err := f.ExcludeColumns(tt.black); err != nil {
+				t.Fatalf("ExcludeColumns() error = %v", err)
+			}
+			if got := names(f.FilterColumns("db", tt.table, columns)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FilterColumns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_LazyParseEntry(t *testing.T) {
+	ctx := context.Background()
+	header := newTestHeader(EventType_INSERT)
+	header.TableName = "orders"
+	orders := newRowDataEntry(header, &RowChange{
+		EventTypePresent: &RowChange_EventType{EventType: EventType_INSERT},
+		RowDatas:         []*RowData{{AfterColumns: []*Column{newTestColumn(0, "id", true, "1", true)}}},
+	})
+	server := newFakeCanalServer(t, append(testEntries(), orders))
+
+	// 延迟解析时在GetEntries中过滤掉orders
+	connector := NewSimpleConnector(server.Address(), "example",
+		WithFilter(`test\.user`), WithLazyParseEntry(true), WithLogger(QuietLogger()))
+	if err := connector.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer connector.Disconnect(ctx)
+	message, err := connector.GetWithoutAck(ctx, 100, time.Second)
+	if err != nil {
+		t.Fatalf("GetWithoutAck() error = %v", err)
+	}
+	if !message.Raw {
+		t.Fatalf("GetWithoutAck() Raw = false, want true")
+	}
+	entries, err := message.GetEntries()
+	if err != nil {
+		t.Fatalf("GetEntries() error = %v", err)
+	}
+	if len(entries) != len(testEntries()) {
+		t.Errorf("GetEntries() = %d entries, want %d", len(entries), len(testEntries()))
+	}
+}
+
+func TestFilter_MatchCache(t *testing.T) {
+	f, _ := NewFilter(`db\..*`, "")
+	for i := 0; i < filterCacheSizeMax+10; i++ {
+		if !f.Match("db", fmt.Sprintf("t_%d", i)) {
+			t.Fatalf("Match(t_%d) = false, want true", i)
+		}
+	}
+	if size := f.cacheSize.Load(); size != filterCacheSizeMax {
+		t.Errorf("cache size = %d, want %d", size, filterCacheSizeMax)
+	}
+	if f.Match("other", "t_0") {
+		t.Errorf("Match(other.t_0) = true, want false")
+	}
+}
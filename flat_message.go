@@ -0,0 +1,162 @@
+package icanal
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// FlatMessage canal MQ模式的扁平消息；字段及JSON格式与canal一致，便于TCP模式与MQ模式共用下游代码
+//
+// 字段按字母序声明，与canal(fastjson)输出的字段顺序一致
+type FlatMessage struct {
+	Data      []map[string]*string `json:"data"`
+	Database  string               `json:"database"`
+	Es        int64                `json:"es"` // binlog执行时间，毫秒
+	Gtid      string               `json:"gtid"`
+	Id        int64                `json:"id"` // batch id
+	IsDdl     bool                 `json:"isDdl"`
+	MysqlType map[string]string    `json:"mysqlType"`
+	Old       []map[string]*string `json:"old"`
+	PkNames   []string             `json:"pkNames"`
+	Sql       string               `json:"sql"`
+	SqlType   map[string]int32     `json:"sqlType"`
+	Table     string               `json:"table"`
+	Ts        int64                `json:"ts"` // 转换时间，毫秒
+	Type      string               `json:"type"`
+}
+
+// NewFlatMessages 把消息转换为扁平消息，每个ROWDATA类型的Entry对应一条
+func NewFlatMessages(message *Message) ([]*FlatMessage, error) {
+	entries, err := message.GetEntries()
+	if err != nil {
+		return nil, err
+	}
+	return NewFlatMessagesFromEntries(message.Id, entries)
+}
+
+// NewFlatMessagesFromEntries 把一组Entry(如一个事务)转换为扁平消息，转换规则与canal的MQMessageUtils一致
+func NewFlatMessagesFromEntries(id int64, entries []*Entry) ([]*FlatMessage, error) {
+	var flatMessages []*FlatMessage
+	ts := time.Now().UnixMilli()
+
+	for _, entry := range entries {
+		if entry.GetEntryType() != EntryType_ROWDATA {
+			continue
+		}
+
+		rowChange, err := ParseRowChange(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		header := entry.GetHeader()
+		eventType := rowChange.GetEventType()
+		flatMessage := &FlatMessage{
+			Id:       id,
+			Database: header.GetSchemaName(),
+			Table:    header.GetTableName(),
+			IsDdl:    rowChange.GetIsDdl(),
+			Type:     eventType.String(),
+			Es:       header.GetExecuteTime(),
+			Ts:       ts,
+			Sql:      rowChange.GetSql(),
+			Gtid:     header.GetGtid(),
+			PkNames:  []string{}, // canal输出[]而不是null
+		}
+		flatMessages = append(flatMessages, flatMessage)
+
+		if rowChange.GetIsDdl() {
+			continue
+		}
+		if eventType != EventType_INSERT && eventType != EventType_UPDATE && eventType != EventType_DELETE {
+			continue
+		}
+
+		sqlType := make(map[string]int32)
+		mysqlType := make(map[string]string)
+		// 与canal一致，更新列集合在整个Entry内累积
+		updated := make(map[string]bool)
+
+		for i, rowData := range rowChange.GetRowDatas() {
+			columns := rowData.GetAfterColumns()
+			if eventType == EventType_DELETE {
+				columns = rowData.GetBeforeColumns()
+			}
+
+			row := make(map[string]*string, len(columns))
+			for _, column := range columns {
+				if i == 0 && column.GetIsKey() {
+					flatMessage.PkNames = append(flatMessage.PkNames, column.GetName())
+				}
+				sqlType[column.GetName()] = column.GetSqlType()
+				mysqlType[column.GetName()] = column.GetMysqlType()
+				row[column.GetName()] = flatValue(column)
+				if column.GetUpdated() {
+					updated[column.GetName()] = true
+				}
+			}
+			if len(row) > 0 {
+				flatMessage.Data = append(flatMessage.Data, row)
+			}
+
+			if eventType == EventType_UPDATE {
+				old := make(map[string]*string)
+				for _, column := range rowData.GetBeforeColumns() {
+					if updated[column.GetName()] {
+						old[column.GetName()] = flatValue(column)
+					}
+				}
+				if len(old) > 0 {
+					flatMessage.Old = append(flatMessage.Old, old)
+				}
+			}
+		}
+
+		if len(sqlType) > 0 {
+			flatMessage.SqlType = sqlType
+		}
+		if len(mysqlType) > 0 {
+			flatMessage.MysqlType = mysqlType
+		}
+	}
+
+	return flatMessages, nil
+}
+
+// NewFlatMessageFromEvent 把单个变更事件转换为扁平消息，data和old最多一行
+func NewFlatMessageFromEvent(event ChangeEvent) (*FlatMessage, error) {
+	rowChange := &RowChange{
+		EventTypePresent: &RowChange_EventType{EventType: event.EventType},
+		IsDdlPresent:     &RowChange_IsDdl{IsDdl: event.IsDdl},
+		Sql:              event.Sql,
+	}
+	if event.RowData != nil {
+		rowChange.RowDatas = []*RowData{event.RowData}
+	}
+
+	storeValue, err := proto.Marshal(rowChange)
+	if err != nil {
+		return nil, err
+	}
+
+	flatMessages, err := NewFlatMessagesFromEntries(event.BatchId, []*Entry{{
+		Header:           event.Header,
+		EntryTypePresent: &Entry_EntryType{EntryType: EntryType_ROWDATA},
+		StoreValue:       storeValue,
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	return flatMessages[0], nil
+}
+
+// flatValue 列值；NULL返回nil
+func flatValue(column *Column) *string {
+	if column.GetIsNull() {
+		return nil
+	}
+	value := column.GetValue()
+	return &value
+}
@@ -0,0 +1,174 @@
+package icanal
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func newRowDataEntry(header *Header, rowChange *RowChange) *Entry {
+	storeValue, _ := proto.Marshal(rowChange)
+	return &Entry{
+		Header:           header,
+		EntryTypePresent: &Entry_EntryType{EntryType: EntryType_ROWDATA},
+		StoreValue:       storeValue,
+	}
+}
+
+func newTestHeader(eventType EventType) *Header {
+	return &Header{
+		LogfileName:      "mysql-bin.000001",
+		LogfileOffset:    1024,
+		ServerId:         1,
+		ExecuteTime:      1589373515000,
+		SchemaName:       "test",
+		TableName:        "user",
+		EventTypePresent: &Header_EventType{EventType: eventType},
+		Gtid:             "3e11fa47-71ca-11e1-9e33-c80aa9429562:23",
+	}
+}
+
+func newTestColumn(index int32, name string, isKey bool, value string, updated bool) *Column {
+	column := &Column{
+		Index:         index,
+		Name:          name,
+		IsKey:         isKey,
+		Value:         value,
+		Updated:       updated,
+		IsNullPresent: &Column_IsNull{IsNull: false},
+	}
+	switch name {
+	case "id":
+		column.SqlType, column.MysqlType = -5, "bigint(20)"
+	case "name":
+		column.SqlType, column.MysqlType = 12, "varchar(255)"
+	case "age":
+		column.SqlType, column.MysqlType = 4, "int(11)"
+	}
+	if value == "NULL" {
+		column.Value = ""
+		column.IsNullPresent = &Column_IsNull{IsNull: true}
+	}
+	return column
+}
+
+// testEntries 一个事务内的插入、更新、删除和DDL
+func testEntries() []*Entry {
+	return []*Entry{
+		{
+			Header:           newTestHeader(EventType_QUERY),
+			EntryTypePresent: &Entry_EntryType{EntryType: EntryType_TRANSACTIONBEGIN},
+		},
+		newRowDataEntry(newTestHeader(EventType_INSERT), &RowChange{
+			EventTypePresent: &RowChange_EventType{EventType: EventType_INSERT},
+			RowDatas: []*RowData{
+				{AfterColumns: []*Column{
+					newTestColumn(0, "id", true, "1", true),
+					newTestColumn(1, "name", false, "alice", true),
+					newTestColumn(2, "age", false, "NULL", true),
+				}},
+				{AfterColumns: []*Column{
+					newTestColumn(0, "id", true, "2", true),
+					newTestColumn(1, "name", false, "bob", true),
+					newTestColumn(2, "age", false, "20", true),
+				}},
+			},
+		}),
+		newRowDataEntry(newTestHeader(EventType_UPDATE), &RowChange{
+			EventTypePresent: &RowChange_EventType{EventType: EventType_UPDATE},
+			RowDatas: []*RowData{
+				{
+					BeforeColumns: []*Column{
+						newTestColumn(0, "id", true, "1", false),
+						newTestColumn(1, "name", false, "alice", false),
+						newTestColumn(2, "age", false, "NULL", false),
+					},
+					AfterColumns: []*Column{
+						newTestColumn(0, "id", true, "1", false),
+						newTestColumn(1, "name", false, "alice", false),
+						newTestColumn(2, "age", false, "18", true),
+					},
+				},
+			},
+		}),
+		newRowDataEntry(newTestHeader(EventType_DELETE), &RowChange{
+			EventTypePresent: &RowChange_EventType{EventType: EventType_DELETE},
+			RowDatas: []*RowData{
+				{BeforeColumns: []*Column{
+					newTestColumn(0, "id", true, "2", false),
+					newTestColumn(1, "name", false, "bob", false),
+					newTestColumn(2, "age", false, "20", false),
+				}},
+			},
+		}),
+		newRowDataEntry(newTestHeader(EventType_ALTER), &RowChange{
+			EventTypePresent: &RowChange_EventType{EventType: EventType_ALTER},
+			IsDdlPresent:     &RowChange_IsDdl{IsDdl: true},
+			Sql:              "ALTER TABLE user ADD COLUMN email varchar(255)",
+			DdlSchemaName:    "test",
+		}),
+		{
+			Header:           newTestHeader(EventType_QUERY),
+			EntryTypePresent: &Entry_EntryType{EntryType: EntryType_TRANSACTIONEND},
+			StoreValue:       mustMarshal(&TransactionEnd{TransactionId: "1001"}),
+		},
+	}
+}
+
+func mustMarshal(m proto.Message) []byte {
+	data, err := proto.Marshal(m)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// assertCanalOutput 与testdata下canal输出的消息按JSON语义逐行比较；文件按canal的序列化规则编写，不从本包输出生成
+func assertCanalOutput(t *testing.T, name string, got [][]byte) {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	if len(got) != len(lines) {
+		t.Fatalf("%s has %d messages, got %d", name, len(lines), len(got))
+	}
+
+	for i, line := range lines {
+		var gotValue, wantValue any
+		if err = json.Unmarshal(got[i], &gotValue); err != nil {
+			t.Fatal(err)
+		}
+		if err = json.Unmarshal(line, &wantValue); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(gotValue, wantValue) {
+			t.Errorf("%s line %d mismatch\ngot:  %s\nwant: %s", name, i+1, got[i], line)
+		}
+	}
+}
+
+func TestNewFlatMessages(t *testing.T) {
+	flatMessages, err := NewFlatMessages(&Message{Id: 3, Entries: testEntries()})
+	if err != nil {
+		t.Fatalf("NewFlatMessages() error = %v", err)
+	}
+
+	var got [][]byte
+	for _, flatMessage := range flatMessages {
+		flatMessage.Ts = 1589373515477
+		data, err := json.Marshal(flatMessage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, data)
+	}
+	assertCanalOutput(t, "canal_flat_message.jsonl", got)
+}
@@ -0,0 +1,362 @@
+package icanal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	LockHeld    = "held"    // 持有锁，正在消费
+	LockStandby = "standby" // 等待锁的备用实例
+)
+
+// HealthStatus 单个destination的健康状态
+type HealthStatus struct {
+	Destination      string    `json:"destination"`
+	Ready            bool      `json:"ready"`
+	Live             bool      `json:"live"`
+	Reasons          []string  `json:"reasons,omitempty"` // 未就绪或不存活的原因
+	Connected        bool      `json:"connected"`
+	Subscribed       bool      `json:"subscribed"`
+	ZookeeperState   string    `json:"zkState,omitempty"` // 非集群连接器为空
+	Lock             string    `json:"lock,omitempty"`    // LockHeld或LockStandby，非集群连接器为空
+	LastGet          time.Time `json:"lastGet,omitzero"`  // 最后一次成功拉取的时间
+	LastError        string    `json:"lastError,omitempty"`
+	LastErrorTime    time.Time `json:"lastErrorTime,omitzero"`
+	LagMs            int64     `json:"lagMs"`
+	MaxLagMs         int64     `json:"maxLagMs"`
+	SinceLastEventMs int64     `json:"sinceLastEventMs"`
+}
+
+// HealthReport 所有destination的健康状态
+type HealthReport struct {
+	Ready        bool           `json:"ready"`
+	Live         bool           `json:"live"`
+	Destinations []HealthStatus `json:"destinations"`
+}
+
+// HealthHandler 健康检查的http.Handler；ServeHTTP为就绪检查，Liveness为存活检查
+//
+// 就绪和存活时返回200，否则返回503，响应体为HealthReport的JSON。没有destination时视为就绪。
+type HealthHandler struct {
+	mutex        sync.Mutex
+	destinations map[string]*healthConnector
+	maxLag       time.Duration
+	maxGetAge    time.Duration
+	requireLock  bool
+	liveGetAge   time.Duration
+	now          func() time.Time
+}
+
+type HealthOption func(*HealthHandler)
+
+// WithReadyMaxLag 延迟超过maxLag时未就绪；为0时不检查
+func WithReadyMaxLag(maxLag time.Duration) HealthOption {
+	return func(h *HealthHandler) {
+		h.maxLag = maxLag
+	}
+}
+
+// WithReadyMaxGetAge 持有连接但超过maxGetAge没有成功拉取时未就绪；为0时不检查
+func WithReadyMaxGetAge(maxGetAge time.Duration) HealthOption {
+	return func(h *HealthHandler) {
+		h.maxGetAge = maxGetAge
+	}
+}
+
+// WithReadyRequireLock 备用实例视为未就绪；默认备用实例就绪
+func WithReadyRequireLock() HealthOption {
+	return func(h *HealthHandler) {
+		h.requireLock = true
+	}
+}
+
+// WithLiveMaxGetAge 持有连接但超过maxGetAge没有成功拉取时视为不存活，用于发现卡住的消费；为0时不检查
+func WithLiveMaxGetAge(maxGetAge time.Duration) HealthOption {
+	return func(h *HealthHandler) {
+		h.liveGetAge = maxGetAge
+	}
+}
+
+// NewHealthHandler 新建健康检查
+func NewHealthHandler(opts ...HealthOption) *HealthHandler {
+	h := &HealthHandler{
+		destinations: make(map[string]*healthConnector),
+		now:          time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// Remove 移除destination
+func (h *HealthHandler) Remove(destination string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	delete(h.destinations, destination)
+}
+
+// Report 当前健康状态，按destination排序
+func (h *HealthHandler) Report() HealthReport {
+	h.mutex.Lock()
+	connectors := make([]*healthConnector, 0, len(h.destinations))
+	for _, c := range h.destinations {
+		connectors = append(connectors, c)
+	}
+	h.mutex.Unlock()
+
+	sort.Slice(connectors, func(i, j int) bool { return connectors[i].destination < connectors[j].destination })
+
+	report := HealthReport{Ready: true, Live: true, Destinations: make([]HealthStatus, 0, len(connectors))}
+	for _, c := range connectors {
+		status := h.status(c)
+		report.Ready = report.Ready && status.Ready
+		report.Live = report.Live && status.Live
+		report.Destinations = append(report.Destinations, status)
+	}
+	return report
+}
+
+// status 计算单个destination的状态
+func (h *HealthHandler) status(c *healthConnector) HealthStatus {
+	now := h.now()
+
+	c.mutex.Lock()
+	status := HealthStatus{
+		Destination:   c.destination,
+		Connected:     c.connected,
+		Subscribed:    c.subscribed,
+		LastGet:       c.lastGet,
+		LastErrorTime: c.lastErrorTime,
+	}
+	if c.lastError != nil {
+		status.LastError = c.lastError.Error()
+	}
+	c.mutex.Unlock()
+
+	if cluster, ok := unwrapConnector(c.Connector).(*clusterConnector); ok {
+		if manager, ok := cluster.clusterManager.(clusterStatus); ok {
+			status.ZookeeperState = manager.zookeeperState()
+			status.Lock = LockStandby
+			if manager.lockHeld() {
+				status.Lock = LockHeld
+			}
+		}
+	}
+
+	lag := c.lag.Stats()
+	status.LagMs = lag.Lag.Milliseconds()
+	status.MaxLagMs = lag.MaxLag.Milliseconds()
+	status.SinceLastEventMs = lag.SinceLastEvent.Milliseconds()
+
+	var notReady, notLive []string
+	switch {
+	case status.Lock == LockStandby && !status.Connected:
+		if h.requireLock {
+			notReady = append(notReady, "standby")
+		}
+	case !status.Connected:
+		notReady = append(notReady, "not connected")
+	case !status.Subscribed:
+		notReady = append(notReady, "not subscribed")
+	default:
+		getAge := now.Sub(status.LastGet)
+		if h.maxGetAge > 0 && getAge > h.maxGetAge {
+			notReady = append(notReady, fmt.Sprintf("no successful get for %s", getAge.Round(time.Millisecond)))
+		}
+		if h.liveGetAge > 0 && getAge > h.liveGetAge {
+			notLive = append(notLive, fmt.Sprintf("no successful get for %s", getAge.Round(time.Millisecond)))
+		}
+		if h.maxLag > 0 && lag.Lag > h.maxLag {
+			notReady = append(notReady, fmt.Sprintf("lag %s exceeds %s", lag.Lag.Round(time.Millisecond), h.maxLag))
+		}
+	}
+
+	status.Ready = len(notReady) == 0
+	status.Live = len(notLive) == 0
+	status.Reasons = append(notReady, notLive...)
+	return status
+}
+
+// ServeHTTP 就绪检查
+func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	report := h.Report()
+	writeHealthReport(w, report, report.Ready)
+}
+
+// Liveness 存活检查的http.Handler
+func (h *HealthHandler) Liveness() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		report := h.Report()
+		writeHealthReport(w, report, report.Live)
+	})
+}
+
+func writeHealthReport(w http.ResponseWriter, report HealthReport, ok bool) {
+	w.Header().Set("Content-Type", "application/json")
+	if ok {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// healthConnector 记录连接状态的连接器
+type healthConnector struct {
+	Connector
+	destination   string
+	lag           *LagTracker
+	mutex         sync.Mutex
+	connected     bool
+	subscribed    bool
+	lastGet       time.Time
+	lastError     error
+	lastErrorTime time.Time
+}
+
+type HealthConnectorOption func(*healthConnector)
+
+// WithHealthLagTracker 使用已有的延迟跟踪，调用方负责用NewLagConnector等方式观测；不设置时包装一个新的延迟连接器
+func WithHealthLagTracker(tracker *LagTracker) HealthConnectorOption {
+	return func(c *healthConnector) {
+		c.lag = tracker
+	}
+}
+
+// NewHealthConnector 包装连接器并注册到health，同名destination会被替换；可用于简单、集群和回放连接器
+//
+// 集群连接器被包外的类型包装时无法读取zookeeper和锁状态
+func NewHealthConnector(connector Connector, destination string, health *HealthHandler, opts ...HealthConnectorOption) Connector {
+	c := &healthConnector{
+		Connector:   connector,
+		destination: destination,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.lag == nil {
+		c.lag = NewLagTracker()
+		c.Connector = NewLagConnector(connector, c.lag)
+	}
+
+	health.mutex.Lock()
+	health.destinations[destination] = c
+	health.mutex.Unlock()
+
+	return c
+}
+
+// observe 记录错误；ctx取消不算错误
+func (c *healthConnector) observe(err error) error {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return err
+	}
+	c.mutex.Lock()
+	c.lastError = err
+	c.lastErrorTime = time.Now()
+	c.mutex.Unlock()
+	return err
+}
+
+func (c *healthConnector) setState(connected bool, subscribed bool) {
+	c.mutex.Lock()
+	c.connected = connected
+	c.subscribed = subscribed
+	c.mutex.Unlock()
+}
+
+func (c *healthConnector) Connect(ctx context.Context) error {
+	c.setState(false, false)
+	if err := c.observe(c.Connector.Connect(ctx)); err != nil {
+		return err
+	}
+	c.setState(true, false)
+	return nil
+}
+
+func (c *healthConnector) Disconnect(ctx context.Context) error {
+	c.setState(false, false)
+	return c.observe(c.Connector.Disconnect(ctx))
+}
+
+func (c *healthConnector) Subscribe(ctx context.Context, filter string) error {
+	if err := c.observe(c.Connector.Subscribe(ctx, filter)); err != nil {
+		return err
+	}
+	c.setState(true, true)
+	return nil
+}
+
+func (c *healthConnector) Unsubscribe(ctx context.Context) error {
+	if err := c.observe(c.Connector.Unsubscribe(ctx)); err != nil {
+		return err
+	}
+	c.setState(true, false)
+	return nil
+}
+
+func (c *healthConnector) Get(ctx context.Context, batchSize int32, timeout time.Duration) (*Message, error) {
+	message, err := c.Connector.Get(ctx, batchSize, timeout)
+	if err = c.observe(err); err != nil {
+		return nil, err
+	}
+	c.got()
+	return message, nil
+}
+
+func (c *healthConnector) GetWithoutAck(ctx context.Context, batchSize int32, timeout time.Duration) (*Message, error) {
+	message, err := c.Connector.GetWithoutAck(ctx, batchSize, timeout)
+	if err = c.observe(err); err != nil {
+		return nil, err
+	}
+	c.got()
+	return message, nil
+}
+
+// got 拉取成功；服务端要求先订阅才能拉取，Connect时按WithFilter订阅的也视为已订阅
+func (c *healthConnector) got() {
+	c.mutex.Lock()
+	c.connected = true
+	c.subscribed = true
+	c.lastGet = time.Now()
+	c.mutex.Unlock()
+}
+
+func (c *healthConnector) Ack(ctx context.Context, batchId int64) error {
+	return c.observe(c.Connector.Ack(ctx, batchId))
+}
+
+func (c *healthConnector) Rollback(ctx context.Context, batchId int64) error {
+	return c.observe(c.Connector.Rollback(ctx, batchId))
+}
+
+// unwrapConnector 去掉包内的包装，返回最内层的连接器
+func unwrapConnector(connector Connector) Connector {
+	for {
+		switch c := connector.(type) {
+		case *healthConnector:
+			connector = c.Connector
+		case *lagConnector:
+			connector = c.Connector
+		case *metricsConnector:
+			connector = c.Connector
+		case *tracingConnector:
+			connector = c.Connector
+		case *Recorder:
+			connector = c.Connector
+		default:
+			return connector
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package icanal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// stateConnector Connect返回给定错误的连接器
+type stateConnector struct {
+	messageConnector
+	connectErr error
+}
+
+func (c *stateConnector) Connect(context.Context) error           { return c.connectErr }
+func (c *stateConnector) Subscribe(context.Context, string) error { return nil }
+
+func TestHealthHandler(t *testing.T) {
+	ctx := context.Background()
+	health := NewHealthHandler(WithReadyMaxLag(time.Hour), WithLiveMaxGetAge(time.Hour))
+	inner := &stateConnector{
+		messageConnector: messageConnector{
+			Connector: &recordConnector{},
+			messages:  []*Message{{Id: 1, Entries: testEntries()}},
+		},
+		connectErr: ErrNetwork,
+	}
+	connector := NewHealthConnector(inner, "example", health)
+
+	check := func(handler http.Handler, wantCode int) HealthReport {
+		t.Helper()
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		if recorder.Code != wantCode {
+			t.Fatalf("status = %d, want %d: %s", recorder.Code, wantCode, recorder.Body.String())
+		}
+		var report HealthReport
+		if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		return report
+	}
+
+	if err := connector.Connect(ctx); !errors.Is(err, ErrNetwork) {
+		t.Fatalf("Connect() error = %v, want %v", err, ErrNetwork)
+	}
+	report := check(health, http.StatusServiceUnavailable)
+	if status := report.Destinations[0]; status.Connected || status.LastError == "" {
+		t.Errorf("status = %+v, want disconnected with last error", status)
+	}
+	check(health.Liveness(), http.StatusOK)
+
+	inner.connectErr = nil
+	if err := connector.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	report = check(health, http.StatusServiceUnavailable)
+	if status := report.Destinations[0]; !status.Connected || status.Subscribed {
+		t.Errorf("status = %+v, want connected but not subscribed", status)
+	}
+
+	message, err := connector.GetWithoutAck(ctx, 100, time.Second)
+	if err != nil {
+		t.Fatalf("GetWithoutAck() error = %v", err)
+	}
+	if err = connector.Ack(ctx, message.Id); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+
+	// 测试数据的执行时间是多年前，延迟超过阈值
+	report = check(health, http.StatusServiceUnavailable)
+	if status := report.Destinations[0]; !status.Subscribed || status.LastGet.IsZero() || status.LagMs <= 0 {
+		t.Errorf("status = %+v, want subscribed with lag", status)
+	}
+
+	WithReadyMaxLag(0)(health)
+	check(health, http.StatusOK)
+
+	health.Remove("example")
+	if report = check(health, http.StatusOK); len(report.Destinations) != 0 {
+		t.Errorf("destinations = %v, want empty", report.Destinations)
+	}
+}
+
+func TestHealthConnector_LagTracker(t *testing.T) {
+	ctx := context.Background()
+	health := NewHealthHandler()
+	tracker := NewLagTracker()
+	lagged := NewLagConnector(&messageConnector{
+		Connector: &recordConnector{},
+		messages:  []*Message{{Id: 1, Entries: testEntries()}, {Id: 2, Entries: testEntries()}},
+	}, tracker)
+	connector := NewHealthConnector(lagged, "example", health, WithHealthLagTracker(tracker))
+
+	// 使用已有的延迟跟踪时不再包装
+	if inner := connector.(*healthConnector).Connector; inner != lagged {
+		t.Fatalf("wrapped connector = %T, want the given lag connector", inner)
+	}
+
+	message, err := connector.GetWithoutAck(ctx, 100, time.Second)
+	if err != nil {
+		t.Fatalf("GetWithoutAck() error = %v", err)
+	}
+	if err = connector.Rollback(ctx, message.Id); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	// 回滚后的批次不再观测
+	if err = connector.Ack(ctx, message.Id); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if status := health.Report().Destinations[0]; status.LagMs != 0 {
+		t.Errorf("lag = %dms after rollback, want 0", status.LagMs)
+	}
+
+	if message, err = connector.GetWithoutAck(ctx, 100, time.Second); err != nil {
+		t.Fatalf("GetWithoutAck() error = %v", err)
+	}
+	if err = connector.Ack(ctx, message.Id); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if status := health.Report().Destinations[0]; status.LagMs <= 0 {
+		t.Errorf("lag = %dms after ack, want > 0", status.LagMs)
+	}
+}
@@ -0,0 +1,134 @@
+package icanal
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+const (
+	HeartbeatTableDefault    = "icanal_heartbeat" // 默认心跳表
+	HeartbeatIdDefault       = "icanal"           // 默认心跳行的id
+	HeartbeatIntervalDefault = time.Second        // 默认心跳间隔
+)
+
+// HeartbeatWriter 定时向源库的心跳表写入当前时间
+//
+// 源库空闲时canal不产生Entry，LagTracker的延迟会一直增长；写入心跳表后binlog中会持续有该表的行变更，
+// 消费到后延迟恢复为真实的复制延迟。心跳表的行变更交给LagTracker时用WithLagHeartbeatTable按心跳处理，
+// Handler一般需要跳过该表。心跳表不存在时自动创建，每个id一行；写入失败只记录日志，下一个间隔重试。
+type HeartbeatWriter struct {
+	db       *sql.DB
+	dialect  SQLDialect
+	table    string
+	id       string
+	interval time.Duration
+	logger   *slog.Logger
+	now      func() time.Time
+}
+
+type HeartbeatOption func(*HeartbeatWriter)
+
+// WithHeartbeatTable 设置心跳表，位于连接的默认库
+func WithHeartbeatTable(table string) HeartbeatOption {
+	return func(w *HeartbeatWriter) {
+		w.table = table
+	}
+}
+
+// WithHeartbeatId 设置心跳行的id；多个进程写入同一张表时使用不同的id
+func WithHeartbeatId(id string) HeartbeatOption {
+	return func(w *HeartbeatWriter) {
+		w.id = id
+	}
+}
+
+// WithHeartbeatInterval 设置心跳间隔，应小于延迟告警的阈值
+func WithHeartbeatInterval(interval time.Duration) HeartbeatOption {
+	return func(w *HeartbeatWriter) {
+		w.interval = interval
+	}
+}
+
+// WithHeartbeatLogger 设置日志
+func WithHeartbeatLogger(logger *slog.Logger) HeartbeatOption {
+	return func(w *HeartbeatWriter) {
+		w.logger = logger
+	}
+}
+
+// NewHeartbeatWriter 新建心跳写入器，db为源库的连接
+func NewHeartbeatWriter(db *sql.DB, dialect SQLDialect, opts ...HeartbeatOption) *HeartbeatWriter {
+	w := &HeartbeatWriter{
+		db:       db,
+		dialect:  dialect,
+		table:    HeartbeatTableDefault,
+		id:       HeartbeatIdDefault,
+		interval: HeartbeatIntervalDefault,
+		now:      time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+	w.logger = loggerOrDefault(w.logger)
+
+	return w
+}
+
+// Init 创建心跳表
+func (w *HeartbeatWriter) Init(ctx context.Context) error {
+	_, err := w.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+w.dialect.quote(w.table)+" ("+
+		w.dialect.quote("id")+" VARCHAR(255) NOT NULL PRIMARY KEY, "+
+		w.dialect.quote("ts")+" BIGINT NOT NULL)")
+	return err
+}
+
+// Beat 写入一次心跳，ts为当前的unix毫秒
+func (w *HeartbeatWriter) Beat(ctx context.Context) error {
+	column := func(name string, isKey bool, value string) *Column {
+		return &Column{Name: name, IsKey: isKey, Value: value, Updated: true}
+	}
+
+	statement, err := NewSQLGenerator(w.dialect, WithUpsert(true)).Generate(ChangeEvent{
+		Header:    &Header{TableName: w.table},
+		EventType: EventType_INSERT,
+		RowData: &RowData{AfterColumns: []*Column{
+			column("id", true, w.id),
+			column("ts", false, strconv.FormatInt(w.now().UnixMilli(), 10)),
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.db.ExecContext(ctx, statement.Query, statement.Args...)
+	return err
+}
+
+// Run 创建心跳表后按间隔写入心跳，直到ctx结束；只有建表失败时返回错误
+func (w *HeartbeatWriter) Run(ctx context.Context) error {
+	if err := w.Init(ctx); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.Beat(ctx); err != nil && ctx.Err() == nil {
+			w.logger.WarnContext(ctx, "failed to write heartbeat",
+				slog.String("table", w.table),
+				slog.Any("error", err),
+			)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
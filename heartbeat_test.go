@@ -0,0 +1,47 @@
+package icanal
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestHeartbeatWriter(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "source.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	writer := NewHeartbeatWriter(db, DialectSQLite, WithHeartbeatId("example"),
+		WithHeartbeatInterval(time.Millisecond), WithHeartbeatLogger(QuietLogger()))
+	done := make(chan error, 1)
+	go func() { done <- writer.Run(ctx) }()
+
+	// 每个间隔更新同一行
+	var first int64
+	waitFor(t, "heartbeat", func() bool {
+		return db.QueryRow(`SELECT ts FROM icanal_heartbeat WHERE id = 'example'`).Scan(&first) == nil
+	})
+	waitFor(t, "next heartbeat", func() bool {
+		var ts int64
+		_ = db.QueryRow(`SELECT ts FROM icanal_heartbeat WHERE id = 'example'`).Scan(&ts)
+		return ts > first
+	})
+
+	cancel()
+	if err = <-done; err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+	var rows int
+	if err = db.QueryRow(`SELECT COUNT(*) FROM icanal_heartbeat`).Scan(&rows); err != nil || rows != 1 {
+		t.Errorf("heartbeat rows = %d (%v), want 1", rows, err)
+	}
+}
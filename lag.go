@@ -0,0 +1,371 @@
+package icanal
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	LagWindowDefault        = time.Minute // 默认最大延迟的统计窗口
+	LagCheckIntervalDefault = time.Second // 默认Run检查阈值的间隔
+)
+
+// LagAlert 延迟越过阈值时的通知
+type LagAlert struct {
+	Threshold time.Duration // 越过的阈值
+	Lag       time.Duration // 当前延迟
+	Exceeded  bool          // true为超过阈值，false为恢复到阈值以下
+	Gtid      string        // 最后一个Entry的gtid
+}
+
+// LagStats 延迟快照
+type LagStats struct {
+	Lag            time.Duration // 当前延迟，当前时间减去最后一个Entry的binlog执行时间；没有观测过时为0
+	MaxLag         time.Duration // 窗口内的最大延迟
+	SinceLastEvent time.Duration // 距离最后一个行变更或DDL(不含心跳表)的时间；没有收到过时为0
+	ExecuteTime    time.Time     // 最后一个Entry的binlog执行时间
+	Gtid           string        // 最后一个Entry的gtid
+}
+
+type lagSample struct {
+	at  time.Time
+	lag time.Duration
+}
+
+type lagThreshold struct {
+	threshold time.Duration
+	callback  func(LagAlert)
+	exceeded  bool
+}
+
+// LagTracker 复制延迟跟踪；延迟为当前时间减去最后处理的Entry的binlog执行时间
+//
+// 没有新的Entry时延迟随时间增长，阈值在Observe时和Run的定时检查中判断。
+// 收到的心跳(HEARTBEAT、MHEARTBEAT)、事务头尾和心跳表的行变更同样更新执行时间，但不算作事件。
+// 源库空闲时canal不产生Entry，延迟会一直增长；可以用HeartbeatWriter定时写入心跳表，并用WithLagHeartbeatTable指定该表。
+type LagTracker struct {
+	mutex          sync.Mutex
+	heartbeatTable string // 心跳表，"schema.table"
+	window         time.Duration
+	interval       time.Duration
+	thresholds     []*lagThreshold
+	samples        []lagSample // 窗口内的延迟，按时间顺序
+	lastEvent      time.Time
+	executeTime    time.Time
+	gtid           string
+	now            func() time.Time
+}
+
+type LagOption func(*LagTracker)
+
+// WithLagWindow 设置最大延迟的统计窗口
+func WithLagWindow(window time.Duration) LagOption {
+	return func(t *LagTracker) {
+		t.window = window
+	}
+}
+
+// WithLagCheckInterval 设置Run检查阈值的间隔
+func WithLagCheckInterval(interval time.Duration) LagOption {
+	return func(t *LagTracker) {
+		t.interval = interval
+	}
+}
+
+// WithLagThreshold 延迟超过threshold和恢复到threshold以下时调用callback；可设置多个
+func WithLagThreshold(threshold time.Duration, callback func(LagAlert)) LagOption {
+	return func(t *LagTracker) {
+		t.thresholds = append(t.thresholds, &lagThreshold{threshold: threshold, callback: callback})
+	}
+}
+
+// WithLagHeartbeatTable 心跳表的行变更按心跳处理，不算作事件；通常是HeartbeatWriter写入的表
+func WithLagHeartbeatTable(schema, table string) LagOption {
+	return func(t *LagTracker) {
+		t.heartbeatTable = schema + "." + table
+	}
+}
+
+// NewLagTracker 新建延迟跟踪
+func NewLagTracker(opts ...LagOption) *LagTracker {
+	t := &LagTracker{
+		window:   LagWindowDefault,
+		interval: LagCheckIntervalDefault,
+		now:      time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+// isEvent 是否为行变更或DDL；心跳、事务头尾和心跳表的行变更不算
+func (t *LagTracker) isEvent(entry *Entry) bool {
+	if entry.GetEntryType() != EntryType_ROWDATA || entry.GetHeader().GetEventType() == EventType_MHEARTBEAT {
+		return false
+	}
+	header := entry.GetHeader()
+	return t.heartbeatTable == "" || header.GetSchemaName()+"."+header.GetTableName() != t.heartbeatTable
+}
+
+// Observe 观测处理完成的Entry；没有执行时间的Entry被忽略
+func (t *LagTracker) Observe(entries []*Entry) {
+	var last *Header
+	event := false
+	for _, entry := range entries {
+		if entry.GetHeader().GetExecuteTime() <= 0 {
+			continue
+		}
+		last = entry.GetHeader()
+		if t.isEvent(entry) {
+			event = true
+		}
+	}
+	if last == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	now := t.now()
+	t.executeTime = time.UnixMilli(last.GetExecuteTime())
+	if last.GetGtid() != "" {
+		t.gtid = last.GetGtid()
+	}
+	if event {
+		t.lastEvent = now
+	}
+	alerts := t.sample(now)
+	t.mutex.Unlock()
+
+	notify(alerts)
+}
+
+// Run 按间隔用当前时间记录延迟并检查阈值，直到ctx结束；没有新的Entry时只有定时检查能发现延迟增长
+func (t *LagTracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.check()
+		}
+	}
+}
+
+// check 用当前时间记录延迟并检查阈值；没有观测过Entry时跳过
+func (t *LagTracker) check() {
+	t.mutex.Lock()
+	if t.executeTime.IsZero() {
+		t.mutex.Unlock()
+		return
+	}
+	alerts := t.sample(t.now())
+	t.mutex.Unlock()
+
+	notify(alerts)
+}
+
+// sample 记录当前延迟，返回越过阈值的通知；调用方持有锁
+func (t *LagTracker) sample(now time.Time) []pendingAlert {
+	lag := t.current(now)
+	t.samples = append(t.prune(now), lagSample{at: now, lag: lag})
+	return t.crossed(lag)
+}
+
+// current 当前延迟；调用方持有锁
+func (t *LagTracker) current(now time.Time) time.Duration {
+	if t.executeTime.IsZero() {
+		return 0
+	}
+	return max(now.Sub(t.executeTime), 0)
+}
+
+// ObserveMessage 观测一个批次；延迟解析的消息会在这里解析一次
+func (t *LagTracker) ObserveMessage(message *Message) error {
+	if message == nil || message.Id == -1 {
+		return nil
+	}
+	entries, err := message.GetEntries()
+	if err != nil {
+		return err
+	}
+	t.Observe(entries)
+	return nil
+}
+
+type pendingAlert struct {
+	LagAlert
+	callback func(LagAlert)
+}
+
+// notify 在锁外调用回调
+func notify(alerts []pendingAlert) {
+	for _, alert := range alerts {
+		alert.callback(alert.LagAlert)
+	}
+}
+
+// crossed 更新阈值状态，返回需要通知的回调；调用方持有锁
+func (t *LagTracker) crossed(lag time.Duration) []pendingAlert {
+	var alerts []pendingAlert
+	for _, threshold := range t.thresholds {
+		exceeded := lag > threshold.threshold
+		if exceeded == threshold.exceeded {
+			continue
+		}
+		threshold.exceeded = exceeded
+		alerts = append(alerts, pendingAlert{
+			LagAlert: LagAlert{Threshold: threshold.threshold, Lag: lag, Exceeded: exceeded, Gtid: t.gtid},
+			callback: threshold.callback,
+		})
+	}
+	return alerts
+}
+
+// prune 去掉窗口外的延迟；调用方持有锁
+func (t *LagTracker) prune(now time.Time) []lagSample {
+	i := 0
+	for i < len(t.samples) && now.Sub(t.samples[i].at) > t.window {
+		i++
+	}
+	t.samples = t.samples[i:]
+	return t.samples
+}
+
+// Lag 当前延迟
+func (t *LagTracker) Lag() time.Duration {
+	return t.Stats().Lag
+}
+
+// MaxLag 窗口内的最大延迟
+func (t *LagTracker) MaxLag() time.Duration {
+	return t.Stats().MaxLag
+}
+
+// SinceLastEvent 距离最后一个行变更或DDL的时间；没有收到过时为0
+func (t *LagTracker) SinceLastEvent() time.Duration {
+	return t.Stats().SinceLastEvent
+}
+
+// Stats 延迟快照
+func (t *LagTracker) Stats() LagStats {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.now()
+	stats := LagStats{
+		Lag:         t.current(now),
+		MaxLag:      t.current(now),
+		ExecuteTime: t.executeTime,
+		Gtid:        t.gtid,
+	}
+	for _, sample := range t.prune(now) {
+		stats.MaxLag = max(stats.MaxLag, sample.lag)
+	}
+	if !t.lastEvent.IsZero() {
+		stats.SinceLastEvent = now.Sub(t.lastEvent)
+	}
+	return stats
+}
+
+// lagConnector 观测延迟的连接器；批次在确认后才计入延迟
+type lagConnector struct {
+	Connector
+	tracker *LagTracker
+	mutex   sync.Mutex
+	pending []*Message // 未确认的批次，按拉取顺序
+}
+
+// NewLagConnector 包装连接器，确认过的批次交给tracker观测；可用于简单、集群和回放连接器
+func NewLagConnector(connector Connector, tracker *LagTracker) Connector {
+	return &lagConnector{
+		Connector: connector,
+		tracker:   tracker,
+	}
+}
+
+func (c *lagConnector) Get(ctx context.Context, batchSize int32, timeout time.Duration) (*Message, error) {
+	message, err := c.Connector.Get(ctx, batchSize, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.tracker.ObserveMessage(message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func (c *lagConnector) GetWithoutAck(ctx context.Context, batchSize int32, timeout time.Duration) (*Message, error) {
+	message, err := c.Connector.GetWithoutAck(ctx, batchSize, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if message != nil && message.Id != -1 {
+		c.mutex.Lock()
+		c.pending = append(c.pending, message)
+		c.mutex.Unlock()
+	}
+	return message, nil
+}
+
+// Ack 确认后观测该批次；之前未确认的批次一并丢弃
+func (c *lagConnector) Ack(ctx context.Context, batchId int64) error {
+	if err := c.Connector.Ack(ctx, batchId); err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	var acked *Message
+	for i, message := range c.pending {
+		if message.Id == batchId {
+			acked = message
+			c.pending = c.pending[i+1:]
+			break
+		}
+	}
+	c.mutex.Unlock()
+
+	if acked == nil {
+		return nil
+	}
+	return c.tracker.ObserveMessage(acked)
+}
+
+// Connect 重新连接后服务端会重新投递未确认的批次
+func (c *lagConnector) Connect(ctx context.Context) error {
+	c.reset()
+	return c.Connector.Connect(ctx)
+}
+
+// Disconnect 断开后未确认的批次会被重新投递
+func (c *lagConnector) Disconnect(ctx context.Context) error {
+	c.reset()
+	return c.Connector.Disconnect(ctx)
+}
+
+// Rollback 回滚的批次及之后的批次会重新投递；batchId为0时回滚所有未确认的批次
+func (c *lagConnector) Rollback(ctx context.Context, batchId int64) error {
+	c.mutex.Lock()
+	for i, message := range c.pending {
+		if batchId == 0 || message.Id == batchId {
+			c.pending = c.pending[:i]
+			break
+		}
+	}
+	c.mutex.Unlock()
+
+	return c.Connector.Rollback(ctx, batchId)
+}
+
+// reset 清空未确认的批次
+func (c *lagConnector) reset() {
+	c.mutex.Lock()
+	c.pending = nil
+	c.mutex.Unlock()
+}
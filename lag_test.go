@@ -0,0 +1,160 @@
+package icanal
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func lagEntry(entryType EntryType, eventType EventType, executeTime time.Time) *Entry {
+	header := newTestHeader(eventType)
+	header.ExecuteTime = executeTime.UnixMilli()
+	return &Entry{Header: header, EntryTypePresent: &Entry_EntryType{EntryType: entryType}}
+}
+
+func TestLagTracker(t *testing.T) {
+	base := time.UnixMilli(1589373515000)
+	now := base
+
+	var alerts []LagAlert
+	tracker := NewLagTracker(
+		WithLagWindow(10*time.Second),
+		WithLagThreshold(5*time.Second, func(alert LagAlert) { alerts = append(alerts, alert) }),
+	)
+	tracker.now = func() time.Time { return now }
+
+	tests := []struct {
+		name           string
+		advance        time.Duration
+		entry          *Entry
+		wantLag        time.Duration
+		wantMaxLag     time.Duration
+		wantSinceEvent time.Duration
+		wantAlerts     int
+	}{
+		{"event", 2 * time.Second, lagEntry(EntryType_ROWDATA, EventType_INSERT, base), 2 * time.Second, 2 * time.Second, 0, 0},
+		{"falling behind", 6 * time.Second, lagEntry(EntryType_ROWDATA, EventType_UPDATE, base), 8 * time.Second, 8 * time.Second, 0, 1},
+		{"heartbeat catches up", time.Second, lagEntry(EntryType_HEARTBEAT, EventType_MHEARTBEAT, base.Add(9*time.Second)), 0, 8 * time.Second, time.Second, 2},
+		{"idle", 11 * time.Second, nil, 11 * time.Second, 11 * time.Second, 12 * time.Second, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.advance)
+			if tt.entry != nil {
+				tracker.Observe([]*Entry{tt.entry})
+			}
+
+			stats := tracker.Stats()
+			if stats.Lag != tt.wantLag || stats.MaxLag != tt.wantMaxLag || stats.SinceLastEvent != tt.wantSinceEvent {
+				t.Errorf("Stats() = (%v, %v, %v), want (%v, %v, %v)", stats.Lag, stats.MaxLag, stats.SinceLastEvent,
+					tt.wantLag, tt.wantMaxLag, tt.wantSinceEvent)
+			}
+			if len(alerts) != tt.wantAlerts {
+				t.Errorf("alerts = %v, want %d", alerts, tt.wantAlerts)
+			}
+		})
+	}
+
+	// 空闲时延迟增长，由定时检查发现
+	tracker.check()
+	if len(alerts) != 3 || !alerts[0].Exceeded || alerts[1].Exceeded || !alerts[2].Exceeded || alerts[2].Lag != 11*time.Second {
+		t.Errorf("alerts = %v, want exceeded, recovered, exceeded when idle", alerts)
+	}
+}
+
+func TestLagTracker_HeartbeatTable(t *testing.T) {
+	base := time.UnixMilli(1589373515000)
+	now := base.Add(time.Second)
+	tracker := NewLagTracker(WithLagHeartbeatTable("test", "icanal_heartbeat"))
+	tracker.now = func() time.Time { return now }
+
+	tracker.Observe([]*Entry{lagEntry(EntryType_ROWDATA, EventType_INSERT, base)})
+
+	// 心跳表的事务追上延迟，但不算作事件
+	now = now.Add(10 * time.Second)
+	heartbeat := lagEntry(EntryType_ROWDATA, EventType_UPDATE, now)
+	heartbeat.Header.TableName = "icanal_heartbeat"
+	tracker.Observe([]*Entry{
+		lagEntry(EntryType_TRANSACTIONBEGIN, EventType_QUERY, now),
+		heartbeat,
+		lagEntry(EntryType_TRANSACTIONEND, EventType_QUERY, now),
+	})
+
+	stats := tracker.Stats()
+	if stats.Lag != 0 || stats.SinceLastEvent != 10*time.Second {
+		t.Errorf("Stats() = (%v, %v), want (0, 10s)", stats.Lag, stats.SinceLastEvent)
+	}
+}
+
+func TestLagTracker_Run(t *testing.T) {
+	alerts := make(chan LagAlert, 1)
+	tracker := NewLagTracker(
+		WithLagCheckInterval(time.Millisecond),
+		WithLagThreshold(20*time.Millisecond, func(alert LagAlert) { alerts <- alert }),
+	)
+	tracker.Observe([]*Entry{lagEntry(EntryType_ROWDATA, EventType_INSERT, time.Now())})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go tracker.Run(ctx)
+
+	// 没有新的Entry，延迟超过阈值由定时检查通知
+	select {
+	case alert := <-alerts:
+		if !alert.Exceeded || alert.Lag <= 20*time.Millisecond {
+			t.Errorf("alert = %+v, want exceeded", alert)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no alert")
+	}
+}
+
+func TestLagConnector(t *testing.T) {
+	ctx := context.Background()
+	tracker := NewLagTracker()
+	connector := NewLagConnector(&messageConnector{
+		Connector: &recordConnector{},
+		messages:  []*Message{{Id: 1, Entries: testEntries()}},
+	}, tracker)
+
+	message, err := connector.GetWithoutAck(ctx, 100, time.Second)
+	if err != nil {
+		t.Fatalf("GetWithoutAck() error = %v", err)
+	}
+	if gtid := tracker.Stats().Gtid; gtid != "" {
+		t.Errorf("Gtid = %q before ack, want empty", gtid)
+	}
+
+	if err = connector.Ack(ctx, message.Id); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if stats := tracker.Stats(); stats.Gtid != newTestHeader(EventType_INSERT).GetGtid() || stats.Lag <= 0 {
+		t.Errorf("Stats() = %+v after ack", stats)
+	}
+}
+
+func TestLagConnector_Rollback(t *testing.T) {
+	ctx := context.Background()
+	tracker := NewLagTracker()
+	connector := NewLagConnector(&messageConnector{
+		Connector: &recordConnector{},
+		messages:  []*Message{{Id: 1, Entries: testEntries()}, {Id: 2, Entries: testEntries()}},
+	}, tracker)
+
+	for i := 0; i < 2; i++ {
+		if _, err := connector.GetWithoutAck(ctx, 100, time.Second); err != nil {
+			t.Fatalf("GetWithoutAck() error = %v", err)
+		}
+	}
+
+	// 只回滚第二个批次，第一个批次确认后仍然计入延迟
+	if err := connector.Rollback(ctx, 2); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if err := connector.Ack(ctx, 1); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if gtid := tracker.Stats().Gtid; gtid != newTestHeader(EventType_INSERT).GetGtid() {
+		t.Errorf("Gtid = %q after ack, want observed", gtid)
+	}
+}
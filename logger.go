@@ -0,0 +1,36 @@
+package icanal
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// 日志的公共属性名
+const (
+	LogKeyDestination = "destination"
+	LogKeyClientId    = "clientId"
+	LogKeyAddress     = "address"
+	LogKeyBatchId     = "batchId"
+)
+
+// QuietLogger 丢弃所有日志的logger；作为库嵌入时配合WithLogger等选项使用
+func QuietLogger() *slog.Logger {
+	return slog.New(slog.DiscardHandler)
+}
+
+// loggerOrDefault logger为nil时使用slog.Default()
+func loggerOrDefault(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return slog.Default()
+	}
+	return logger
+}
+
+// zkLogger 将go-zookeeper的日志转到slog
+type zkLogger struct {
+	logger *slog.Logger
+}
+
+func (l zkLogger) Printf(format string, args ...any) {
+	l.logger.Info(fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,54 @@
+package icanal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"testing"
+)
+
+// nodeClusterManager 返回固定节点的集群经理
+type nodeClusterManager struct {
+	address string
+}
+
+func (m *nodeClusterManager) Init(context.Context) error              { return nil }
+func (m *nodeClusterManager) GetNode(context.Context) (string, error) { return m.address, nil }
+func (m *nodeClusterManager) GetLock(context.Context) error           { return nil }
+func (m *nodeClusterManager) ReleaseLock(context.Context) error       { return nil }
+
+func TestWithLogger(t *testing.T) {
+	// 监听后立即关闭，连接会失败
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	connector := newClusterConnector("example", &nodeClusterManager{address: address},
+		WithLogger(logger), WithRetryTimes(2), WithRetryInterval(0))
+	if err = connector.Connect(context.Background()); !errors.Is(err, ErrOverRetryTimes) {
+		t.Fatalf("Connect() error = %v, want %v", err, ErrOverRetryTimes)
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 3 {
+		t.Fatalf("logged %d lines, want 3:\n%s", len(lines), buf.String())
+	}
+	for _, line := range lines {
+		record := make(map[string]any)
+		if err = json.Unmarshal(line, &record); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		if record[LogKeyDestination] != "example" || record[LogKeyClientId] != float64(ClientIdDefault) {
+			t.Errorf("record = %v, want destination and clientId", record)
+		}
+	}
+}
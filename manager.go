@@ -0,0 +1,351 @@
+package icanal
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Destination 托管的消费目标
+type Destination struct {
+	Name            string           // destination名称
+	Filter          string           // 订阅的filter
+	Handler         Handler          // 消息处理函数
+	Options         []Option         // 连接器选项
+	ConsumerOptions []ConsumerOption // 消费者选项
+}
+
+// Manager 多destination消费经理；共享一个zookeeper连接，每个destination独立消费、独立重启
+type Manager struct {
+	zkServer       []string
+	sessionTimeout time.Duration
+	restartWait    time.Duration
+	metrics        Metrics
+	tracing        bool
+	tracerProvider trace.TracerProvider
+	logger         *slog.Logger
+	health         *HealthHandler
+	zkConn         zkClient
+	connect        func() (zkClient, <-chan zk.Event, error) // 连接zookeeper，测试时替换
+	done           <-chan struct{}                           // Start的ctx结束后关闭
+	mutex          sync.Mutex
+	destinations   map[string]*managedDestination
+}
+
+type managedDestination struct {
+	clusterManager ClusterManager
+	cancel         context.CancelFunc
+	done           chan struct{}
+	mutex          sync.Mutex
+	cancelRun      context.CancelFunc // 结束当前这一次消费，用于会话失效后重新获取锁
+}
+
+// restart 结束当前这一次消费，消费循环会重新连接并获取锁
+func (md *managedDestination) restart() {
+	md.mutex.Lock()
+	defer md.mutex.Unlock()
+
+	if md.cancelRun != nil {
+		md.cancelRun()
+	}
+}
+
+type ManagerOption func(*Manager)
+
+// WithRestartInterval 消费失败后重启的等待时间
+func WithRestartInterval(restartInterval time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.restartWait = restartInterval
+	}
+}
+
+// WithManagerMetrics 记录所有destination的指标
+func WithManagerMetrics(metrics Metrics) ManagerOption {
+	return func(m *Manager) {
+		m.metrics = metrics
+	}
+}
+
+// WithManagerTracing 为所有destination的连接器记录span；tracerProvider为nil时使用全局TracerProvider
+func WithManagerTracing(tracerProvider trace.TracerProvider) ManagerOption {
+	return func(m *Manager) {
+		m.tracing = true
+		m.tracerProvider = tracerProvider
+	}
+}
+
+// WithManagerLogger 设置日志；同时用于zookeeper连接和所有destination，destination自己的选项优先
+func WithManagerLogger(logger *slog.Logger) ManagerOption {
+	return func(m *Manager) {
+		m.logger = logger
+	}
+}
+
+// WithManagerHealth 把所有destination的状态注册到health，移除destination时一并移除
+func WithManagerHealth(health *HealthHandler) ManagerOption {
+	return func(m *Manager) {
+		m.health = health
+	}
+}
+
+// NewManager 新建多destination消费经理
+func NewManager(zkServer []string, zkSessionTimeout time.Duration, opts ...ManagerOption) *Manager {
+	m := &Manager{
+		zkServer:       zkServer,
+		sessionTimeout: zkSessionTimeout,
+		restartWait:    RetryIntervalDefault,
+		destinations:   make(map[string]*managedDestination),
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	m.connect = func() (zkClient, <-chan zk.Event, error) {
+		return zk.Connect(m.zkServer, m.sessionTimeout, zk.WithLogger(zkLogger{m.log()}))
+	}
+
+	return m
+}
+
+// Start 连接zookeeper；ctx结束时所有destination停止消费
+func (m *Manager) Start(ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.zkConn != nil {
+		return nil
+	}
+
+	zkConn, events, err := m.connect()
+	if err != nil {
+		m.log().ErrorContext(ctx, "connect zookeeper error",
+			slog.Any("error", err),
+			slog.Any("zkServer", m.zkServer),
+		)
+		return err
+	}
+
+	m.zkConn = zkConn
+	m.done = ctx.Done()
+
+	go m.watch(ctx, events)
+
+	return nil
+}
+
+// watch ctx结束时停止所有destination的消费；会话失效后所有锁节点都被删除，重启所有destination的消费以重新获取锁。
+// 连接关闭时结束
+func (m *Manager) watch(ctx context.Context, events <-chan zk.Event) {
+	for {
+		select {
+		case <-ctx.Done():
+			m.mutex.Lock()
+			for _, md := range m.destinations {
+				md.cancel()
+			}
+			m.mutex.Unlock()
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if !isSessionExpired(event) {
+				continue
+			}
+
+			m.log().WarnContext(ctx, "zookeeper session expired, re-acquiring locks")
+
+			m.mutex.Lock()
+			for _, md := range m.destinations {
+				md.restart()
+			}
+			m.mutex.Unlock()
+		}
+	}
+}
+
+// Add 添加destination并开始消费；消费持续到Remove、Stop或者Start的ctx结束，不受ctx取消的影响
+func (m *Manager) Add(ctx context.Context, destination Destination) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.zkConn == nil {
+		return ErrManagerNotStarted
+	}
+	select {
+	case <-m.done:
+		return ErrManagerNotStarted
+	default:
+	}
+	if _, ok := m.destinations[destination.Name]; ok {
+		return ErrDestinationExists
+	}
+
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	md := &managedDestination{
+		clusterManager: newSharedClusterNodeManager(destination.Name, m.zkConn, WithClusterManagerLogger(m.logger)),
+		cancel:         cancel,
+		done:           make(chan struct{}),
+	}
+	m.destinations[destination.Name] = md
+
+	go m.run(runCtx, destination, md)
+
+	m.log().InfoContext(ctx, "destination added",
+		slog.String(LogKeyDestination, destination.Name),
+	)
+
+	return nil
+}
+
+// Remove 停止并移除destination；会释放该destination的锁
+func (m *Manager) Remove(ctx context.Context, name string) error {
+	m.mutex.Lock()
+	md, ok := m.destinations[name]
+	if ok {
+		delete(m.destinations, name)
+	}
+	m.mutex.Unlock()
+
+	if !ok {
+		return ErrDestinationNotFound
+	}
+
+	return m.stop(ctx, name, md)
+}
+
+// Destinations 当前托管的destination名称
+func (m *Manager) Destinations() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	names := make([]string, 0, len(m.destinations))
+	for name := range m.destinations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Stop 停止所有destination并关闭zookeeper连接
+func (m *Manager) Stop(ctx context.Context) error {
+	m.mutex.Lock()
+	destinations := m.destinations
+	m.destinations = make(map[string]*managedDestination)
+	zkConn := m.zkConn
+	m.zkConn = nil
+	m.mutex.Unlock()
+
+	var lastErr error
+	for name, md := range destinations {
+		if err := m.stop(ctx, name, md); err != nil {
+			lastErr = err
+		}
+	}
+
+	if zkConn != nil {
+		zkConn.Close()
+	}
+
+	return lastErr
+}
+
+// log 经理自身的日志
+func (m *Manager) log() *slog.Logger {
+	return loggerOrDefault(m.logger)
+}
+
+// stop 停止消费并释放锁；ctx结束时不再等待消费退出，但仍然释放锁，避免锁节点残留到会话超时
+func (m *Manager) stop(ctx context.Context, name string, md *managedDestination) error {
+	md.cancel()
+
+	var err error
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-md.done:
+	}
+
+	if m.health != nil {
+		m.health.Remove(name)
+	}
+
+	if releaseErr := md.clusterManager.ReleaseLock(context.WithoutCancel(ctx)); releaseErr != nil {
+		return errors.Join(err, releaseErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	m.log().InfoContext(ctx, "destination removed",
+		slog.String(LogKeyDestination, name),
+	)
+
+	return nil
+}
+
+// run 消费循环；失败后等待一段时间重启，直到ctx结束
+func (m *Manager) run(ctx context.Context, destination Destination, md *managedDestination) {
+	defer close(md.done)
+
+	opts := append([]ConsumerOption{WithConsumerFilter(destination.Filter)}, destination.ConsumerOptions...)
+	connectorOpts := destination.Options
+	if m.logger != nil {
+		connectorOpts = append([]Option{WithLogger(m.logger)}, connectorOpts...)
+		opts = append([]ConsumerOption{
+			WithConsumerLogger(m.logger.With(slog.String(LogKeyDestination, destination.Name))),
+		}, opts...)
+	}
+	var connector Connector = newClusterConnector(destination.Name, md.clusterManager, connectorOpts...)
+	if m.health != nil {
+		connector = NewHealthConnector(connector, destination.Name, m.health)
+	}
+	if m.metrics != nil {
+		connector = NewMetricsConnector(connector, destination.Name, m.metrics)
+	}
+	if m.tracing {
+		connector = NewTracingConnector(connector, destination.Name, m.tracerProvider)
+		opts = append([]ConsumerOption{WithTracerProvider(m.tracerProvider)}, opts...)
+	}
+	consumer := NewConsumer(connector, destination.Handler, opts...)
+
+	for {
+		runCtx, cancelRun := context.WithCancel(ctx)
+		md.mutex.Lock()
+		md.cancelRun = cancelRun
+		md.mutex.Unlock()
+
+		err := consumer.Run(runCtx)
+		cancelRun()
+		if ctx.Err() != nil {
+			return
+		}
+
+		// 会话失效，立即重新连接获取锁
+		if runCtx.Err() != nil || errors.Is(err, ErrLockLost) {
+			m.log().WarnContext(ctx, "destination lock lost, reconnecting",
+				slog.String(LogKeyDestination, destination.Name),
+			)
+			continue
+		}
+
+		m.log().WarnContext(ctx, "destination consumer stopped, restarting",
+			slog.String(LogKeyDestination, destination.Name),
+			slog.Any("error", err),
+		)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(m.restartWait):
+		}
+	}
+}
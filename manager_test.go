@@ -0,0 +1,186 @@
+package icanal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+)
+
+// newTestManager 连接假zookeeper的经理；example的运行节点指向server
+func newTestManager(t *testing.T, server *fakeCanalServer) (*Manager, *fakeZk) {
+	t.Helper()
+
+	zkConn := newFakeZk()
+	if err := checkRootPath(zkConn, getDestinationCluster("example")); err != nil {
+		t.Fatalf("checkRootPath() error = %v", err)
+	}
+	running := fmt.Sprintf(`{"address":%q,"active":true}`, server.Address())
+	if _, err := zkConn.Create(getDestinationServerRunning("example"), []byte(running), 0, nil); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	m := NewManager(nil, time.Second, WithRestartInterval(10*time.Millisecond), WithManagerLogger(QuietLogger()))
+	m.connect = func() (zkClient, <-chan zk.Event, error) {
+		return zkConn, zkConn.events, nil
+	}
+	return m, zkConn
+}
+
+// waitFor 等待条件成立
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestManager(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeCanalServer(t, testEntries())
+	m, zkConn := newTestManager(t, server)
+	defer m.Stop(ctx)
+
+	destination := Destination{
+		Name:    "example",
+		Handler: func(context.Context, *Message) error { return nil },
+	}
+	if err := m.Add(ctx, destination); !errors.Is(err, ErrManagerNotStarted) {
+		t.Errorf("Add() before Start error = %v, want %v", err, ErrManagerNotStarted)
+	}
+	if err := m.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := m.Add(ctx, destination); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := m.Add(ctx, destination); !errors.Is(err, ErrDestinationExists) {
+		t.Errorf("Add() twice error = %v, want %v", err, ErrDestinationExists)
+	}
+
+	lockNodes := func() []string {
+		children, _, _ := zkConn.Children(getLockPath("example"))
+		return children
+	}
+	waitFor(t, "batch acked", func() bool { return len(server.Acked()) == 1 })
+	if nodes := lockNodes(); len(nodes) != 1 {
+		t.Fatalf("lock nodes = %v, want one", nodes)
+	}
+
+	if err := m.Remove(ctx, "example"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if nodes := lockNodes(); len(nodes) != 0 {
+		t.Errorf("lock nodes = %v after Remove, want none", nodes)
+	}
+	if names := m.Destinations(); len(names) != 0 {
+		t.Errorf("Destinations() = %v, want empty", names)
+	}
+	if err := m.Remove(ctx, "example"); !errors.Is(err, ErrDestinationNotFound) {
+		t.Errorf("Remove() twice error = %v, want %v", err, ErrDestinationNotFound)
+	}
+}
+
+func TestManager_NotStarted(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(nil, time.Second, WithManagerLogger(QuietLogger()))
+
+	destination := Destination{
+		Name:    "example",
+		Handler: func(context.Context, *Message) error { return nil },
+	}
+	if err := m.Add(ctx, destination); !errors.Is(err, ErrManagerNotStarted) {
+		t.Errorf("Add() before Start error = %v, want %v", err, ErrManagerNotStarted)
+	}
+	if err := m.Remove(ctx, "example"); !errors.Is(err, ErrDestinationNotFound) {
+		t.Errorf("Remove() error = %v, want %v", err, ErrDestinationNotFound)
+	}
+	if names := m.Destinations(); len(names) != 0 {
+		t.Errorf("Destinations() = %v, want empty", names)
+	}
+	if err := m.Stop(ctx); err != nil {
+		t.Errorf("Stop() error = %v", err)
+	}
+}
+
+func TestManager_Canceled(t *testing.T) {
+	server := newFakeCanalServer(t, testEntries())
+	m, zkConn := newTestManager(t, server)
+	defer m.Stop(context.Background())
+
+	startCtx, cancelStart := context.WithCancel(context.Background())
+	if err := m.Start(startCtx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	// Add的ctx结束不影响消费
+	addCtx, cancelAdd := context.WithCancel(context.Background())
+	destination := Destination{
+		Name:    "example",
+		Handler: func(context.Context, *Message) error { return nil },
+	}
+	if err := m.Add(addCtx, destination); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	cancelAdd()
+	server.Push(testEntries())
+	waitFor(t, "batches acked", func() bool { return len(server.Acked()) == 2 })
+
+	// ctx已结束时仍然释放锁
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.Remove(canceled, "example"); err != nil && !errors.Is(err, context.Canceled) {
+		t.Errorf("Remove() error = %v, want nil or %v", err, context.Canceled)
+	}
+	if children, _, _ := zkConn.Children(getLockPath("example")); len(children) != 0 {
+		t.Errorf("lock nodes = %v after Remove, want none", children)
+	}
+
+	cancelStart()
+	waitFor(t, "manager stopped", func() bool {
+		return errors.Is(m.Add(context.Background(), destination), ErrManagerNotStarted)
+	})
+}
+
+func TestManager_SessionExpired(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeCanalServer(t, testEntries())
+	m, zkConn := newTestManager(t, server)
+	defer m.Stop(ctx)
+
+	if err := m.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	destination := Destination{
+		Name:    "example",
+		Handler: func(context.Context, *Message) error { return nil },
+	}
+	if err := m.Add(ctx, destination); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	waitFor(t, "batch acked", func() bool { return len(server.Acked()) == 1 })
+
+	zkConn.expire()
+	server.Push(testEntries())
+
+	// 新会话下重新创建锁节点并重新连接canal server
+	waitFor(t, "lock re-acquired", func() bool {
+		children, _, _ := zkConn.Children(getLockPath("example"))
+		if len(children) != 1 {
+			return false
+		}
+		zkConn.mutex.Lock()
+		defer zkConn.mutex.Unlock()
+		return zkConn.nodes[getLockPath("example")+"/"+children[0]].session == zkConn.session
+	})
+	waitFor(t, "reconnected", func() bool { return server.Connections() >= 2 })
+	waitFor(t, "batch acked after reconnect", func() bool { return len(server.Acked()) == 2 })
+}
@@ -0,0 +1,210 @@
+package icanal
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// MaxwellEvent Maxwell格式的行变更或者DDL
+type MaxwellEvent struct {
+	Database string         `json:"database"`
+	Table    string         `json:"table,omitempty"`
+	Type     string         `json:"type"`
+	Ts       int64          `json:"ts"` // binlog执行时间，秒
+	Xid      json.Number    `json:"xid,omitempty"`
+	Xoffset  *int           `json:"xoffset,omitempty"` // 事务内序号，事务最后一行输出commit代替
+	Commit   bool           `json:"commit,omitempty"`
+	Data     map[string]any `json:"data,omitempty"`
+	Old      map[string]any `json:"old,omitempty"`
+	Sql      string         `json:"sql,omitempty"` // DDL语句
+}
+
+// MaxwellEncoder Maxwell格式编码器
+//
+// 事务的xid取自TransactionEnd，因此行会被缓存到事务结束才输出，跨批次的事务会留在编码器中等待下一批。
+// 按批次确认时注意：确认的批次可能包含尚未输出的行。默认不输出DDL，与Maxwell默认的output_ddl=false一致。
+// 可以在多个goroutine中使用，但缓存的事务是共享的，同一个事务的Entry要按顺序交给同一个编码器。
+type MaxwellEncoder struct {
+	mutex     sync.Mutex
+	pending   []*MaxwellEvent
+	outputDdl bool
+}
+
+type MaxwellOption func(*MaxwellEncoder)
+
+// WithMaxwellOutputDDL 是否输出DDL，对应Maxwell的output_ddl；DDL立即输出，type为table-create、table-alter等
+func WithMaxwellOutputDDL(outputDdl bool) MaxwellOption {
+	return func(e *MaxwellEncoder) {
+		e.outputDdl = outputDdl
+	}
+}
+
+// NewMaxwellEncoder 新建Maxwell编码器
+func NewMaxwellEncoder(opts ...MaxwellOption) *MaxwellEncoder {
+	e := &MaxwellEncoder{}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Encode 编码一组Entry，返回其中已结束事务的全部行
+func (e *MaxwellEncoder) Encode(entries []*Entry) ([]*MaxwellEvent, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	var events []*MaxwellEvent
+
+	for _, entry := range entries {
+		switch entry.GetEntryType() {
+		case EntryType_ROWDATA:
+			ddl, err := e.appendRows(entry)
+			if err != nil {
+				return nil, err
+			}
+			if ddl != nil {
+				events = append(events, ddl)
+			}
+		case EntryType_TRANSACTIONEND:
+			end := &TransactionEnd{}
+			if err := proto.Unmarshal(entry.GetStoreValue(), end); err != nil {
+				return nil, errors.Join(ErrUnmarshal, err)
+			}
+			events = append(events, e.commit(end.GetTransactionId())...)
+		}
+	}
+
+	return events, nil
+}
+
+// Flush 返回并清空未结束事务的行；这些行没有xid和commit标记
+func (e *MaxwellEncoder) Flush() []*MaxwellEvent {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	events := e.pending
+	e.pending = nil
+	return events
+}
+
+// appendRows 缓存行变更；输出DDL时返回DDL事件
+func (e *MaxwellEncoder) appendRows(entry *Entry) (*MaxwellEvent, error) {
+	rowChange, err := ParseRowChange(entry)
+	if err != nil {
+		return nil, err
+	}
+	if rowChange.GetIsDdl() {
+		if !e.outputDdl {
+			return nil, nil
+		}
+		return maxwellDdl(entry.GetHeader(), rowChange), nil
+	}
+
+	eventType := rowChange.GetEventType()
+	if eventType != EventType_INSERT && eventType != EventType_UPDATE && eventType != EventType_DELETE {
+		return nil, nil
+	}
+
+	header := entry.GetHeader()
+	for _, rowData := range rowChange.GetRowDatas() {
+		event := &MaxwellEvent{
+			Database: header.GetSchemaName(),
+			Table:    header.GetTableName(),
+			Type:     strings.ToLower(eventType.String()),
+			Ts:       header.GetExecuteTime() / 1000,
+		}
+
+		if eventType == EventType_DELETE {
+			event.Data = maxwellRow(rowData.GetBeforeColumns(), nil)
+		} else {
+			event.Data = maxwellRow(rowData.GetAfterColumns(), nil)
+		}
+
+		if eventType == EventType_UPDATE {
+			updated := make(map[string]bool)
+			for _, column := range rowData.GetAfterColumns() {
+				if column.GetUpdated() {
+					updated[column.GetName()] = true
+				}
+			}
+			if len(updated) > 0 {
+				event.Old = maxwellRow(rowData.GetBeforeColumns(), updated)
+			}
+		}
+
+		e.pending = append(e.pending, event)
+	}
+
+	return nil, nil
+}
+
+// maxwellDdl DDL事件；不能识别的DDL类型为ddl
+func maxwellDdl(header *Header, rowChange *RowChange) *MaxwellEvent {
+	target := "table"
+	if header.GetTableName() == "" {
+		target = "database"
+	}
+
+	action := "ddl"
+	switch rowChange.GetEventType() {
+	case EventType_CREATE:
+		action = target + "-create"
+	case EventType_ERASE:
+		action = target + "-drop"
+	case EventType_ALTER, EventType_RENAME, EventType_CINDEX, EventType_DINDEX:
+		action = target + "-alter"
+	}
+
+	database := header.GetSchemaName()
+	if database == "" {
+		database = rowChange.GetDdlSchemaName()
+	}
+
+	return &MaxwellEvent{
+		Database: database,
+		Table:    header.GetTableName(),
+		Type:     action,
+		Ts:       header.GetExecuteTime() / 1000,
+		Sql:      rowChange.GetSql(),
+	}
+}
+
+// commit 事务结束，给缓存的行设置xid，最后一行标记commit
+func (e *MaxwellEncoder) commit(transactionId string) []*MaxwellEvent {
+	events := e.pending
+	e.pending = nil
+
+	var xid json.Number
+	if _, err := strconv.ParseUint(transactionId, 10, 64); err == nil {
+		xid = json.Number(transactionId)
+	}
+
+	for i, event := range events {
+		event.Xid = xid
+		if i == len(events)-1 {
+			event.Commit = true
+		} else {
+			xoffset := i
+			event.Xoffset = &xoffset
+		}
+	}
+
+	return events
+}
+
+// maxwellRow 列转换为Maxwell的行；only不为nil时只保留其中的列
+func maxwellRow(columns []*Column, only map[string]bool) map[string]any {
+	row := make(map[string]any, len(columns))
+	for _, column := range columns {
+		if only != nil && !only[column.GetName()] {
+			continue
+		}
+		row[column.GetName()] = columnValue(column)
+	}
+	return row
+}
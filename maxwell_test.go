@@ -0,0 +1,132 @@
+package icanal
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+func TestMaxwellEncoder_Encode(t *testing.T) {
+	entries := testEntries()
+	encoder := NewMaxwellEncoder()
+
+	// 事务跨批次：结束前不输出
+	events, err := encoder.Encode(entries[:3])
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("Encode() before transaction end = %d events, want 0", len(events))
+	}
+	if events, err = encoder.Encode(entries[3:]); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	want := []string{
+		`{"database":"test","table":"user","type":"insert","ts":1589373515,"xid":1001,"xoffset":0,"data":{"age":null,"id":1,"name":"alice"}}`,
+		`{"database":"test","table":"user","type":"insert","ts":1589373515,"xid":1001,"xoffset":1,"data":{"age":20,"id":2,"name":"bob"}}`,
+		`{"database":"test","table":"user","type":"update","ts":1589373515,"xid":1001,"xoffset":2,"data":{"age":18,"id":1,"name":"alice"},"old":{"age":null}}`,
+		`{"database":"test","table":"user","type":"delete","ts":1589373515,"xid":1001,"commit":true,"data":{"age":20,"id":2,"name":"bob"}}`,
+	}
+	if len(events) != len(want) {
+		t.Fatalf("Encode() = %d events, want %d", len(events), len(want))
+	}
+	for i, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			t.Fatalf("Marshal() error = %v", err)
+		}
+		if string(data) != want[i] {
+			t.Errorf("event %d = %s, want %s", i, data, want[i])
+		}
+	}
+
+	if pending := encoder.Flush(); len(pending) != 0 {
+		t.Errorf("Flush() = %d events, want 0", len(pending))
+	}
+}
+
+func TestMaxwellEncoder_Flush(t *testing.T) {
+	encoder := NewMaxwellEncoder()
+	if _, err := encoder.Encode(testEntries()[:2]); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	pending := encoder.Flush()
+	if len(pending) != 2 {
+		t.Fatalf("Flush() = %d events, want 2", len(pending))
+	}
+	for _, event := range pending {
+		if event.Xid != "" || event.Commit || event.Xoffset != nil {
+			t.Errorf("Flush() event = %+v, want no transaction info", event)
+		}
+	}
+}
+
+func TestMaxwellEncoder_Concurrent(t *testing.T) {
+	encoder := NewMaxwellEncoder()
+
+	var (
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+		count int
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			events, err := encoder.Encode(testEntries())
+			if err != nil {
+				t.Errorf("Encode() error = %v", err)
+				return
+			}
+			mutex.Lock()
+			count += len(events)
+			mutex.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if count != 8*4 {
+		t.Errorf("Encode() = %d events, want %d", count, 8*4)
+	}
+}
+
+func TestMaxwellEncoder_DDL(t *testing.T) {
+	ddl := testEntries()[4]
+	create := newRowDataEntry(&Header{ExecuteTime: 1589373515000}, &RowChange{
+		EventTypePresent: &RowChange_EventType{EventType: EventType_CREATE},
+		IsDdlPresent:     &RowChange_IsDdl{IsDdl: true},
+		Sql:              "CREATE DATABASE shop",
+		DdlSchemaName:    "shop",
+	})
+
+	tests := []struct {
+		name      string
+		outputDdl bool
+		want      []string
+	}{
+		{"default", false, nil},
+		{"output ddl", true, []string{
+			`{"database":"test","table":"user","type":"table-alter","ts":1589373515,"sql":"ALTER TABLE user ADD COLUMN email varchar(255)"}`,
+			`{"database":"shop","type":"database-create","ts":1589373515,"sql":"CREATE DATABASE shop"}`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := NewMaxwellEncoder(WithMaxwellOutputDDL(tt.outputDdl)).Encode([]*Entry{ddl, create})
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if len(events) != len(tt.want) {
+				t.Fatalf("Encode() = %d events, want %d", len(events), len(tt.want))
+			}
+			for i, event := range events {
+				data, _ := json.Marshal(event)
+				if string(data) != tt.want[i] {
+					t.Errorf("event %d = %s, want %s", i, data, tt.want[i])
+				}
+			}
+		})
+	}
+}
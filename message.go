@@ -13,6 +13,9 @@ type Message struct {
 	Entries    []*Entry
 	Raw        bool
 	RawEntries any
+
+	body   []byte  // 服务端发送的canal.Messages原始字节，客户端过滤前，供Recorder录制
+	filter *Filter // 延迟解析时的客户端过滤器，在GetEntries中应用
 }
 
 func decodeMessages(packet *canal.Packet, lazyParseEntry bool) (*Message, error) {
@@ -30,7 +33,8 @@ func decodeMessages(packet *canal.Packet, lazyParseEntry bool) (*Message, error)
 		}
 
 		message := &Message{
-			Id: messages.GetBatchId(),
+			Id:   messages.GetBatchId(),
+			body: packet.GetBody(),
 		}
 
 		if lazyParseEntry {
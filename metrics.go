@@ -0,0 +1,183 @@
+package icanal
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Metrics 指标记录接口，PrometheusMetrics为prometheus实现
+type Metrics interface {
+	// BatchReceived 收到一个批次；空批次entries为0
+	BatchReceived(destination string, entries int, bytes int, latency time.Duration)
+	// RowsReceived 收到的行变更
+	RowsReceived(destination string, schema string, table string, eventType EventType, rows int)
+	// Acked 确认批次
+	Acked(destination string)
+	// RolledBack 回滚批次
+	RolledBack(destination string)
+	// Reconnected 重新连接
+	Reconnected(destination string)
+	// Error 发生错误，kind为ErrorKind的结果
+	Error(destination string, kind string)
+	// Lag 消费延迟，当前时间减去最后一个Entry的binlog执行时间
+	Lag(destination string, lag time.Duration)
+}
+
+// errorKinds 错误分类，按顺序匹配
+var errorKinds = []struct {
+	kind string
+	err  error
+}{
+	{"unsupported_version", ErrUnsupportedVersion},
+	{"handshake", ErrHandshake},
+	{"expected_packet_type", ErrExpectedPacketType},
+	{"auth", ErrAuth},
+	{"unmarshal", ErrUnmarshal},
+	{"compression_not_support", ErrCompressionNotSupport},
+	{"network", ErrNetwork},
+	{"over_retry_times", ErrOverRetryTimes},
+	{"subscribe", ErrSubscribe},
+	{"unsubscribe", ErrUnsubscribe},
+	{"batch_not_tracked", ErrBatchNotTracked},
+	{"invalid_filter", ErrInvalidFilter},
+	{"lock_lost", ErrLockLost},
+	{"canceled", context.Canceled},
+	{"deadline_exceeded", context.DeadlineExceeded},
+}
+
+// ErrorKind 按哨兵错误分类，用作指标标签；canal服务端错误为"canal"，其他为"other"
+func ErrorKind(err error) string {
+	for _, k := range errorKinds {
+		if errors.Is(err, k.err) {
+			return k.kind
+		}
+	}
+	var canalErr *CanalError
+	if errors.As(err, &canalErr) {
+		return "canal"
+	}
+	return "other"
+}
+
+// metricsConnector 记录指标的连接器
+type metricsConnector struct {
+	Connector
+	destination string
+	metrics     Metrics
+	connected   bool // 是否连接过，之后的连接记为重连
+}
+
+// NewMetricsConnector 包装连接器并记录指标，可用于简单、集群和回放连接器；集群连接器内部的重连也记为重连
+func NewMetricsConnector(connector Connector, destination string, metrics Metrics) Connector {
+	if cluster, ok := unwrapConnector(connector).(*clusterConnector); ok {
+		cluster.restarted = append(cluster.restarted, func() {
+			metrics.Reconnected(destination)
+		})
+	}
+	return &metricsConnector{
+		Connector:   connector,
+		destination: destination,
+		metrics:     metrics,
+	}
+}
+
+func (c *metricsConnector) Connect(ctx context.Context) error {
+	if err := c.Connector.Connect(ctx); err != nil {
+		c.metrics.Error(c.destination, ErrorKind(err))
+		return err
+	}
+	if c.connected {
+		c.metrics.Reconnected(c.destination)
+	}
+	c.connected = true
+	return nil
+}
+
+func (c *metricsConnector) Subscribe(ctx context.Context, filter string) error {
+	return c.observe(c.Connector.Subscribe(ctx, filter))
+}
+
+func (c *metricsConnector) Get(ctx context.Context, batchSize int32, timeout time.Duration) (*Message, error) {
+	start := time.Now()
+	message, err := c.Connector.Get(ctx, batchSize, timeout)
+	if err = c.observe(err); err != nil {
+		return nil, err
+	}
+	c.received(message, time.Since(start))
+	c.metrics.Acked(c.destination)
+	return message, nil
+}
+
+func (c *metricsConnector) GetWithoutAck(ctx context.Context, batchSize int32, timeout time.Duration) (*Message, error) {
+	start := time.Now()
+	message, err := c.Connector.GetWithoutAck(ctx, batchSize, timeout)
+	if err = c.observe(err); err != nil {
+		return nil, err
+	}
+	c.received(message, time.Since(start))
+	return message, nil
+}
+
+func (c *metricsConnector) Ack(ctx context.Context, batchId int64) error {
+	if err := c.observe(c.Connector.Ack(ctx, batchId)); err != nil {
+		return err
+	}
+	c.metrics.Acked(c.destination)
+	return nil
+}
+
+func (c *metricsConnector) Rollback(ctx context.Context, batchId int64) error {
+	if err := c.observe(c.Connector.Rollback(ctx, batchId)); err != nil {
+		return err
+	}
+	c.metrics.RolledBack(c.destination)
+	return nil
+}
+
+func (c *metricsConnector) observe(err error) error {
+	if err != nil {
+		c.metrics.Error(c.destination, ErrorKind(err))
+	}
+	return err
+}
+
+// received 记录批次、行和延迟指标；延迟解析的消息会在这里解析一次
+func (c *metricsConnector) received(message *Message, latency time.Duration) {
+	if message == nil || message.Id == -1 {
+		c.metrics.BatchReceived(c.destination, 0, 0, latency)
+		return
+	}
+
+	entries, err := message.GetEntries()
+	if err != nil {
+		c.metrics.Error(c.destination, ErrorKind(err))
+		return
+	}
+
+	bytes := 0
+	for _, entry := range entries {
+		bytes += proto.Size(entry)
+
+		if entry.GetEntryType() != EntryType_ROWDATA {
+			continue
+		}
+		rowChange, err := ParseRowChange(entry)
+		if err != nil {
+			c.metrics.Error(c.destination, ErrorKind(err))
+			continue
+		}
+		if rows := len(rowChange.GetRowDatas()); rows > 0 {
+			header := entry.GetHeader()
+			c.metrics.RowsReceived(c.destination, header.GetSchemaName(), header.GetTableName(), rowChange.GetEventType(), rows)
+		}
+	}
+	c.metrics.BatchReceived(c.destination, len(entries), bytes, latency)
+
+	if len(entries) > 0 {
+		executeTime := entries[len(entries)-1].GetHeader().GetExecuteTime()
+		c.metrics.Lag(c.destination, time.Since(time.UnixMilli(executeTime)))
+	}
+}
@@ -0,0 +1,118 @@
+package icanal
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	MetricsNamespaceDefault = "icanal" // 默认prometheus命名空间
+)
+
+// PrometheusMetrics Metrics的prometheus实现，同时是prometheus.Collector，注册到Registry后即可暴露
+type PrometheusMetrics struct {
+	batches    *prometheus.CounterVec
+	bytes      *prometheus.CounterVec
+	entries    *prometheus.CounterVec
+	rows       *prometheus.CounterVec
+	acks       *prometheus.CounterVec
+	rollbacks  *prometheus.CounterVec
+	reconnects *prometheus.CounterVec
+	errors     *prometheus.CounterVec
+	getLatency *prometheus.HistogramVec
+	batchSize  *prometheus.HistogramVec
+	lag        *prometheus.GaugeVec
+	collectors []prometheus.Collector
+}
+
+// NewPrometheusMetrics 新建prometheus指标，namespace为空时使用默认命名空间
+func NewPrometheusMetrics(namespace string) *PrometheusMetrics {
+	if namespace == "" {
+		namespace = MetricsNamespaceDefault
+	}
+
+	counter := func(name, help string, labels ...string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: namespace, Name: name, Help: help}, labels)
+	}
+
+	m := &PrometheusMetrics{
+		batches:    counter("packets_received_total", "Message packets received.", "destination"),
+		bytes:      counter("bytes_received_total", "Entry bytes received.", "destination"),
+		entries:    counter("entries_received_total", "Entries received.", "destination"),
+		rows:       counter("rows_received_total", "Row changes received.", "destination", "schema", "table", "type"),
+		acks:       counter("acks_total", "Batches acked.", "destination"),
+		rollbacks:  counter("rollbacks_total", "Batches rolled back.", "destination"),
+		reconnects: counter("reconnects_total", "Reconnections.", "destination"),
+		errors:     counter("errors_total", "Errors by kind.", "destination", "kind"),
+		getLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "get_duration_seconds",
+			Help:      "Latency of Get and GetWithoutAck.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8),
+		}, []string{"destination"}),
+		batchSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "batch_entries",
+			Help:      "Entries per non-empty batch.",
+			Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+		}, []string{"destination"}),
+		lag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "lag_seconds",
+			Help:      "Now minus the execute time of the last received entry.",
+		}, []string{"destination"}),
+	}
+	m.collectors = []prometheus.Collector{
+		m.batches, m.bytes, m.entries, m.rows, m.acks, m.rollbacks, m.reconnects, m.errors,
+		m.getLatency, m.batchSize, m.lag,
+	}
+
+	return m
+}
+
+func (m *PrometheusMetrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range m.collectors {
+		collector.Describe(ch)
+	}
+}
+
+func (m *PrometheusMetrics) Collect(ch chan<- prometheus.Metric) {
+	for _, collector := range m.collectors {
+		collector.Collect(ch)
+	}
+}
+
+func (m *PrometheusMetrics) BatchReceived(destination string, entries int, bytes int, latency time.Duration) {
+	m.batches.WithLabelValues(destination).Inc()
+	m.bytes.WithLabelValues(destination).Add(float64(bytes))
+	m.entries.WithLabelValues(destination).Add(float64(entries))
+	m.getLatency.WithLabelValues(destination).Observe(latency.Seconds())
+	if entries > 0 {
+		m.batchSize.WithLabelValues(destination).Observe(float64(entries))
+	}
+}
+
+func (m *PrometheusMetrics) RowsReceived(destination string, schema string, table string, eventType EventType, rows int) {
+	m.rows.WithLabelValues(destination, schema, table, eventType.String()).Add(float64(rows))
+}
+
+func (m *PrometheusMetrics) Acked(destination string) {
+	m.acks.WithLabelValues(destination).Inc()
+}
+
+func (m *PrometheusMetrics) RolledBack(destination string) {
+	m.rollbacks.WithLabelValues(destination).Inc()
+}
+
+func (m *PrometheusMetrics) Reconnected(destination string) {
+	m.reconnects.WithLabelValues(destination).Inc()
+}
+
+func (m *PrometheusMetrics) Error(destination string, kind string) {
+	m.errors.WithLabelValues(destination, kind).Inc()
+}
+
+func (m *PrometheusMetrics) Lag(destination string, lag time.Duration) {
+	m.lag.WithLabelValues(destination).Set(lag.Seconds())
+}
@@ -0,0 +1,102 @@
+package icanal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestErrorKind(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{err: errors.Join(ErrNetwork, errors.New("broken pipe")), want: "network"},
+		{err: fmt.Errorf("wrap: %w", ErrAuth), want: "auth"},
+		{err: NewCanalError(400, "something goes wrong"), want: "canal"},
+		{err: context.Canceled, want: "canceled"},
+		{err: errors.New("unknown"), want: "other"},
+	}
+	for _, tt := range tests {
+		if got := ErrorKind(tt.err); got != tt.want {
+			t.Errorf("ErrorKind(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestMetricsConnector(t *testing.T) {
+	ctx := context.Background()
+	metrics := NewPrometheusMetrics("")
+	connector := NewMetricsConnector(&messageConnector{
+		Connector: &recordConnector{},
+		messages:  []*Message{{Id: 1, Entries: testEntries()}},
+	}, "example", metrics)
+
+	for i := 0; i < 2; i++ {
+		message, err := connector.GetWithoutAck(ctx, 100, time.Second)
+		if err != nil {
+			t.Fatalf("GetWithoutAck() error = %v", err)
+		}
+		if err = connector.Ack(ctx, message.Id); err != nil {
+			t.Fatalf("Ack() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{name: "packets", got: testutil.ToFloat64(metrics.batches), want: 2},
+		{name: "entries", got: testutil.ToFloat64(metrics.entries), want: float64(len(testEntries()))},
+		{name: "inserts", got: testutil.ToFloat64(metrics.rows.WithLabelValues("example", "test", "user", "INSERT")), want: 2},
+		{name: "acks", got: testutil.ToFloat64(metrics.acks), want: 2},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+// staticClusterManager 固定节点、不加锁的集群管理器
+type staticClusterManager struct {
+	address string
+}
+
+func (m staticClusterManager) Init(context.Context) error              { return nil }
+func (m staticClusterManager) GetNode(context.Context) (string, error) { return m.address, nil }
+func (m staticClusterManager) GetLock(context.Context) error           { return nil }
+func (m staticClusterManager) ReleaseLock(context.Context) error       { return nil }
+
+func TestMetricsConnector_ClusterRestart(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeCanalServer(t, testEntries())
+	metrics := NewPrometheusMetrics("")
+	connector := NewMetricsConnector(newClusterConnector("example", staticClusterManager{server.Address()},
+		WithRetryInterval(time.Millisecond),
+		WithLogger(QuietLogger()),
+	), "example", metrics)
+
+	if err := connector.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer connector.Disconnect(ctx)
+
+	// 连接断开后集群连接器在内部重连
+	server.Kick()
+	message, err := connector.GetWithoutAck(ctx, 100, time.Second)
+	if err != nil {
+		t.Fatalf("GetWithoutAck() error = %v", err)
+	}
+	if message.Id != 1 || server.Connections() != 2 {
+		t.Fatalf("GetWithoutAck() = batch %d with %d connections, want batch 1 with 2", message.Id, server.Connections())
+	}
+	if got := testutil.ToFloat64(metrics.reconnects); got != 1 {
+		t.Errorf("reconnects = %v, want 1", got)
+	}
+}
@@ -0,0 +1,176 @@
+package icanal
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/kalvinzhang/icanal/protocol/canal"
+)
+
+// recordMagic 录制文件头
+const recordMagic = "ICNLREC1"
+
+// recordHeaderLength 每条记录的头：时间戳(unix纳秒)、batch id、包长度，大端序
+const recordHeaderLength = 8 + 8 + 4
+
+// recordedPacket 录制的一个消息包
+type recordedPacket struct {
+	timestamp time.Time
+	batchId   int64
+	data      []byte // canal.Packet序列化后的字节
+}
+
+// Recorder 录制连接器收到的消息，包装的连接器行为不变
+//
+// 每个非空批次按canal.Packet(MESSAGES类型)写入，附带接收时间和batch id，可由ReplayConnector回放。
+// 录制的是服务端发送的原始字节，客户端过滤前；消息不是从canal包解析的(如自定义连接器)时重新序列化Entry。
+// 写入失败时GetWithoutAck返回错误，批次回滚后重新投递；Get返回时批次已经确认，写入失败只记录日志。
+type Recorder struct {
+	Connector
+	mutex  sync.Mutex
+	writer io.Writer
+	header bool
+	logger *slog.Logger
+}
+
+type RecorderOption func(*Recorder)
+
+// WithRecorderLogger 设置日志
+func WithRecorderLogger(logger *slog.Logger) RecorderOption {
+	return func(r *Recorder) {
+		r.logger = logger
+	}
+}
+
+// NewRecorder 新建录制器，w通常为文件；录制器不负责关闭w
+func NewRecorder(connector Connector, w io.Writer, opts ...RecorderOption) *Recorder {
+	r := &Recorder{
+		Connector: connector,
+		writer:    w,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+func (r *Recorder) Get(ctx context.Context, batchSize int32, timeout time.Duration) (*Message, error) {
+	message, err := r.Connector.Get(ctx, batchSize, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.record(message); err != nil {
+		loggerOrDefault(r.logger).ErrorContext(ctx, "failed to record acked batch",
+			slog.Int64(LogKeyBatchId, message.Id),
+			slog.Any("error", err),
+		)
+	}
+	return message, nil
+}
+
+func (r *Recorder) GetWithoutAck(ctx context.Context, batchSize int32, timeout time.Duration) (*Message, error) {
+	message, err := r.Connector.GetWithoutAck(ctx, batchSize, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return message, r.record(message)
+}
+
+func (r *Recorder) record(message *Message) error {
+	if message == nil || message.Id == -1 {
+		return nil
+	}
+
+	data, err := recordPacket(message)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.header {
+		if _, err = io.WriteString(r.writer, recordMagic); err != nil {
+			return err
+		}
+		r.header = true
+	}
+
+	header := make([]byte, 0, recordHeaderLength)
+	header = binary.BigEndian.AppendUint64(header, uint64(time.Now().UnixNano()))
+	header = binary.BigEndian.AppendUint64(header, uint64(message.Id))
+	header = binary.BigEndian.AppendUint32(header, uint32(len(data)))
+	if _, err = r.writer.Write(append(header, data...)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// recordPacket 消息的canal.Packet字节，优先使用服务端发送的原始字节
+func recordPacket(message *Message) ([]byte, error) {
+	if message.body != nil {
+		return proto.Marshal(&canal.Packet{Type: canal.PacketType_MESSAGES, Body: message.body})
+	}
+
+	messages := &canal.Messages{BatchId: message.Id}
+	if message.Raw {
+		messages.Messages, _ = message.RawEntries.([][]byte)
+	} else {
+		for _, entry := range message.Entries {
+			data, err := proto.Marshal(entry)
+			if err != nil {
+				return nil, err
+			}
+			messages.Messages = append(messages.Messages, data)
+		}
+	}
+	return marshalPacket(canal.PacketType_MESSAGES, messages)
+}
+
+// readRecords 读取录制文件
+func readRecords(r io.Reader) ([]recordedPacket, error) {
+	reader := bufio.NewReader(r)
+
+	magic := make([]byte, len(recordMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil // 空文件
+		}
+		return nil, errors.Join(ErrInvalidRecord, err)
+	}
+	if string(magic) != recordMagic {
+		return nil, ErrInvalidRecord
+	}
+
+	var packets []recordedPacket
+	header := make([]byte, recordHeaderLength)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return packets, nil
+			}
+			return nil, errors.Join(ErrInvalidRecord, err)
+		}
+
+		packet := recordedPacket{
+			timestamp: time.Unix(0, int64(binary.BigEndian.Uint64(header[0:8]))),
+			batchId:   int64(binary.BigEndian.Uint64(header[8:16])),
+			data:      make([]byte, binary.BigEndian.Uint32(header[16:20])),
+		}
+		if _, err := io.ReadFull(reader, packet.data); err != nil {
+			return nil, errors.Join(ErrInvalidRecord, err)
+		}
+		packets = append(packets, packet)
+	}
+}
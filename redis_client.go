@@ -0,0 +1,228 @@
+package icanal
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	RedisTimeoutDefault = 3 * time.Second // 默认redis连接和读写超时
+)
+
+// RedisClient redis客户端，可基于go-redis等实现
+type RedisClient interface {
+	// Pipeline 以pipeline按顺序发送命令；任一命令返回错误时返回错误
+	Pipeline(ctx context.Context, commands [][]string) error
+}
+
+// RespClient 基于RESP协议的简单redis客户端，单连接，出错后下次调用时重连
+type RespClient struct {
+	addr     string
+	password string
+	db       int
+	timeout  time.Duration
+
+	mutex  sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+type RespClientOption func(*RespClient)
+
+// WithRedisPassword 设置密码
+func WithRedisPassword(password string) RespClientOption {
+	return func(c *RespClient) {
+		c.password = password
+	}
+}
+
+// WithRedisDB 设置数据库
+func WithRedisDB(db int) RespClientOption {
+	return func(c *RespClient) {
+		c.db = db
+	}
+}
+
+// WithRedisTimeout 设置连接和读写超时；ctx有更早的截止时间时以ctx为准
+func WithRedisTimeout(timeout time.Duration) RespClientOption {
+	return func(c *RespClient) {
+		c.timeout = timeout
+	}
+}
+
+// NewRespClient 新建redis客户端，第一次调用时连接
+func NewRespClient(addr string, opts ...RespClientOption) *RespClient {
+	c := &RespClient{
+		addr:    addr,
+		timeout: RedisTimeoutDefault,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *RespClient) Pipeline(ctx context.Context, commands [][]string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.conn == nil {
+		if err := c.connect(ctx); err != nil {
+			return errors.Join(ErrNetwork, err)
+		}
+	}
+
+	err := c.pipeline(ctx, commands)
+	var redisErr *RedisError
+	if err != nil && !errors.As(err, &redisErr) {
+		// 网络错误后连接状态未知，关闭后重连
+		c.close()
+		return errors.Join(ErrNetwork, err)
+	}
+	return err
+}
+
+// Close 关闭连接
+func (c *RespClient) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.close()
+}
+
+func (c *RespClient) close() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	c.reader = nil
+	return err
+}
+
+func (c *RespClient) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: c.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	c.reader = bufio.NewReader(conn)
+
+	var commands [][]string
+	if c.password != "" {
+		commands = append(commands, []string{"AUTH", c.password})
+	}
+	if c.db != 0 {
+		commands = append(commands, []string{"SELECT", strconv.Itoa(c.db)})
+	}
+	if len(commands) == 0 {
+		return nil
+	}
+
+	if err = c.pipeline(ctx, commands); err != nil {
+		c.close()
+		return err
+	}
+	return nil
+}
+
+func (c *RespClient) pipeline(ctx context.Context, commands [][]string) error {
+	deadline := time.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(c.conn)
+	for _, command := range commands {
+		writer.WriteString("*" + strconv.Itoa(len(command)) + "\r\n")
+		for _, arg := range command {
+			writer.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	// 读完所有回复，保持连接同步
+	var firstErr error
+	for range commands {
+		err := c.readReply()
+		var redisErr *RedisError
+		if err != nil && !errors.As(err, &redisErr) {
+			return err
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// readReply 读取并丢弃一个回复；错误回复返回RedisError
+func (c *RespClient) readReply() error {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if len(line) < 3 {
+		return fmt.Errorf("invalid reply %q", line)
+	}
+	line = line[:len(line)-2]
+
+	switch line[0] {
+	case '+', ':':
+		return nil
+	case '-':
+		return &RedisError{Msg: line[1:]}
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return nil
+		}
+		_, err = io.CopyN(io.Discard, c.reader, int64(n)+2)
+		return err
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return err
+		}
+		var firstErr error
+		for i := 0; i < n; i++ {
+			err = c.readReply()
+			var redisErr *RedisError
+			if err != nil && !errors.As(err, &redisErr) {
+				return err
+			}
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	}
+
+	return fmt.Errorf("invalid reply %q", line)
+}
+
+// RedisError redis返回的错误回复
+type RedisError struct {
+	Msg string
+}
+
+func (e *RedisError) Error() string {
+	return e.Msg
+}
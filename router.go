@@ -0,0 +1,145 @@
+package icanal
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"slices"
+)
+
+// Router 按库表和事件类型路由变更事件；可作为Handler接入消费循环
+//
+// 库表模式使用canal的filter语法，空模式匹配所有表；一个事件命中多个路由时按注册顺序依次执行，
+// 没有命中任何路由的事件交给fallback处理。模式非法时不注册该路由，错误由Err、Handle和Route返回。
+type Router struct {
+	routes   []route
+	fallback EventHandler
+	err      error // 注册路由时的错误
+}
+
+type route struct {
+	table      *regexp.Regexp // nil匹配所有表
+	eventTypes []EventType    // 为空匹配所有事件类型
+	ddl        bool           // 只匹配DDL
+	handler    EventHandler
+}
+
+func (r route) match(event ChangeEvent) bool {
+	if r.ddl && !event.IsDdl {
+		return false
+	}
+	if len(r.eventTypes) > 0 && !slices.Contains(r.eventTypes, event.EventType) {
+		return false
+	}
+	return r.table == nil || r.table.MatchString(event.SchemaName()+"."+event.TableName())
+}
+
+// NewRouter 新建路由
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// On 注册库表指定事件类型的处理函数；不指定事件类型时匹配所有类型
+func (r *Router) On(pattern string, handler EventHandler, eventTypes ...EventType) *Router {
+	table, err := compileFilter(pattern)
+	if err != nil {
+		r.err = errors.Join(r.err, err)
+		return r
+	}
+	r.routes = append(r.routes, route{
+		table:      table,
+		eventTypes: eventTypes,
+		handler:    handler,
+	})
+	return r
+}
+
+// OnInsert 注册INSERT处理函数
+func (r *Router) OnInsert(pattern string, handler EventHandler) *Router {
+	return r.On(pattern, handler, EventType_INSERT)
+}
+
+// OnUpdate 注册UPDATE处理函数
+func (r *Router) OnUpdate(pattern string, handler EventHandler) *Router {
+	return r.On(pattern, handler, EventType_UPDATE)
+}
+
+// OnDelete 注册DELETE处理函数
+func (r *Router) OnDelete(pattern string, handler EventHandler) *Router {
+	return r.On(pattern, handler, EventType_DELETE)
+}
+
+// OnDDL 注册所有表的DDL处理函数
+func (r *Router) OnDDL(handler EventHandler) *Router {
+	return r.OnTableDDL("", handler)
+}
+
+// OnTableDDL 注册指定库表的DDL处理函数
+func (r *Router) OnTableDDL(pattern string, handler EventHandler) *Router {
+	table, err := compileFilter(pattern)
+	if err != nil {
+		r.err = errors.Join(r.err, err)
+		return r
+	}
+	r.routes = append(r.routes, route{
+		table:   table,
+		ddl:     true,
+		handler: handler,
+	})
+	return r
+}
+
+// Fallback 注册未命中任何路由时的处理函数
+func (r *Router) Fallback(handler EventHandler) *Router {
+	r.fallback = handler
+	return r
+}
+
+// Err 注册路由时的错误，包含ErrInvalidFilter；用于在开始消费前校验
+func (r *Router) Err() error {
+	return r.err
+}
+
+// Handle 处理一批消息；注册路由有错误时直接返回该错误
+func (r *Router) Handle(ctx context.Context, message *Message) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	events, err := ParseChangeEvents(message)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		if err = r.Route(ctx, event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Route 路由单个变更事件
+func (r *Router) Route(ctx context.Context, event ChangeEvent) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	routed := false
+	for _, rt := range r.routes {
+		if !rt.match(event) {
+			continue
+		}
+		routed = true
+		if err := rt.handler(ctx, event); err != nil {
+			return err
+		}
+	}
+
+	if !routed && r.fallback != nil {
+		return r.fallback(ctx, event)
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package icanal
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func newRouterEvent(table string, eventType EventType, ddl bool) ChangeEvent {
+	return ChangeEvent{
+		Header:    &Header{SchemaName: "test", TableName: table},
+		EventType: eventType,
+		IsDdl:     ddl,
+	}
+}
+
+func TestRouter_Route(t *testing.T) {
+	events := []ChangeEvent{
+		newRouterEvent("user", EventType_INSERT, false),
+		newRouterEvent("user", EventType_UPDATE, false),
+		newRouterEvent("user", EventType_DELETE, false),
+		newRouterEvent("user", EventType_ALTER, true),
+		newRouterEvent("orders", EventType_INSERT, false),
+	}
+
+	var got []string
+	record := func(name string) EventHandler {
+		return func(ctx context.Context, event ChangeEvent) error {
+			got = append(got, name+":"+event.TableName()+":"+event.EventType.String())
+			return nil
+		}
+	}
+
+	tests := []struct {
+		name   string
+		router func() *Router
+		want   []string
+	}{
+		{
+			name: "event type",
+			router: func() *Router {
+				return NewRouter().OnInsert(`test\.user`, record("insert")).OnDelete(`test\..*`, record("delete"))
+			},
+			want: []string{"insert:user:INSERT", "delete:user:DELETE"},
+		},
+		{
+			name: "table",
+			router: func() *Router {
+				return NewRouter().On(`test\.orders`, record("orders"))
+			},
+			want: []string{"orders:orders:INSERT"},
+		},
+		{
+			name: "multiple routes in order",
+			router: func() *Router {
+				return NewRouter().OnUpdate("", record("all")).OnUpdate(`test\.user`, record("user"))
+			},
+			want: []string{"all:user:UPDATE", "user:user:UPDATE"},
+		},
+		{
+			name: "ddl",
+			router: func() *Router {
+				return NewRouter().OnTableDDL(`test\.orders`, record("orders")).OnDDL(record("ddl"))
+			},
+			want: []string{"ddl:user:ALTER"},
+		},
+		{
+			name: "fallback",
+			router: func() *Router {
+				return NewRouter().On(`test\.user`, record("user")).Fallback(record("fallback"))
+			},
+			want: []string{
+				"user:user:INSERT", "user:user:UPDATE", "user:user:DELETE", "user:user:ALTER",
+				"fallback:orders:INSERT",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			router := tt.router()
+			for _, event := range events {
+				if err := router.Route(context.Background(), event); err != nil {
+					t.Fatalf("Route() error = %v", err)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Route() routed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouter_InvalidPattern(t *testing.T) {
+	errHandle := errors.New("handle error")
+	handler := func(context.Context, ChangeEvent) error { return errHandle }
+
+	for _, router := range []*Router{
+		NewRouter().On(`test\.(`, handler),
+		NewRouter().OnTableDDL(`[`, handler),
+	} {
+		if err := router.Err(); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("Err() = %v, want %v", err, ErrInvalidFilter)
+		}
+		if err := router.Handle(context.Background(), &Message{}); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("Handle() error = %v, want %v", err, ErrInvalidFilter)
+		}
+		if err := router.Route(context.Background(), newRouterEvent("user", EventType_INSERT, false)); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("Route() error = %v, want %v", err, ErrInvalidFilter)
+		}
+	}
+}
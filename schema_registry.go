@@ -0,0 +1,139 @@
+package icanal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SchemaRegistry schema注册中心
+type SchemaRegistry interface {
+	// Register 注册schema并返回版本号；与已有版本相同时返回已有版本
+	Register(subject string, schema string) (int, error)
+	// Get 获取指定版本的schema
+	Get(subject string, version int) (string, error)
+	// Latest 获取最新的schema和版本号
+	Latest(subject string) (string, int, error)
+}
+
+// FileSchemaRegistry 基于本地文件的schema注册中心，用于本地开发和测试替代远程注册中心
+//
+// 每个版本保存为"<dir>/<subject>/<version>.avsc"
+type FileSchemaRegistry struct {
+	dir   string
+	mutex sync.Mutex
+}
+
+// NewFileSchemaRegistry 新建本地文件schema注册中心
+func NewFileSchemaRegistry(dir string) (*FileSchemaRegistry, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileSchemaRegistry{dir: dir}, nil
+}
+
+func (r *FileSchemaRegistry) Register(subject string, schema string) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	versions, err := r.versions(subject)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, version := range versions {
+		existing, err := r.read(subject, version)
+		if err != nil {
+			return 0, err
+		}
+		if existing == schema {
+			return version, nil
+		}
+	}
+
+	version := 1
+	if len(versions) > 0 {
+		version = versions[len(versions)-1] + 1
+	}
+
+	if err = os.MkdirAll(filepath.Join(r.dir, subject), 0o755); err != nil {
+		return 0, err
+	}
+	if err = os.WriteFile(r.path(subject, version), []byte(schema), 0o644); err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+func (r *FileSchemaRegistry) Get(subject string, version int) (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.read(subject, version)
+}
+
+func (r *FileSchemaRegistry) Latest(subject string) (string, int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	versions, err := r.versions(subject)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(versions) == 0 {
+		return "", 0, ErrSchemaNotFound
+	}
+
+	version := versions[len(versions)-1]
+	schema, err := r.read(subject, version)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return schema, version, nil
+}
+
+func (r *FileSchemaRegistry) path(subject string, version int) string {
+	return filepath.Join(r.dir, subject, fmt.Sprintf("%d.avsc", version))
+}
+
+func (r *FileSchemaRegistry) read(subject string, version int) (string, error) {
+	data, err := os.ReadFile(r.path(subject, version))
+	if os.IsNotExist(err) {
+		return "", ErrSchemaNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// versions subject已有的版本号，升序
+func (r *FileSchemaRegistry) versions(subject string) ([]int, error) {
+	files, err := os.ReadDir(filepath.Join(r.dir, subject))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []int
+	for _, file := range files {
+		name, ok := strings.CutSuffix(file.Name(), ".avsc")
+		if !ok {
+			continue
+		}
+		if version, err := strconv.Atoi(name); err == nil {
+			versions = append(versions, version)
+		}
+	}
+	sort.Ints(versions)
+
+	return versions, nil
+}
@@ -0,0 +1,232 @@
+package icanal
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ColumnMeta 列元数据
+type ColumnMeta struct {
+	Name      string
+	Index     int32
+	SqlType   int32
+	MysqlType string
+	IsKey     bool
+}
+
+// TableSchema 表结构；Version从1开始，每次结构变更加1
+type TableSchema struct {
+	Schema  string
+	Table   string
+	Version int
+	Columns []ColumnMeta
+}
+
+// KeyNames 主键列名
+func (s *TableSchema) KeyNames() []string {
+	var names []string
+	for _, column := range s.Columns {
+		if column.IsKey {
+			names = append(names, column.Name)
+		}
+	}
+	return names
+}
+
+// SchemaChangeFunc 表结构变更回调；建表时old为nil，删表或改名时new为nil
+type SchemaChangeFunc func(ctx context.Context, old, new *TableSchema, event ChangeEvent)
+
+// SchemaTracker 表结构跟踪器；从行事件中学习列元数据，遇到DDL时生成新版本
+//
+// DDL只携带sql，新结构要等到该表的下一条行事件才能确定，因此ALTER/CREATE的回调在下一条行事件时触发；
+// ERASE/RENAME立即触发，新结构为nil。RENAME的header中是新表名，改名前的表从sql中解析。
+// ALTER之后即使列没有变化(如加索引、改注释)也生成新版本；没有看到DDL但列发生变化时同样生成新版本。
+type SchemaTracker struct {
+	mutex    sync.Mutex
+	tables   map[string]*TableSchema
+	versions map[string]int         // 每张表最后使用的版本号，删表后保留
+	pending  map[string]ChangeEvent // 已看到DDL、等待新结构的表
+	onChange SchemaChangeFunc
+}
+
+// NewSchemaTracker 新建表结构跟踪器；onChange可以为nil
+func NewSchemaTracker(onChange SchemaChangeFunc) *SchemaTracker {
+	return &SchemaTracker{
+		tables:   make(map[string]*TableSchema),
+		versions: make(map[string]int),
+		pending:  make(map[string]ChangeEvent),
+		onChange: onChange,
+	}
+}
+
+// Handle 处理一批消息，可作为Handler或者在其他Handler中调用
+func (t *SchemaTracker) Handle(ctx context.Context, message *Message) error {
+	events, err := ParseChangeEvents(message)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		t.Observe(ctx, event)
+	}
+
+	return nil
+}
+
+// Observe 观察一个变更事件，返回该行对应的表结构；DDL或者没有列时返回nil。返回值与跟踪器共享，不能修改
+func (t *SchemaTracker) Observe(ctx context.Context, event ChangeEvent) *TableSchema {
+	if event.IsDdl {
+		t.observeDdl(ctx, event)
+		return nil
+	}
+
+	columns := event.Columns()
+	if len(columns) == 0 {
+		return nil
+	}
+
+	metas := make([]ColumnMeta, 0, len(columns))
+	for _, column := range columns {
+		metas = append(metas, ColumnMeta{
+			Name:      column.GetName(),
+			Index:     column.GetIndex(),
+			SqlType:   column.GetSqlType(),
+			MysqlType: column.GetMysqlType(),
+			IsKey:     column.GetIsKey(),
+		})
+	}
+
+	key := event.SchemaName() + "." + event.TableName()
+
+	t.mutex.Lock()
+	old := t.tables[key]
+	ddl, hasDdl := t.pending[key]
+	delete(t.pending, key)
+	if old != nil && !hasDdl && slices.Equal(old.Columns, metas) {
+		t.mutex.Unlock()
+		return old
+	}
+
+	firstSeen := t.versions[key] == 0
+	t.versions[key]++
+	current := &TableSchema{
+		Schema:  event.SchemaName(),
+		Table:   event.TableName(),
+		Version: t.versions[key],
+		Columns: metas,
+	}
+	t.tables[key] = current
+	t.mutex.Unlock()
+
+	// 第一次看到且没有DDL，只记录不回调
+	if firstSeen && !hasDdl {
+		return current
+	}
+
+	if !hasDdl {
+		ddl = event
+	}
+	if t.onChange != nil {
+		t.onChange(ctx, old, current, ddl)
+	}
+
+	return current
+}
+
+func (t *SchemaTracker) observeDdl(ctx context.Context, event ChangeEvent) {
+	if event.TableName() == "" {
+		return
+	}
+
+	key := event.SchemaName() + "." + event.TableName()
+
+	switch event.EventType {
+	case EventType_ALTER, EventType_CREATE:
+		t.mutex.Lock()
+		t.pending[key] = event
+		t.mutex.Unlock()
+	case EventType_ERASE:
+		t.drop(ctx, key, event)
+	case EventType_RENAME:
+		renames := renameTables(event.SchemaName(), event.Sql)
+		if len(renames) == 0 {
+			t.drop(ctx, key, event)
+			return
+		}
+		for _, rename := range renames {
+			t.drop(ctx, rename[0], event)
+			t.drop(ctx, rename[1], event)
+		}
+	}
+}
+
+// drop 删除表的结构，表已知时回调
+func (t *SchemaTracker) drop(ctx context.Context, key string, event ChangeEvent) {
+	t.mutex.Lock()
+	old, ok := t.tables[key]
+	delete(t.tables, key)
+	delete(t.pending, key)
+	if ok {
+		t.versions[key]++
+	}
+	t.mutex.Unlock()
+
+	if ok && t.onChange != nil {
+		t.onChange(ctx, old, nil, event)
+	}
+}
+
+var (
+	renameTablePattern = regexp.MustCompile(`(?is)^\s*RENAME\s+TABLES?\s+(.+?)\s*;?\s*$`)
+	renamePairPattern  = regexp.MustCompile(`(?is)^\s*(\S+)\s+TO\s+(\S+)\s*$`)
+	alterRenamePattern = regexp.MustCompile(`(?is)^\s*ALTER\s+TABLE\s+(\S+)\s.*\bRENAME\s+(?:TO\s+|AS\s+)?(\S+?)\s*;?\s*$`)
+)
+
+// renameTables 解析RENAME TABLE a TO b, c TO d和ALTER TABLE a RENAME TO b，返回改名前后的"库.表"；
+// 表名没有库名时使用schema。不能解析时返回nil
+func renameTables(schema, sql string) [][2]string {
+	var pairs []string
+	if match := renameTablePattern.FindStringSubmatch(sql); match != nil {
+		pairs = strings.Split(match[1], ",")
+	} else if match = alterRenamePattern.FindStringSubmatch(sql); match != nil {
+		pairs = []string{match[1] + " TO " + match[2]}
+	}
+
+	var renames [][2]string
+	for _, pair := range pairs {
+		match := renamePairPattern.FindStringSubmatch(pair)
+		if match == nil {
+			return nil
+		}
+		renames = append(renames, [2]string{qualifiedTableName(schema, match[1]), qualifiedTableName(schema, match[2])})
+	}
+
+	return renames
+}
+
+// qualifiedTableName 去掉反引号并补全库名
+func qualifiedTableName(schema, name string) string {
+	name = strings.ReplaceAll(name, "`", "")
+	if !strings.Contains(name, ".") {
+		name = schema + "." + name
+	}
+	return name
+}
+
+// Lookup 查询表当前的结构；返回副本，未知时返回nil
+func (t *SchemaTracker) Lookup(schema, table string) *TableSchema {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	current, ok := t.tables[schema+"."+table]
+	if !ok {
+		return nil
+	}
+	clone := *current
+	clone.Columns = slices.Clone(current.Columns)
+
+	return &clone
+}
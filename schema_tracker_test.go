@@ -0,0 +1,125 @@
+package icanal
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func newDdlEvent(eventType EventType, table string, sql string) ChangeEvent {
+	return ChangeEvent{
+		Header:    &Header{SchemaName: "test", TableName: table},
+		EventType: eventType,
+		IsDdl:     true,
+		Sql:       sql,
+	}
+}
+
+func newInsertEvent(table string, names ...string) ChangeEvent {
+	columns := make([]*Column, 0, len(names))
+	for i, name := range names {
+		columns = append(columns, &Column{Index: int32(i), Name: name, IsKey: i == 0, MysqlType: "int"})
+	}
+	return ChangeEvent{
+		Header:    &Header{SchemaName: "test", TableName: table},
+		EventType: EventType_INSERT,
+		RowData:   &RowData{AfterColumns: columns},
+	}
+}
+
+func TestSchemaTracker(t *testing.T) {
+	ctx := context.Background()
+
+	var changes []string
+	version := func(schema *TableSchema) string {
+		if schema == nil {
+			return "nil"
+		}
+		return fmt.Sprintf("%s@%d", schema.Table, schema.Version)
+	}
+	tracker := NewSchemaTracker(func(ctx context.Context, old, new *TableSchema, event ChangeEvent) {
+		changes = append(changes, fmt.Sprintf("%s %s->%s", event.EventType, version(old), version(new)))
+	})
+
+	steps := []struct {
+		event       ChangeEvent
+		wantVersion int // 行事件返回的版本，DDL为0
+	}{
+		{newDdlEvent(EventType_CREATE, "user", "CREATE TABLE user (id int primary key)"), 0},
+		{newInsertEvent("user", "id"), 1},
+		{newInsertEvent("user", "id"), 1},
+		{newDdlEvent(EventType_ALTER, "user", "ALTER TABLE user ADD COLUMN age int"), 0},
+		{newInsertEvent("user", "id", "age"), 2},
+		{newDdlEvent(EventType_ALTER, "user", "ALTER TABLE user ADD INDEX idx_age (age)"), 0},
+		{newInsertEvent("user", "id", "age"), 3},
+		// header中是新表名
+		{newDdlEvent(EventType_RENAME, "member", "RENAME TABLE user TO member"), 0},
+		{newInsertEvent("member", "id", "age"), 1},
+		{newDdlEvent(EventType_ERASE, "member", "DROP TABLE member"), 0},
+	}
+	for i, step := range steps {
+		got := tracker.Observe(ctx, step.event)
+		if step.wantVersion == 0 {
+			if got != nil {
+				t.Errorf("step %d Observe() = %+v, want nil", i, got)
+			}
+			continue
+		}
+		if got == nil || got.Version != step.wantVersion {
+			t.Fatalf("step %d Observe() = %+v, want version %d", i, got, step.wantVersion)
+		}
+	}
+
+	want := []string{
+		"CREATE nil->user@1",
+		"ALTER user@1->user@2",
+		"ALTER user@2->user@3",
+		"RENAME user@3->nil",
+		"ERASE member@1->nil",
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %v, want %v", changes, want)
+	}
+	if schema := tracker.Lookup("test", "user"); schema != nil {
+		t.Errorf("Lookup(user) = %+v after rename, want nil", schema)
+	}
+	if schema := tracker.Lookup("test", "member"); schema != nil {
+		t.Errorf("Lookup(member) = %+v after erase, want nil", schema)
+	}
+}
+
+func TestSchemaTracker_Lookup(t *testing.T) {
+	tracker := NewSchemaTracker(nil)
+	tracker.Observe(context.Background(), newInsertEvent("user", "id", "age"))
+
+	schema := tracker.Lookup("test", "user")
+	if schema == nil || len(schema.Columns) != 2 {
+		t.Fatalf("Lookup() = %+v, want two columns", schema)
+	}
+	// 修改返回值不影响跟踪器
+	schema.Version = 10
+	schema.Columns[0].Name = "changed"
+	if got := tracker.Lookup("test", "user"); got.Version != 1 || got.Columns[0].Name != "id" {
+		t.Errorf("Lookup() = %+v after modifying the copy", got)
+	}
+}
+
+func TestRenameTables(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want [][2]string
+	}{
+		{"RENAME TABLE user TO member", [][2]string{{"test.user", "test.member"}}},
+		{"rename table `user` to `shop`.`member`, a TO b;", [][2]string{{"test.user", "shop.member"}, {"test.a", "test.b"}}},
+		{"ALTER TABLE user RENAME TO member", [][2]string{{"test.user", "test.member"}}},
+		{"ALTER TABLE `db`.`user` RENAME AS `db`.`member`", [][2]string{{"db.user", "db.member"}}},
+		{"ALTER TABLE user RENAME COLUMN a TO b", nil},
+		{"DROP TABLE user", nil},
+	}
+	for _, tt := range tests {
+		if got := renameTables("test", tt.sql); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("renameTables(%q) = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package icanal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	FlushCountDefault    = 1000                   // 默认缓冲多少条变更后写入
+	FlushBytesDefault    = 4 * 1024 * 1024        // 默认缓冲多少字节后写入
+	FlushIntervalDefault = time.Second            // 默认最长缓冲时间
+	MaxRetriesDefault    = 3                      // 默认写入失败重试次数
+	RetryBackoffDefault  = 100 * time.Millisecond // 默认首次重试等待时间，之后指数增长
+	MaxBackoffDefault    = 10 * time.Second       // 默认最长重试等待时间
+)
+
+// Sink 变更事件的写入目标
+type Sink interface {
+	Write(ctx context.Context, events []ChangeEvent) error
+}
+
+// SinkFunc 函数形式的Sink
+type SinkFunc func(ctx context.Context, events []ChangeEvent) error
+
+func (f SinkFunc) Write(ctx context.Context, events []ChangeEvent) error {
+	return f(ctx, events)
+}
+
+// Permanent 标记重试也无法成功的写入错误，例如数据不合法；SinkRunner只把这类错误的记录转入死信
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return errors.Join(ErrPermanent, err)
+}
+
+// SinkFormat sink写出的JSON格式
+type SinkFormat int
+
+const (
+	SinkFormatNative SinkFormat = iota // ChangeEvent的原生JSON
+	SinkFormatFlat                     // canal的FlatMessage
+)
+
+// marshal 按格式编码单个变更事件
+func (f SinkFormat) marshal(event ChangeEvent) ([]byte, error) {
+	if f == SinkFormatFlat {
+		flatMessage, err := NewFlatMessageFromEvent(event)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(flatMessage)
+	}
+	return json.Marshal(event)
+}
+
+// SinkRunnerConfig sink运行器配置
+type SinkRunnerConfig struct {
+	ConsumerConfig
+	FlushCount    int           // 缓冲条数达到后写入
+	FlushBytes    int           // 缓冲字节数达到后写入
+	FlushInterval time.Duration // 第一条缓冲后最长等待时间
+	MaxRetries    int           // 写入失败重试次数
+	RetryBackoff  time.Duration // 首次重试等待时间，之后指数增长
+	MaxBackoff    time.Duration // 最长重试等待时间
+	DeadLetter    Sink          // 死信sink，接收永久错误的记录；为nil时直接返回错误
+}
+
+func getDefaultSinkRunnerConfig() *SinkRunnerConfig {
+	return &SinkRunnerConfig{
+		ConsumerConfig: *getDefaultConsumerConfig(),
+		FlushCount:     FlushCountDefault,
+		FlushBytes:     FlushBytesDefault,
+		FlushInterval:  FlushIntervalDefault,
+		MaxRetries:     MaxRetriesDefault,
+		RetryBackoff:   RetryBackoffDefault,
+		MaxBackoff:     MaxBackoffDefault,
+	}
+}
+
+type SinkRunnerOption func(*SinkRunnerConfig)
+
+// WithConsumerOptions 设置拉取相关的选项
+func WithConsumerOptions(opts ...ConsumerOption) SinkRunnerOption {
+	return func(c *SinkRunnerConfig) {
+		for _, opt := range opts {
+			opt(&c.ConsumerConfig)
+		}
+	}
+}
+
+func WithFlushCount(flushCount int) SinkRunnerOption {
+	return func(c *SinkRunnerConfig) {
+		c.FlushCount = flushCount
+	}
+}
+
+func WithFlushBytes(flushBytes int) SinkRunnerOption {
+	return func(c *SinkRunnerConfig) {
+		c.FlushBytes = flushBytes
+	}
+}
+
+func WithFlushInterval(flushInterval time.Duration) SinkRunnerOption {
+	return func(c *SinkRunnerConfig) {
+		c.FlushInterval = flushInterval
+	}
+}
+
+func WithMaxRetries(maxRetries int) SinkRunnerOption {
+	return func(c *SinkRunnerConfig) {
+		c.MaxRetries = maxRetries
+	}
+}
+
+func WithRetryBackoff(retryBackoff time.Duration, maxBackoff time.Duration) SinkRunnerOption {
+	return func(c *SinkRunnerConfig) {
+		c.RetryBackoff = retryBackoff
+		c.MaxBackoff = maxBackoff
+	}
+}
+
+func WithDeadLetter(deadLetter Sink) SinkRunnerOption {
+	return func(c *SinkRunnerConfig) {
+		c.DeadLetter = deadLetter
+	}
+}
+
+// SinkRunner 把连接器拉取的变更批量写入sink
+//
+// 变更按条数、字节数、时间缓冲后写入；写入失败按指数退避重试，永久错误（见Permanent）时逐条写入，
+// 单条永久错误的记录转入死信sink。一个canal批次中的所有记录都写入或进入死信后才确认该批次；
+// 临时错误重试后仍失败时回滚缓冲中的批次并返回错误。
+type SinkRunner struct {
+	connector Connector
+	sink      Sink
+	config    *SinkRunnerConfig
+	logger    *slog.Logger
+
+	events    []ChangeEvent
+	bytes     int
+	batchIds  []int64   // 缓冲中的批次，按拉取顺序
+	firstTime time.Time // 第一条缓冲的时间
+}
+
+// NewSinkRunner 新建sink运行器
+func NewSinkRunner(connector Connector, sink Sink, opts ...SinkRunnerOption) *SinkRunner {
+	config := getDefaultSinkRunnerConfig()
+
+	// 应用所有选项
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	return &SinkRunner{
+		connector: connector,
+		sink:      sink,
+		config:    config,
+		logger:    loggerOrDefault(config.Logger),
+	}
+}
+
+// Run 连接并循环拉取写入，直到ctx结束或者出现错误；ctx结束时写入剩余缓冲并返回nil
+func (r *SinkRunner) Run(ctx context.Context) error {
+	if err := r.connector.Connect(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		if err := r.connector.Disconnect(context.WithoutCancel(ctx)); err != nil {
+			r.logger.WarnContext(ctx, "failed to disconnect", slog.Any("error", err))
+		}
+	}()
+
+	if r.config.Filter != "" {
+		if err := r.connector.Subscribe(ctx, r.config.Filter); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return r.flush(context.WithoutCancel(ctx))
+		default:
+		}
+
+		message, err := r.connector.GetWithoutAck(ctx, r.config.BatchSize, r.config.Timeout)
+		if err != nil {
+			return err
+		}
+
+		empty := message == nil || message.Id == -1
+		if !empty {
+			if err = r.buffer(message); err != nil {
+				return err
+			}
+		}
+
+		if r.shouldFlush() {
+			if err = r.flush(ctx); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if empty {
+			select {
+			case <-ctx.Done():
+			case <-time.After(r.config.IdleInterval):
+			}
+		}
+	}
+}
+
+func (r *SinkRunner) buffer(message *Message) error {
+	events, err := ParseChangeEvents(message)
+	if err != nil {
+		return err
+	}
+
+	if len(r.batchIds) == 0 {
+		r.firstTime = time.Now()
+	}
+	r.batchIds = append(r.batchIds, message.Id)
+	r.events = append(r.events, events...)
+	for _, event := range events {
+		r.bytes += proto.Size(event.RowData) + len(event.Sql)
+	}
+
+	return nil
+}
+
+func (r *SinkRunner) shouldFlush() bool {
+	if len(r.batchIds) == 0 {
+		return false
+	}
+	return len(r.events) >= r.config.FlushCount ||
+		r.bytes >= r.config.FlushBytes ||
+		time.Since(r.firstTime) >= r.config.FlushInterval
+}
+
+// flush 写入缓冲并确认其中的批次；写入失败时回滚，canal会重投所有未确认的批次
+func (r *SinkRunner) flush(ctx context.Context) error {
+	if len(r.batchIds) == 0 {
+		return nil
+	}
+	defer r.reset()
+
+	if err := r.write(ctx, r.events); err != nil {
+		r.logger.WarnContext(ctx, "sink write failed, rollback",
+			slog.Int("batches", len(r.batchIds)),
+			slog.Any("error", err),
+		)
+		if rbErr := r.connector.Rollback(ctx, 0); rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+
+	for _, batchId := range r.batchIds {
+		if err := r.connector.Ack(ctx, batchId); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *SinkRunner) reset() {
+	r.events = nil
+	r.bytes = 0
+	r.batchIds = nil
+}
+
+// write 重试写入；永久错误时逐条写入，单条永久错误的记录进入死信
+func (r *SinkRunner) write(ctx context.Context, events []ChangeEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	err := r.writeWithRetry(ctx, events)
+	if err == nil {
+		return nil
+	}
+	if r.config.DeadLetter == nil || !errors.Is(err, ErrPermanent) {
+		return err
+	}
+
+	if len(events) > 1 {
+		r.logger.WarnContext(ctx, "sink write failed, writing one by one",
+			slog.Int("events", len(events)),
+			slog.Any("error", err),
+		)
+		for i := range events {
+			if err = r.write(ctx, events[i:i+1]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	event := events[0]
+	r.logger.ErrorContext(ctx, "sink write failed, sending to dead letter",
+		slog.String("schema", event.SchemaName()),
+		slog.String("table", event.TableName()),
+		slog.String("logfileName", event.Header.GetLogfileName()),
+		slog.Int64("logfileOffset", event.Header.GetLogfileOffset()),
+		slog.Any("error", err),
+	)
+	if dlErr := r.config.DeadLetter.Write(ctx, events); dlErr != nil {
+		return errors.Join(ErrDeadLetter, dlErr, err)
+	}
+
+	return nil
+}
+
+func (r *SinkRunner) writeWithRetry(ctx context.Context, events []ChangeEvent) error {
+	backoff := r.config.RetryBackoff
+
+	var err error
+	for times := 0; ; times++ {
+		if err = r.sink.Write(ctx, events); err == nil {
+			return nil
+		}
+		if times >= r.config.MaxRetries || errors.Is(err, ErrPermanent) {
+			return err
+		}
+
+		r.logger.WarnContext(ctx, "sink write failed, retrying",
+			slog.Int("retryTimes", times+1),
+			slog.Any("error", err),
+		)
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(ctx.Err(), err)
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, r.config.MaxBackoff)
+	}
+}
@@ -0,0 +1,380 @@
+package icanal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	ElasticsearchIndexDefault      = "{schema}.{table}"     // 默认索引名模板
+	ElasticsearchMaxActionsDefault = 1000                   // 默认单个bulk请求最多action数
+	ElasticsearchMaxBytesDefault   = 5 * 1024 * 1024        // 默认单个bulk请求最大字节数
+	ElasticsearchRetriesDefault    = 3                      // 默认429/5xx的item重试次数
+	ElasticsearchBackoffDefault    = 200 * time.Millisecond // 默认首次重试等待时间，之后指数增长
+)
+
+// elasticsearchAction bulk中的一个action
+type elasticsearchAction struct {
+	action string // index或delete
+	index  string
+	id     string
+	source []byte // delete时为nil
+}
+
+func (a elasticsearchAction) size() int {
+	return len(a.index) + len(a.id) + len(a.source) + 64
+}
+
+// elasticsearchItemError bulk中单个item的错误
+type elasticsearchItemError struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+type elasticsearchBulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Id     string                  `json:"_id"`
+		Status int                     `json:"status"`
+		Error  *elasticsearchItemError `json:"error"`
+	} `json:"items"`
+}
+
+// ElasticsearchSink 通过_bulk接口把行变更同步到elasticsearch的sink
+//
+// INSERT/UPDATE转换为index action，文档为变更后的行；DELETE转换为delete action；修改了主键的UPDATE先删除旧文档。
+// _id为主键值，复合主键等为主键值的JSON数组（见elasticsearchId），索引名由模板渲染，{schema}和{table}替换为小写的库名和表名。
+// 一次Write中同一文档只保留最后一个action，超过大小的按条数和字节数拆成多个bulk请求；
+// 429和5xx的item按指数退避重试，其他item错误(delete的404除外)返回错误。
+// 按时间的缓冲由SinkRunner负责，Write返回前所有action都已完成；索引名模板非法时Write返回模板的错误。
+type ElasticsearchSink struct {
+	url        string
+	client     *http.Client
+	index      []string // 切分后的索引名模板
+	maxActions int
+	maxBytes   int
+	retries    int
+	backoff    time.Duration
+	header     http.Header
+	err        error // 选项的错误
+	logger     *slog.Logger
+}
+
+type ElasticsearchOption func(*ElasticsearchSink)
+
+// WithElasticsearchLogger 设置日志
+func WithElasticsearchLogger(logger *slog.Logger) ElasticsearchOption {
+	return func(s *ElasticsearchSink) {
+		s.logger = logger
+	}
+}
+
+// WithElasticsearchClient 设置http客户端
+func WithElasticsearchClient(client *http.Client) ElasticsearchOption {
+	return func(s *ElasticsearchSink) {
+		s.client = client
+	}
+}
+
+// WithElasticsearchIndex 设置索引名模板，如"mysql-{table}"
+func WithElasticsearchIndex(template string) ElasticsearchOption {
+	return func(s *ElasticsearchSink) {
+		parts, err := parseKeyTemplate(template)
+		if err != nil {
+			s.err = errors.Join(s.err, fmt.Errorf("%w: %s", err, template))
+			return
+		}
+		s.index = parts
+	}
+}
+
+// WithElasticsearchBulkSize 设置单个bulk请求的最多action数和最大字节数
+func WithElasticsearchBulkSize(maxActions int, maxBytes int) ElasticsearchOption {
+	return func(s *ElasticsearchSink) {
+		s.maxActions = maxActions
+		s.maxBytes = maxBytes
+	}
+}
+
+// WithElasticsearchRetry 设置429/5xx的重试次数和首次等待时间
+func WithElasticsearchRetry(retries int, backoff time.Duration) ElasticsearchOption {
+	return func(s *ElasticsearchSink) {
+		s.retries = retries
+		s.backoff = backoff
+	}
+}
+
+// WithElasticsearchBasicAuth 设置basic认证
+func WithElasticsearchBasicAuth(username, password string) ElasticsearchOption {
+	return func(s *ElasticsearchSink) {
+		request := http.Request{Header: make(http.Header)}
+		request.SetBasicAuth(username, password)
+		s.header.Set("Authorization", request.Header.Get("Authorization"))
+	}
+}
+
+// NewElasticsearchSink 新建elasticsearch sink，url为集群地址
+func NewElasticsearchSink(url string, opts ...ElasticsearchOption) *ElasticsearchSink {
+	index, _ := parseKeyTemplate(ElasticsearchIndexDefault)
+	s := &ElasticsearchSink{
+		url:        strings.TrimSuffix(url, "/"),
+		client:     http.DefaultClient,
+		index:      index,
+		maxActions: ElasticsearchMaxActionsDefault,
+		maxBytes:   ElasticsearchMaxBytesDefault,
+		retries:    ElasticsearchRetriesDefault,
+		backoff:    ElasticsearchBackoffDefault,
+		header:     make(http.Header),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.logger = loggerOrDefault(s.logger)
+	return s
+}
+
+// Err 返回选项的错误
+func (s *ElasticsearchSink) Err() error {
+	return s.err
+}
+
+func (s *ElasticsearchSink) Write(ctx context.Context, events []ChangeEvent) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	actions, err := s.actions(events)
+	if err != nil {
+		return err
+	}
+
+	for len(actions) > 0 {
+		n, size := 0, 0
+		for n < len(actions) && n < s.maxActions && (n == 0 || size+actions[n].size() <= s.maxBytes) {
+			size += actions[n].size()
+			n++
+		}
+		if err = s.bulk(ctx, actions[:n]); err != nil {
+			return err
+		}
+		actions = actions[n:]
+	}
+
+	return nil
+}
+
+// actions 把变更转换为action，同一文档只保留最后一个
+func (s *ElasticsearchSink) actions(events []ChangeEvent) ([]elasticsearchAction, error) {
+	var actions []elasticsearchAction
+	for _, event := range events {
+		if event.IsDdl {
+			continue
+		}
+		index := s.indexName(event)
+
+		var before, after string
+		if columns := event.RowData.GetBeforeColumns(); len(columns) > 0 {
+			before = elasticsearchId(columns)
+		}
+		if columns := event.RowData.GetAfterColumns(); len(columns) > 0 {
+			after = elasticsearchId(columns)
+		}
+
+		switch event.EventType {
+		case EventType_INSERT, EventType_UPDATE:
+			if after == "" {
+				return nil, Permanent(errors.Join(ErrNoPrimaryKey, fmt.Errorf("table %s.%s", event.SchemaName(), event.TableName())))
+			}
+			if before != "" && before != after {
+				actions = append(actions, elasticsearchAction{action: "delete", index: index, id: before})
+			}
+			source, err := json.Marshal(columnValues(event.RowData.GetAfterColumns()))
+			if err != nil {
+				return nil, err
+			}
+			actions = append(actions, elasticsearchAction{action: "index", index: index, id: after, source: source})
+		case EventType_DELETE:
+			if before == "" {
+				return nil, Permanent(errors.Join(ErrNoPrimaryKey, fmt.Errorf("table %s.%s", event.SchemaName(), event.TableName())))
+			}
+			actions = append(actions, elasticsearchAction{action: "delete", index: index, id: before})
+		}
+	}
+
+	// 只保留每个文档的最后一个action，重试失败的item时不会覆盖后面的变更
+	last := make(map[[2]string]int, len(actions))
+	for i, action := range actions {
+		last[[2]string{action.index, action.id}] = i
+	}
+	deduplicated := actions[:0]
+	for i, action := range actions {
+		if last[[2]string{action.index, action.id}] == i {
+			deduplicated = append(deduplicated, action)
+		}
+	}
+
+	return deduplicated, nil
+}
+
+func (s *ElasticsearchSink) indexName(event ChangeEvent) string {
+	var b strings.Builder
+	for i, part := range s.index {
+		switch {
+		case i%2 == 0:
+			b.WriteString(part)
+		case part == "schema":
+			b.WriteString(strings.ToLower(event.SchemaName()))
+		case part == "table":
+			b.WriteString(strings.ToLower(event.TableName()))
+		}
+	}
+	return b.String()
+}
+
+// elasticsearchId 文档id；没有主键时返回空
+//
+// 单列主键直接使用主键值；复合主键、NULL、空串及以"["开头的值使用主键值的JSON数组，NULL编码为null，
+// 因此不同的主键不会得到相同的id。
+func elasticsearchId(columns []*Column) string {
+	var keys []*Column
+	for _, column := range columns {
+		if column.GetIsKey() {
+			keys = append(keys, column)
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	if value := keys[0].GetValue(); len(keys) == 1 && !keys[0].GetIsNull() && value != "" && !strings.HasPrefix(value, "[") {
+		return value
+	}
+
+	values := make([]*string, 0, len(keys))
+	for _, column := range keys {
+		if column.GetIsNull() {
+			values = append(values, nil)
+			continue
+		}
+		value := column.GetValue()
+		values = append(values, &value)
+	}
+	data, _ := json.Marshal(values)
+	return string(data)
+}
+
+// bulk 发送一个bulk请求，重试429/5xx的item
+func (s *ElasticsearchSink) bulk(ctx context.Context, actions []elasticsearchAction) error {
+	backoff := s.backoff
+	for times := 0; ; times++ {
+		retry, err := s.post(ctx, actions)
+		if err != nil || len(retry) == 0 {
+			return err
+		}
+		if times >= s.retries {
+			return errors.Join(ErrBulkItem, fmt.Errorf("%d items failed after %d retries", len(retry), times))
+		}
+
+		s.logger.WarnContext(ctx, "elasticsearch bulk items failed, retrying",
+			slog.Int("items", len(retry)),
+			slog.Int("retryTimes", times+1),
+		)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		actions = retry
+	}
+}
+
+// post 发送一次bulk请求，返回需要重试的action
+func (s *ElasticsearchSink) post(ctx context.Context, actions []elasticsearchAction) ([]elasticsearchAction, error) {
+	var body bytes.Buffer
+	for _, action := range actions {
+		meta, err := json.Marshal(map[string]map[string]string{
+			action.action: {"_index": action.index, "_id": action.id},
+		})
+		if err != nil {
+			return nil, err
+		}
+		body.Write(meta)
+		body.WriteByte('\n')
+		if action.source != nil {
+			body.Write(action.source)
+			body.WriteByte('\n')
+		}
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url+"/_bulk", &body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range s.header {
+		request.Header[key] = values
+	}
+	request.Header.Set("Content-Type", "application/x-ndjson")
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500 {
+		return actions, nil // 整个请求重试
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return nil, fmt.Errorf("%w: %s %s", ErrBulkItem, response.Status, data)
+	}
+
+	var result elasticsearchBulkResponse
+	if err = json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	if !result.Errors {
+		return nil, nil
+	}
+	if len(result.Items) != len(actions) {
+		return nil, fmt.Errorf("%w: expect %d items but found %d", ErrBulkItem, len(actions), len(result.Items))
+	}
+
+	var (
+		retry []elasticsearchAction
+		errs  []error
+	)
+	for i, item := range result.Items {
+		action := actions[i]
+		status := item[action.action].Status
+		switch {
+		case status >= 200 && status < 300:
+		case action.action == "delete" && status == http.StatusNotFound:
+		case status == http.StatusTooManyRequests || status >= 500:
+			retry = append(retry, action)
+		default:
+			reason := ""
+			if itemErr := item[action.action].Error; itemErr != nil {
+				reason = itemErr.Type + ": " + itemErr.Reason
+			}
+			errs = append(errs, fmt.Errorf("%s %s/%s: status %d %s", action.action, action.index, action.id, status, reason))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, Permanent(errors.Join(append([]error{ErrBulkItem}, errs...)...))
+	}
+
+	return retry, nil
+}
@@ -0,0 +1,170 @@
+package icanal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// bulkServer _bulk接口的替身；id为"busy"的文档第一次返回429，id为"bad"的文档返回400
+func newBulkServer(t *testing.T, docs map[string]string) *httptest.Server {
+	busy := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var (
+			items  []map[string]any
+			failed bool
+		)
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var meta map[string]map[string]string
+			if err := json.Unmarshal(scanner.Bytes(), &meta); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			for action, target := range meta {
+				key := target["_index"] + "/" + target["_id"]
+				status := http.StatusOK
+				switch {
+				case target["_id"] == "busy" && busy:
+					busy = false
+					status = http.StatusTooManyRequests
+				case target["_id"] == "bad":
+					status = http.StatusBadRequest
+				case action == "index":
+					scanner.Scan()
+					docs[key] = scanner.Text()
+				case action == "delete":
+					if _, ok := docs[key]; !ok {
+						status = http.StatusNotFound
+					}
+					delete(docs, key)
+				}
+				if action == "index" && status != http.StatusOK {
+					scanner.Scan()
+				}
+				failed = failed || status >= 300
+				items = append(items, map[string]any{action: map[string]any{"_id": target["_id"], "status": status}})
+			}
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{"errors": failed, "items": items})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestElasticsearchSink_Write(t *testing.T) {
+	newEvent := func(eventType EventType, id string, seq string) ChangeEvent {
+		event := newRowEvent("Users", id, seq)
+		event.EventType = eventType
+		if eventType == EventType_DELETE {
+			event.RowData.BeforeColumns, event.RowData.AfterColumns = event.RowData.AfterColumns, nil
+		}
+		return event
+	}
+	keyChanged := newEvent(EventType_UPDATE, "3", "1")
+	keyChanged.RowData.BeforeColumns = []*Column{{Name: "id", IsKey: true, Value: "2"}}
+
+	tests := []struct {
+		name    string
+		opts    []ElasticsearchOption
+		events  []ChangeEvent
+		wantErr error
+		docs    map[string]string
+	}{
+		{
+			name: "write",
+			events: []ChangeEvent{
+				newEvent(EventType_INSERT, "1", "0"),
+				newEvent(EventType_INSERT, "2", "0"),
+				newEvent(EventType_UPDATE, "1", "1"),
+				keyChanged,
+				newEvent(EventType_INSERT, "busy", "0"),
+				newEvent(EventType_DELETE, "4", "0"),
+			},
+			docs: map[string]string{
+				"db.users/1":    `{"id":"1","seq":"1"}`,
+				"db.users/3":    `{"id":"3","seq":"1"}`,
+				"db.users/busy": `{"id":"busy","seq":"0"}`,
+			},
+		},
+		{
+			name:    "item error",
+			events:  []ChangeEvent{newEvent(EventType_INSERT, "bad", "0")},
+			wantErr: ErrBulkItem,
+			docs:    map[string]string{},
+		},
+		{
+			name:    "no primary key",
+			events:  []ChangeEvent{{Header: &Header{}, EventType: EventType_INSERT, RowData: &RowData{AfterColumns: []*Column{{Name: "a"}}}}},
+			wantErr: ErrNoPrimaryKey,
+			docs:    map[string]string{},
+		},
+		{
+			name:    "invalid index",
+			opts:    []ElasticsearchOption{WithElasticsearchIndex("{table")},
+			events:  []ChangeEvent{newEvent(EventType_INSERT, "1", "0")},
+			wantErr: ErrKeyTemplate,
+			docs:    map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs := make(map[string]string)
+			server := newBulkServer(t, docs)
+
+			opts := append([]ElasticsearchOption{
+				WithElasticsearchBulkSize(2, 1024),
+				WithElasticsearchRetry(1, time.Millisecond),
+			}, tt.opts...)
+			sink := NewElasticsearchSink(server.URL, opts...)
+			err := sink.Write(context.Background(), tt.events)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Write() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(docs, tt.docs) {
+				t.Errorf("docs = %v, want %v", docs, tt.docs)
+			}
+		})
+	}
+}
+
+func TestElasticsearchId(t *testing.T) {
+	key := func(value string) *Column {
+		return &Column{Name: "k", IsKey: true, Value: value}
+	}
+	null := &Column{Name: "k", IsKey: true, IsNullPresent: &Column_IsNull{IsNull: true}}
+
+	tests := []struct {
+		name    string
+		columns []*Column
+		want    string
+	}{
+		{"single", []*Column{key("1"), {Name: "v", Value: "a"}}, "1"},
+		{"no key", []*Column{{Name: "v", Value: "a"}}, ""},
+		{"composite", []*Column{key("a_b"), key("c")}, `["a_b","c"]`},
+		{"composite separator", []*Column{key("a"), key("b_c")}, `["a","b_c"]`},
+		{"null", []*Column{key("a"), null}, `["a",null]`},
+		{"empty", []*Column{key("a"), key("")}, `["a",""]`},
+		{"single empty", []*Column{key("")}, `[""]`},
+		{"single json", []*Column{key(`["1"]`)}, `["[\"1\"]"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := elasticsearchId(tt.columns); got != tt.want {
+				t.Errorf("elasticsearchId() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
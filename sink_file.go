@@ -0,0 +1,333 @@
+package icanal
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	FilePrefixDefault   = "icanal"          // 默认文件名前缀
+	FileMaxBytesDefault = 128 * 1024 * 1024 // 默认文件最大字节数
+	FileMaxAgeDefault   = time.Hour         // 默认文件最长写入时间
+
+	fileSuffix     = ".ndjson"
+	fileOpenSuffix = ".ndjson.open"
+)
+
+// FileSink 把变更按行写入本地NDJSON文件的sink
+//
+// 正在写入的文件名为"<prefix>-<第一条的binlog文件>_<位点>.ndjson.open"，文件达到大小或写入时间后轮转，
+// 重命名为"<prefix>-<第一条位点>-<最后一条位点>.ndjson"，开启压缩时再压缩为.ndjson.gz。
+// 每次Write都在fsync后返回，因此批次确认时数据已经落盘。重启后会继续写入遗留的.open文件。
+// 时间轮转在写入时检查，空闲时文件保持打开；轮转失败只记录日志，下一次Write时重试。
+// 重启后不知道遗留文件最后一条的位点，第一次写入后才会轮转；写入或fsync失败时截断到写入前的长度，重投时不会留下重复的行。
+type FileSink struct {
+	dir      string
+	prefix   string
+	maxBytes int64
+	maxAge   time.Duration
+	gzip     bool
+	format   SinkFormat
+
+	mutex  sync.Mutex
+	file   *os.File
+	first  binlogPosition
+	last   binlogPosition // 重启后继续写入时为空，直到下一次写入；为空时不轮转
+	size   int64
+	opened time.Time
+	wg     sync.WaitGroup
+	logger *slog.Logger
+}
+
+type FileSinkOption func(*FileSink)
+
+// WithFileLogger 设置日志
+func WithFileLogger(logger *slog.Logger) FileSinkOption {
+	return func(s *FileSink) {
+		s.logger = logger
+	}
+}
+
+// WithFilePrefix 设置文件名前缀
+func WithFilePrefix(prefix string) FileSinkOption {
+	return func(s *FileSink) {
+		s.prefix = prefix
+	}
+}
+
+// WithFileRotation 设置文件最大字节数和最长写入时间
+func WithFileRotation(maxBytes int64, maxAge time.Duration) FileSinkOption {
+	return func(s *FileSink) {
+		s.maxBytes = maxBytes
+		s.maxAge = maxAge
+	}
+}
+
+// WithFileGzip 是否压缩轮转后的文件
+func WithFileGzip(gzip bool) FileSinkOption {
+	return func(s *FileSink) {
+		s.gzip = gzip
+	}
+}
+
+// WithFileFormat 设置每行的格式，默认原生格式
+func WithFileFormat(format SinkFormat) FileSinkOption {
+	return func(s *FileSink) {
+		s.format = format
+	}
+}
+
+// NewFileSink 新建文件sink
+func NewFileSink(dir string, opts ...FileSinkOption) (*FileSink, error) {
+	s := &FileSink{
+		dir:      dir,
+		prefix:   FilePrefixDefault,
+		maxBytes: FileMaxBytesDefault,
+		maxAge:   FileMaxAgeDefault,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.logger = loggerOrDefault(s.logger)
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := s.resume(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// resume 继续写入遗留的.open文件
+func (s *FileSink) resume() error {
+	paths, err := filepath.Glob(filepath.Join(s.dir, s.prefix+"-*"+fileOpenSuffix))
+	if err != nil || len(paths) == 0 {
+		return err
+	}
+	if len(paths) > 1 {
+		return fmt.Errorf("more than one open file: %v", paths)
+	}
+
+	name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(paths[0]), s.prefix+"-"), fileOpenSuffix)
+	first, err := parseFilePosition(name)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(paths[0], os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	s.file = file
+	s.first = first
+	s.size = info.Size()
+	s.opened = time.Now()
+
+	return nil
+}
+
+func (s *FileSink) Write(ctx context.Context, events []ChangeEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	var data []byte
+	for _, event := range events {
+		line, err := s.format.marshal(event)
+		if err != nil {
+			return err
+		}
+		data = append(data, line...)
+		data = append(data, '\n')
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file != nil && s.last != (binlogPosition{}) && (s.size >= s.maxBytes || time.Since(s.opened) >= s.maxAge) {
+		s.tryRotate(ctx)
+	}
+	if s.file == nil {
+		if err := s.open(eventPosition(events[0])); err != nil {
+			return err
+		}
+	}
+
+	if err := s.write(data); err != nil {
+		return err
+	}
+	s.last = eventPosition(events[len(events)-1])
+
+	// 数据已经落盘，轮转失败不影响这次写入
+	if s.size >= s.maxBytes {
+		s.tryRotate(ctx)
+	}
+	return nil
+}
+
+// write 追加并fsync；失败时截断到写入前的长度，截断也失败时按已写入的长度计算文件大小
+func (s *FileSink) write(data []byte) error {
+	n, err := s.file.Write(data)
+	if err == nil {
+		err = s.file.Sync()
+	}
+	if err == nil {
+		s.size += int64(n)
+		return nil
+	}
+
+	if truncateErr := s.file.Truncate(s.size); truncateErr != nil {
+		s.size += int64(n)
+		return errors.Join(err, truncateErr)
+	}
+	return err
+}
+
+// Close 轮转当前文件并等待压缩完成；重启后还没有写入过或轮转失败的文件保持打开状态，重启后继续写入
+func (s *FileSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var err error
+	if s.file != nil && s.last != (binlogPosition{}) {
+		err = s.rotate(context.Background())
+	}
+	if s.file != nil {
+		err = errors.Join(err, s.file.Close())
+		s.file = nil
+	}
+	s.wg.Wait()
+
+	return err
+}
+
+func (s *FileSink) open(first binlogPosition) error {
+	path := filepath.Join(s.dir, s.prefix+"-"+formatFilePosition(first)+fileOpenSuffix)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if err = syncDir(s.dir); err != nil {
+		file.Close()
+		return err
+	}
+
+	s.file = file
+	s.first = first
+	s.last = binlogPosition{}
+	s.size = 0
+	s.opened = time.Now()
+
+	return nil
+}
+
+// tryRotate 轮转当前文件，失败时保留打开的文件等待下一次重试
+func (s *FileSink) tryRotate(ctx context.Context) {
+	path := s.file.Name()
+	if err := s.rotate(ctx); err != nil {
+		s.logger.WarnContext(ctx, "failed to rotate file, retry on next write",
+			slog.String("path", path),
+			slog.Any("error", err),
+		)
+	}
+}
+
+// rotate 按位点重命名并关闭当前文件；重命名失败时文件保持打开
+func (s *FileSink) rotate(ctx context.Context) error {
+	path := filepath.Join(s.dir, s.prefix+"-"+formatFilePosition(s.first)+"-"+formatFilePosition(s.last)+fileSuffix)
+	if err := os.Rename(s.file.Name(), path); err != nil {
+		return err
+	}
+
+	// 数据已经fsync，关闭或同步目录失败时文件也已经轮转
+	file := s.file
+	s.file = nil
+	err := errors.Join(file.Close(), syncDir(s.dir))
+
+	s.logger.DebugContext(ctx, "file sink rotated", slog.String("path", path), slog.Int64("size", s.size))
+
+	if s.gzip {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			if err := gzipFile(path); err != nil {
+				s.logger.WarnContext(ctx, "failed to gzip file", slog.String("path", path), slog.Any("error", err))
+			}
+		}()
+	}
+
+	return err
+}
+
+// formatFilePosition 文件名中的位点，"<binlog文件>_<位点>"
+func formatFilePosition(position binlogPosition) string {
+	return position.file + "_" + strconv.FormatInt(position.offset, 10)
+}
+
+func parseFilePosition(s string) (binlogPosition, error) {
+	i := strings.LastIndexByte(s, '_')
+	if i < 0 {
+		return binlogPosition{}, fmt.Errorf("invalid file position %q", s)
+	}
+	offset, err := strconv.ParseInt(s[i+1:], 10, 64)
+	if err != nil {
+		return binlogPosition{}, errors.Join(fmt.Errorf("invalid file position %q", s), err)
+	}
+	return binlogPosition{file: s[:i], offset: offset}, nil
+}
+
+// gzipFile 压缩为.gz并删除原文件
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	writer := gzip.NewWriter(dst)
+	if _, err = io.Copy(writer, src); err != nil {
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	if err = dst.Sync(); err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+// syncDir fsync目录，保证文件的创建和重命名落盘
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
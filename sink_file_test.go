@@ -0,0 +1,184 @@
+package icanal
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newFileEvent mysql-bin.000001指定位点的变更
+func newFileEvent(offset int64) ChangeEvent {
+	event := newRowEvent("orders", "1", "0")
+	event.Header.LogfileName = "mysql-bin.000001"
+	event.Header.LogfileOffset = offset
+	return event
+}
+
+// fileNames 目录下的文件名，排序后返回
+func fileNames(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fileLines 文件的行数，.gz文件解压后计算
+func fileLines(t *testing.T, path string) int {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if strings.HasSuffix(path, ".gz") {
+		reader, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatalf("gzip.NewReader() error = %v", err)
+		}
+		scanner = bufio.NewScanner(reader)
+	}
+	n := 0
+	for scanner.Scan() {
+		n++
+	}
+	return n
+}
+
+func TestFileSink_Write(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	sink, err := NewFileSink(dir, WithFileRotation(200, time.Hour), WithFileGzip(true))
+	if err != nil {
+		t.Fatalf("NewFileSink() error = %v", err)
+	}
+	// 第一批超过大小后轮转，第二批留在打开的文件中
+	if err = sink.Write(ctx, []ChangeEvent{newFileEvent(4), newFileEvent(100)}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err = sink.Write(ctx, []ChangeEvent{newFileEvent(200)}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	rotated := "icanal-mysql-bin.000001_4-mysql-bin.000001_100.ndjson"
+	waitFor(t, "gzip", func() bool {
+		_, err := os.Stat(filepath.Join(dir, rotated))
+		return os.IsNotExist(err)
+	})
+
+	// 模拟崩溃重启，不关闭旧的sink，继续写入打开的文件
+	if sink, err = NewFileSink(dir, WithFileRotation(200, time.Hour)); err != nil {
+		t.Fatalf("NewFileSink() error = %v", err)
+	}
+	if err = sink.Write(ctx, []ChangeEvent{newFileEvent(300)}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err = sink.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	want := []string{
+		"icanal-mysql-bin.000001_200-mysql-bin.000001_300.ndjson",
+		rotated + ".gz",
+	}
+	if names := fileNames(t, dir); !reflect.DeepEqual(names, want) {
+		t.Fatalf("files = %v, want %v", names, want)
+	}
+	for _, name := range want {
+		if n := fileLines(t, filepath.Join(dir, name)); n != 2 {
+			t.Errorf("%s lines = %d, want 2", name, n)
+		}
+	}
+}
+
+func TestFileSink_RotateFailed(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	// 轮转的目标路径是非空目录，重命名失败
+	rotated := filepath.Join(dir, "icanal-mysql-bin.000001_4-mysql-bin.000001_100.ndjson")
+	if err := os.MkdirAll(filepath.Join(rotated, "blocked"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	sink, err := NewFileSink(dir, WithFileRotation(200, time.Hour))
+	if err != nil {
+		t.Fatalf("NewFileSink() error = %v", err)
+	}
+	if err = sink.Write(ctx, []ChangeEvent{newFileEvent(4), newFileEvent(100)}); err != nil {
+		t.Fatalf("Write() error = %v, want nil after fsync", err)
+	}
+	open := "icanal-mysql-bin.000001_4.ndjson.open"
+	if n := fileLines(t, filepath.Join(dir, open)); n != 2 {
+		t.Fatalf("%s lines = %d, want 2", open, n)
+	}
+
+	// 下一次写入前重试轮转
+	if err = os.RemoveAll(rotated); err != nil {
+		t.Fatal(err)
+	}
+	if err = sink.Write(ctx, []ChangeEvent{newFileEvent(200)}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	want := []string{
+		"icanal-mysql-bin.000001_200.ndjson.open",
+		filepath.Base(rotated),
+	}
+	if names := fileNames(t, dir); !reflect.DeepEqual(names, want) {
+		t.Fatalf("files = %v, want %v", names, want)
+	}
+	if n := fileLines(t, rotated); n != 2 {
+		t.Errorf("%s lines = %d, want 2", rotated, n)
+	}
+	if err = sink.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+}
+
+func TestFileSink_ResumeRotate(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	// 崩溃前的文件已经超过大小，最后一条的位点未知
+	open := filepath.Join(dir, "icanal-mysql-bin.000001_4.ndjson.open")
+	if err := os.WriteFile(open, []byte(strings.Repeat("{}\n", 100)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sink, err := NewFileSink(dir, WithFileRotation(200, time.Hour))
+	if err != nil {
+		t.Fatalf("NewFileSink() error = %v", err)
+	}
+	if err = sink.Write(ctx, []ChangeEvent{newFileEvent(500)}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err = sink.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	want := []string{"icanal-mysql-bin.000001_4-mysql-bin.000001_500.ndjson"}
+	if names := fileNames(t, dir); !reflect.DeepEqual(names, want) {
+		t.Fatalf("files = %v, want %v", names, want)
+	}
+	if n := fileLines(t, filepath.Join(dir, want[0])); n != 101 {
+		t.Errorf("%s lines = %d, want 101", want[0], n)
+	}
+}
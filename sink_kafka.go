@@ -0,0 +1,183 @@
+package icanal
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+)
+
+const (
+	KafkaHeaderLogfileName   = "binlog.file"   // binlog文件名
+	KafkaHeaderLogfileOffset = "binlog.offset" // binlog位点
+	KafkaHeaderGtid          = "binlog.gtid"   // gtid，为空时不设置
+)
+
+// KafkaHeader kafka记录头
+type KafkaHeader struct {
+	Key   string
+	Value []byte
+}
+
+// KafkaRecord kafka记录
+type KafkaRecord struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers []KafkaHeader
+}
+
+// KafkaProducer kafka生产者，由使用方基于具体的kafka客户端实现
+type KafkaProducer interface {
+	// Produce 按顺序发送记录；返回nil表示所有记录都已被broker确认
+	Produce(ctx context.Context, records []KafkaRecord) error
+}
+
+// KafkaSink 把变更发送到kafka的sink
+//
+// 默认每行变更一条记录，key为"schema.table:主键值"(编码见kafkaKey)，同一行的变更进入同一分区；没有主键的表key为"schema.table"。
+// 修改了主键的UPDATE拆成旧主键的DELETE和新主键的INSERT两条记录，保证旧主键的分区能看到删除。
+// 按事务发送时一个事务一条记录，value为事件的JSON数组，key为库名，头中的位点取事务最后一条变更。
+// Write在生产者确认所有记录后才返回，因此批次只会在broker确认后被确认。
+type KafkaSink struct {
+	producer    KafkaProducer
+	topic       func(event ChangeEvent) string
+	format      SinkFormat
+	transaction bool
+}
+
+type KafkaOption func(*KafkaSink)
+
+// WithKafkaTopic 按变更选择topic；按事务发送时使用事务的第一条变更
+func WithKafkaTopic(topic func(event ChangeEvent) string) KafkaOption {
+	return func(s *KafkaSink) {
+		s.topic = topic
+	}
+}
+
+// WithKafkaFormat 设置记录value的格式，默认原生格式
+func WithKafkaFormat(format SinkFormat) KafkaOption {
+	return func(s *KafkaSink) {
+		s.format = format
+	}
+}
+
+// WithKafkaTransaction 是否按事务发送
+func WithKafkaTransaction(transaction bool) KafkaOption {
+	return func(s *KafkaSink) {
+		s.transaction = transaction
+	}
+}
+
+// NewKafkaSink 新建kafka sink，默认所有变更发送到topic
+func NewKafkaSink(producer KafkaProducer, topic string, opts ...KafkaOption) *KafkaSink {
+	s := &KafkaSink{
+		producer: producer,
+		topic: func(ChangeEvent) string {
+			return topic
+		},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *KafkaSink) Write(ctx context.Context, events []ChangeEvent) error {
+	var records []KafkaRecord
+	for _, group := range s.group(events) {
+		record, err := s.record(group)
+		if err != nil {
+			return err
+		}
+		records = append(records, record)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	return s.producer.Produce(ctx, records)
+}
+
+// group 按记录分组；按事务发送时连续的同一事务的变更为一组，DDL单独一组。
+// 未在本批次结束的事务没有事务id，按批次归为一组；按行发送时拆分修改了主键的UPDATE
+func (s *KafkaSink) group(events []ChangeEvent) [][]ChangeEvent {
+	var groups [][]ChangeEvent
+	for i, event := range events {
+		if !s.transaction && event.keyChanged() {
+			for _, split := range splitKeyChange(event) {
+				groups = append(groups, []ChangeEvent{split})
+			}
+			continue
+		}
+		if s.transaction && i > 0 && !event.IsDdl {
+			prev := events[i-1]
+			if !prev.IsDdl && prev.BatchId == event.BatchId && prev.TransactionId == event.TransactionId {
+				groups[len(groups)-1] = append(groups[len(groups)-1], event)
+				continue
+			}
+		}
+		groups = append(groups, []ChangeEvent{event})
+	}
+	return groups
+}
+
+func (s *KafkaSink) record(group []ChangeEvent) (KafkaRecord, error) {
+	first, last := group[0], group[len(group)-1]
+
+	record := KafkaRecord{
+		Topic: s.topic(first),
+		Headers: []KafkaHeader{
+			{Key: KafkaHeaderLogfileName, Value: []byte(last.Header.GetLogfileName())},
+			{Key: KafkaHeaderLogfileOffset, Value: []byte(strconv.FormatInt(last.Header.GetLogfileOffset(), 10))},
+		},
+	}
+	if gtid := last.Header.GetGtid(); gtid != "" {
+		record.Headers = append(record.Headers, KafkaHeader{Key: KafkaHeaderGtid, Value: []byte(gtid)})
+	}
+
+	if !s.transaction {
+		value, err := s.format.marshal(first)
+		if err != nil {
+			return KafkaRecord{}, err
+		}
+		record.Key = []byte(kafkaKey(first))
+		record.Value = value
+		return record, nil
+	}
+
+	values := make([]json.RawMessage, 0, len(group))
+	for _, event := range group {
+		value, err := s.format.marshal(event)
+		if err != nil {
+			return KafkaRecord{}, err
+		}
+		values = append(values, value)
+	}
+	value, err := json.Marshal(values)
+	if err != nil {
+		return KafkaRecord{}, err
+	}
+	record.Key = []byte(first.SchemaName())
+	record.Value = value
+
+	return record, nil
+}
+
+// splitKeyChange 把修改了主键的UPDATE拆成变更前的DELETE和变更后的INSERT
+func splitKeyChange(event ChangeEvent) []ChangeEvent {
+	deleted, inserted := event, event
+	deleted.EventType = EventType_DELETE
+	deleted.RowData = &RowData{BeforeColumns: event.RowData.GetBeforeColumns()}
+	inserted.EventType = EventType_INSERT
+	inserted.RowData = &RowData{AfterColumns: event.RowData.GetAfterColumns()}
+	return []ChangeEvent{deleted, inserted}
+}
+
+// kafkaKey 记录key："schema.table:主键值"，主键值的编码与elasticsearchId相同，复合主键、NULL等为JSON数组
+func kafkaKey(event ChangeEvent) string {
+	key := event.SchemaName() + "." + event.TableName()
+	if id := elasticsearchId(event.Columns()); id != "" {
+		key += ":" + id
+	}
+	return key
+}
@@ -0,0 +1,180 @@
+package icanal
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// recordProducer 记录发送的kafka记录
+type recordProducer struct {
+	records []KafkaRecord
+}
+
+func (p *recordProducer) Produce(_ context.Context, records []KafkaRecord) error {
+	p.records = append(p.records, records...)
+	return nil
+}
+
+func TestKafkaSink_Write(t *testing.T) {
+	newEvent := func(table, id, transactionId string) ChangeEvent {
+		event := newRowEvent(table, id, "0")
+		event.BatchId = 1
+		event.TransactionId = transactionId
+		event.Header.LogfileName = "mysql-bin.000001"
+		event.Header.LogfileOffset = 100
+		return event
+	}
+	events := []ChangeEvent{
+		newEvent("orders", "1", "10"),
+		newEvent("users", "2", "10"),
+		newEvent("orders", "3", "11"),
+		newEvent("orders", "4", ""),
+	}
+
+	tests := []struct {
+		name        string
+		transaction bool
+		keys        []string
+		rows        []int
+	}{
+		{
+			name: "per row",
+			keys: []string{"db.orders:1", "db.users:2", "db.orders:3", "db.orders:4"},
+			rows: []int{1, 1, 1, 1},
+		},
+		{
+			name:        "per transaction",
+			transaction: true,
+			keys:        []string{"db", "db", "db"},
+			rows:        []int{2, 1, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			producer := &recordProducer{}
+			sink := NewKafkaSink(producer, "canal", WithKafkaTransaction(tt.transaction))
+			if err := sink.Write(context.Background(), events); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+
+			var (
+				keys []string
+				rows []int
+			)
+			for _, record := range producer.records {
+				if record.Topic != "canal" {
+					t.Errorf("Topic = %s, want canal", record.Topic)
+				}
+				wantHeaders := []KafkaHeader{
+					{Key: KafkaHeaderLogfileName, Value: []byte("mysql-bin.000001")},
+					{Key: KafkaHeaderLogfileOffset, Value: []byte("100")},
+				}
+				if !reflect.DeepEqual(record.Headers, wantHeaders) {
+					t.Errorf("Headers = %v, want %v", record.Headers, wantHeaders)
+				}
+
+				keys = append(keys, string(record.Key))
+				if !tt.transaction {
+					rows = append(rows, 1)
+					continue
+				}
+				var values []json.RawMessage
+				if err := json.Unmarshal(record.Value, &values); err != nil {
+					t.Fatalf("Unmarshal() error = %v", err)
+				}
+				rows = append(rows, len(values))
+			}
+
+			if !reflect.DeepEqual(keys, tt.keys) {
+				t.Errorf("keys = %v, want %v", keys, tt.keys)
+			}
+			if !reflect.DeepEqual(rows, tt.rows) {
+				t.Errorf("rows = %v, want %v", rows, tt.rows)
+			}
+		})
+	}
+}
+
+func TestKafkaSink_KeyChange(t *testing.T) {
+	event := newRowEvent("orders", "2", "1")
+	event.RowData.BeforeColumns = []*Column{
+		{Name: "id", IsKey: true, Value: "1"},
+		{Name: "seq", Value: "0"},
+	}
+
+	tests := []struct {
+		name        string
+		transaction bool
+		keys        []string
+		types       []string
+	}{
+		{"per row", false, []string{"db.orders:1", "db.orders:2"}, []string{"DELETE", "INSERT"}},
+		{"per transaction", true, []string{"db"}, []string{"UPDATE"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			producer := &recordProducer{}
+			sink := NewKafkaSink(producer, "canal", WithKafkaTransaction(tt.transaction))
+			if err := sink.Write(context.Background(), []ChangeEvent{event}); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+
+			var keys, types []string
+			for _, record := range producer.records {
+				keys = append(keys, string(record.Key))
+				var values []struct {
+					Type string `json:"type"`
+				}
+				value := record.Value
+				if !tt.transaction {
+					value = append(append([]byte("["), value...), ']')
+				}
+				if err := json.Unmarshal(value, &values); err != nil {
+					t.Fatalf("Unmarshal() error = %v", err)
+				}
+				for _, v := range values {
+					types = append(types, v.Type)
+				}
+			}
+			if !reflect.DeepEqual(keys, tt.keys) {
+				t.Errorf("keys = %v, want %v", keys, tt.keys)
+			}
+			if !reflect.DeepEqual(types, tt.types) {
+				t.Errorf("types = %v, want %v", types, tt.types)
+			}
+		})
+	}
+}
+
+func TestKafkaKey(t *testing.T) {
+	newEvent := func(keys ...*Column) ChangeEvent {
+		return ChangeEvent{
+			Header:    &Header{SchemaName: "db", TableName: "orders"},
+			EventType: EventType_INSERT,
+			RowData:   &RowData{AfterColumns: append(keys, &Column{Name: "name", Value: "a"})},
+		}
+	}
+	null := &Column{Name: "b", IsKey: true, IsNullPresent: &Column_IsNull{IsNull: true}}
+
+	tests := []struct {
+		name  string
+		event ChangeEvent
+		want  string
+	}{
+		{"single", newEvent(&Column{Name: "id", IsKey: true, Value: "1"}), "db.orders:1"},
+		{"composite", newEvent(&Column{Name: "a", IsKey: true, Value: "1:2"}, &Column{Name: "b", IsKey: true, Value: "3"}), `db.orders:["1:2","3"]`},
+		{"composite colon", newEvent(&Column{Name: "a", IsKey: true, Value: "1"}, &Column{Name: "b", IsKey: true, Value: "2:3"}), `db.orders:["1","2:3"]`},
+		{"null", newEvent(&Column{Name: "a", IsKey: true, Value: "1"}, null), `db.orders:["1",null]`},
+		{"empty", newEvent(&Column{Name: "a", IsKey: true, Value: "1"}, &Column{Name: "b", IsKey: true}), `db.orders:["1",""]`},
+		{"no key", newEvent(), "db.orders"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := kafkaKey(tt.event); got != tt.want {
+				t.Errorf("kafkaKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
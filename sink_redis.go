@@ -0,0 +1,248 @@
+package icanal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redisRule 库表到redis key的映射规则
+type redisRule struct {
+	table    *regexp.Regexp // 为nil时匹配所有表
+	template []string       // 按"{"和"}"切分后的模板，奇数位置为列名
+	set      bool           // 写入变更后的行，否则删除
+	ttl      time.Duration
+}
+
+// key 用一行的列渲染key
+func (r redisRule) key(columns []*Column) (string, error) {
+	var b strings.Builder
+	for i, part := range r.template {
+		if i%2 == 0 {
+			b.WriteString(part)
+			continue
+		}
+		index := slices.IndexFunc(columns, func(column *Column) bool {
+			return column.GetName() == part
+		})
+		if index < 0 {
+			return "", Permanent(errors.Join(ErrKeyTemplate, fmt.Errorf("column %s not found", part)))
+		}
+		if columns[index].GetIsNull() {
+			return "", Permanent(errors.Join(ErrKeyTemplate, fmt.Errorf("column %s is null", part)))
+		}
+		b.WriteString(columns[index].GetValue())
+	}
+	return b.String(), nil
+}
+
+// parseKeyTemplate 切分key模板，如"user:{id}"切分为["user:", "id", ""]
+func parseKeyTemplate(template string) ([]string, error) {
+	var parts []string
+	for {
+		prefix, rest, ok := strings.Cut(template, "{")
+		if !ok {
+			if strings.Contains(template, "}") {
+				return nil, ErrKeyTemplate
+			}
+			return append(parts, template), nil
+		}
+		name, rest, ok := strings.Cut(rest, "}")
+		if !ok || name == "" || strings.Contains(prefix, "}") {
+			return nil, ErrKeyTemplate
+		}
+		parts = append(parts, prefix, name)
+		template = rest
+	}
+}
+
+// RedisSink 按行变更删除或更新redis缓存的sink
+//
+// key由库表匹配的规则按模板渲染，模板中的{列名}替换为该行的列值。删除规则对每个变更DEL变更前后的key；
+// 写入规则对INSERT/UPDATE把变更后的行以JSON SET到key，DELETE及修改了key的UPDATE删除旧key。
+// 一次Write的命令通过一个pipeline发送。开启延迟双删后，删除的key会在延迟后再删除一次，
+// 用于清除并发读在第一次删除后回填的旧值；第二次删除失败只记录日志。
+// 规则非法时Write返回规则的错误。
+type RedisSink struct {
+	client      RedisClient
+	rules       []redisRule
+	deleteDelay time.Duration
+	wg          sync.WaitGroup
+	err         error // 规则的错误
+	logger      *slog.Logger
+}
+
+type RedisSinkOption func(*RedisSink)
+
+// WithRedisLogger 设置日志
+func WithRedisLogger(logger *slog.Logger) RedisSinkOption {
+	return func(s *RedisSink) {
+		s.logger = logger
+	}
+}
+
+// WithRedisDelete 添加删除规则，pattern为canal过滤规则格式的库表正则
+func WithRedisDelete(pattern string, template string) RedisSinkOption {
+	return withRedisRule(pattern, template, false, 0)
+}
+
+// WithRedisSet 添加写入规则，ttl为0时不过期
+func WithRedisSet(pattern string, template string, ttl time.Duration) RedisSinkOption {
+	return withRedisRule(pattern, template, true, ttl)
+}
+
+func withRedisRule(pattern string, template string, set bool, ttl time.Duration) RedisSinkOption {
+	return func(s *RedisSink) {
+		parts, err := parseKeyTemplate(template)
+		if err != nil {
+			s.err = errors.Join(s.err, fmt.Errorf("%w: %s", err, template))
+			return
+		}
+		table, err := compileFilter(pattern)
+		if err != nil {
+			s.err = errors.Join(s.err, err)
+			return
+		}
+		s.rules = append(s.rules, redisRule{table: table, template: parts, set: set, ttl: ttl})
+	}
+}
+
+// WithRedisDoubleDelete 开启延迟双删
+func WithRedisDoubleDelete(delay time.Duration) RedisSinkOption {
+	return func(s *RedisSink) {
+		s.deleteDelay = delay
+	}
+}
+
+// NewRedisSink 新建redis sink
+func NewRedisSink(client RedisClient, opts ...RedisSinkOption) *RedisSink {
+	s := &RedisSink{client: client}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.logger = loggerOrDefault(s.logger)
+	return s
+}
+
+// Err 返回规则的错误
+func (s *RedisSink) Err() error {
+	return s.err
+}
+
+func (s *RedisSink) Write(ctx context.Context, events []ChangeEvent) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	var (
+		commands [][]string
+		deleted  []string
+	)
+	for _, event := range events {
+		if event.IsDdl {
+			continue
+		}
+		for _, rule := range s.rules {
+			if rule.table != nil && !rule.table.MatchString(event.SchemaName()+"."+event.TableName()) {
+				continue
+			}
+			ruleCommands, ruleDeleted, err := s.commands(rule, event)
+			if err != nil {
+				return err
+			}
+			commands = append(commands, ruleCommands...)
+			deleted = append(deleted, ruleDeleted...)
+		}
+	}
+	if len(commands) == 0 {
+		return nil
+	}
+
+	if err := s.client.Pipeline(ctx, commands); err != nil {
+		return err
+	}
+
+	if s.deleteDelay > 0 && len(deleted) > 0 {
+		s.delayDelete(ctx, deleted)
+	}
+
+	return nil
+}
+
+// commands 一个变更在一条规则下的命令和删除的key
+func (s *RedisSink) commands(rule redisRule, event ChangeEvent) ([][]string, []string, error) {
+	var before, after string
+	var err error
+	if columns := event.RowData.GetBeforeColumns(); len(columns) > 0 {
+		if before, err = rule.key(columns); err != nil {
+			return nil, nil, err
+		}
+	}
+	if columns := event.RowData.GetAfterColumns(); len(columns) > 0 {
+		if after, err = rule.key(columns); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var deleted []string
+	if before != "" && (!rule.set || event.EventType == EventType_DELETE || before != after) {
+		deleted = append(deleted, before)
+	}
+	if after != "" && !rule.set && after != before {
+		deleted = append(deleted, after)
+	}
+
+	var commands [][]string
+	if len(deleted) > 0 {
+		commands = append(commands, append([]string{"DEL"}, deleted...))
+	}
+	if rule.set && after != "" && event.EventType != EventType_DELETE {
+		value, err := json.Marshal(columnValues(event.RowData.GetAfterColumns()))
+		if err != nil {
+			return nil, nil, err
+		}
+		command := []string{"SET", after, string(value)}
+		if rule.ttl > 0 {
+			command = append(command, "PX", strconv.FormatInt(rule.ttl.Milliseconds(), 10))
+		}
+		commands = append(commands, command)
+	}
+
+	return commands, deleted, nil
+}
+
+// delayDelete 延迟后再次删除
+func (s *RedisSink) delayDelete(ctx context.Context, keys []string) {
+	seen := make(map[string]bool, len(keys))
+	keys = slices.DeleteFunc(keys, func(key string) bool {
+		duplicated := seen[key]
+		seen[key] = true
+		return duplicated
+	})
+
+	ctx = context.WithoutCancel(ctx)
+	s.wg.Add(1)
+	time.AfterFunc(s.deleteDelay, func() {
+		defer s.wg.Done()
+		if err := s.client.Pipeline(ctx, [][]string{append([]string{"DEL"}, keys...)}); err != nil {
+			s.logger.WarnContext(ctx, "redis delayed delete failed",
+				slog.Int("keys", len(keys)),
+				slog.Any("error", err),
+			)
+		}
+	})
+}
+
+// Close 等待未完成的延迟删除
+func (s *RedisSink) Close() error {
+	s.wg.Wait()
+	return nil
+}
@@ -0,0 +1,206 @@
+package icanal
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// respServer 内存RESP服务器，支持GET/SET/DEL并记录收到的命令
+type respServer struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	data     map[string]string
+	commands []string
+}
+
+func newRespServer(t *testing.T) *respServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	s := &respServer{listener: listener, data: make(map[string]string)}
+	t.Cleanup(func() {
+		listener.Close()
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *respServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		args := make([]string, n)
+		for i := range args {
+			line, _ = reader.ReadString('\n')
+			size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			arg := make([]byte, size+2)
+			if _, err = io.ReadFull(reader, arg); err != nil {
+				return
+			}
+			args[i] = string(arg[:size])
+		}
+
+		s.mutex.Lock()
+		s.commands = append(s.commands, strings.Join(args, " "))
+		var reply string
+		switch strings.ToUpper(args[0]) {
+		case "SET":
+			s.data[args[1]] = args[2]
+			reply = "+OK\r\n"
+		case "DEL":
+			deleted := 0
+			for _, key := range args[1:] {
+				if _, ok := s.data[key]; ok {
+					delete(s.data, key)
+					deleted++
+				}
+			}
+			reply = ":" + strconv.Itoa(deleted) + "\r\n"
+		case "GET":
+			value, ok := s.data[args[1]]
+			if !ok {
+				reply = "$-1\r\n"
+			} else {
+				reply = "$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n"
+			}
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		s.mutex.Unlock()
+
+		if _, err = conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *respServer) reset() ([]string, map[string]string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	commands, data := s.commands, s.data
+	s.commands, s.data = nil, make(map[string]string)
+	return commands, data
+}
+
+func TestRedisSink_Write(t *testing.T) {
+	server := newRespServer(t)
+	client := NewRespClient(server.listener.Addr().String())
+	defer client.Close()
+
+	update := newRowEvent("users", "2", "1")
+	update.RowData.BeforeColumns = []*Column{{Name: "id", IsKey: true, Value: "1"}}
+	events := []ChangeEvent{newRowEvent("users", "1", "0"), update}
+
+	tests := []struct {
+		name     string
+		opts     []RedisSinkOption
+		commands []string
+		data     map[string]string
+	}{
+		{
+			name:     "delete",
+			opts:     []RedisSinkOption{WithRedisDelete(`db\.users`, "user:{id}"), WithRedisDoubleDelete(time.Millisecond)},
+			commands: []string{"DEL user:1", "DEL user:1 user:2", "DEL user:1 user:2"},
+			data:     map[string]string{},
+		},
+		{
+			name:     "set",
+			opts:     []RedisSinkOption{WithRedisSet(`db\..*`, "{id}:user", time.Minute)},
+			commands: []string{`SET 1:user {"id":"1","seq":"0"} PX 60000`, "DEL 1:user", `SET 2:user {"id":"2","seq":"1"} PX 60000`},
+			data:     map[string]string{"2:user": `{"id":"2","seq":"1"}`},
+		},
+		{
+			name: "no match",
+			opts: []RedisSinkOption{WithRedisDelete(`db\.orders`, "order:{id}")},
+			data: map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := NewRedisSink(client, tt.opts...)
+			if err := sink.Write(context.Background(), events); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			sink.Close()
+
+			commands, data := server.reset()
+			if !reflect.DeepEqual(commands, tt.commands) {
+				t.Errorf("commands = %q, want %q", commands, tt.commands)
+			}
+			if !reflect.DeepEqual(data, tt.data) {
+				t.Errorf("data = %v, want %v", data, tt.data)
+			}
+		})
+	}
+}
+
+func TestRedisSink_Invalid(t *testing.T) {
+	nullKey := newRowEvent("users", "", "0")
+	nullKey.RowData.AfterColumns[0].IsNullPresent = &Column_IsNull{IsNull: true}
+
+	tests := []struct {
+		name    string
+		opts    []RedisSinkOption
+		event   ChangeEvent
+		wantErr error
+	}{
+		{"invalid pattern", []RedisSinkOption{WithRedisDelete(`db\.(`, "user:{id}")}, newRowEvent("users", "1", "0"), ErrInvalidFilter},
+		{"invalid template", []RedisSinkOption{WithRedisSet(`db\.users`, "user:{id", 0)}, newRowEvent("users", "1", "0"), ErrKeyTemplate},
+		{"missing column", []RedisSinkOption{WithRedisDelete(`db\.users`, "user:{name}")}, newRowEvent("users", "1", "0"), ErrPermanent},
+		{"null key", []RedisSinkOption{WithRedisDelete(`db\.users`, "user:{id}")}, nullKey, ErrPermanent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 规则非法时不会发送命令，客户端不需要连接
+			sink := NewRedisSink(nil, tt.opts...)
+			err := sink.Write(context.Background(), []ChangeEvent{tt.event})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Write() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRespClient_Pipeline(t *testing.T) {
+	server := newRespServer(t)
+	client := NewRespClient(server.listener.Addr().String())
+	defer client.Close()
+
+	err := client.Pipeline(context.Background(), [][]string{{"SET", "a", "1"}, {"UNKNOWN"}, {"GET", "a"}})
+	if err == nil || err.Error() != "ERR unknown command" {
+		t.Errorf("Pipeline() error = %v, want ERR unknown command", err)
+	}
+
+	// 错误回复后连接仍然可用
+	if err = client.Pipeline(context.Background(), [][]string{{"DEL", "a"}}); err != nil {
+		t.Errorf("Pipeline() error = %v", err)
+	}
+	if commands, _ := server.reset(); len(commands) != 4 {
+		t.Errorf("commands = %q, want 4 commands", commands)
+	}
+}
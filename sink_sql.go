@@ -0,0 +1,488 @@
+package icanal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	CheckpointTableDefault = "icanal_checkpoint" // 默认位点表
+)
+
+// binlogPosition binlog位点；一个Entry的多行共享文件和偏移，用行号区分
+type binlogPosition struct {
+	file   string
+	offset int64
+	row    int
+	gtid   string // 开启GTID时为执行到该事务的GTID集合
+}
+
+func headerPosition(header *Header) binlogPosition {
+	return binlogPosition{
+		file:   header.GetLogfileName(),
+		offset: header.GetLogfileOffset(),
+		gtid:   header.GetGtid(),
+	}
+}
+
+// eventPosition 变更的位点，包含行号
+func eventPosition(event ChangeEvent) binlogPosition {
+	position := headerPosition(event.Header)
+	position.row = event.Row
+	return position
+}
+
+// after 是否在o之后；o为空位点时总是成立。
+//
+// 主从切换后新主库的binlog文件和偏移与原主库无关，因此两边都有GTID时先比较GTID集合；
+// 集合相同说明是同一个事务，位点在同一个文件中时再按位点比较，否则是在原主库上回放过的事务。
+// binlog文件名序号定长补零，超出后更长，因此先比较长度
+func (p binlogPosition) after(o binlogPosition) bool {
+	if o == (binlogPosition{}) {
+		return true
+	}
+	if p.gtid != "" && o.gtid != "" {
+		if order, ok := compareGtidSets(parseGtidSet(p.gtid), parseGtidSet(o.gtid)); ok {
+			if order != 0 {
+				return order > 0
+			}
+			if p.file != o.file {
+				return false
+			}
+		}
+	}
+	if p.file != o.file {
+		if len(p.file) != len(o.file) {
+			return len(p.file) > len(o.file)
+		}
+		return p.file > o.file
+	}
+	if p.offset != o.offset {
+		return p.offset > o.offset
+	}
+	return p.row > o.row
+}
+
+// parseGtidSet 解析"uuid:1-23:30,uuid2:1-5"格式的GTID集合，返回每个server uuid执行到的最大事务号；格式错误时返回nil
+func parseGtidSet(gtid string) map[string]int64 {
+	set := make(map[string]int64)
+	for _, part := range strings.Split(gtid, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		uuid, intervals, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil
+		}
+		for _, interval := range strings.Split(intervals, ":") {
+			_, end, _ := strings.Cut(interval, "-")
+			if end == "" {
+				end = interval
+			}
+			n, err := strconv.ParseInt(end, 10, 64)
+			if err != nil {
+				return nil
+			}
+			uuid = strings.ToLower(uuid)
+			set[uuid] = max(set[uuid], n)
+		}
+	}
+	return set
+}
+
+// compareGtidSets 比较两个GTID集合：a有b之后的事务为1，b有a之后的事务为-1，相同为0；
+// 互有对方没有的事务或者格式错误时无法比较，ok为false
+func compareGtidSets(a, b map[string]int64) (order int, ok bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	var greater, less bool
+	for uuid, n := range a {
+		greater = greater || n > b[uuid]
+	}
+	for uuid, n := range b {
+		less = less || n > a[uuid]
+	}
+	switch {
+	case greater && less:
+		return 0, false
+	case greater:
+		return 1, true
+	case less:
+		return -1, true
+	}
+	return 0, true
+}
+
+// SQLSink 把变更回放到database/sql数据库的sink
+//
+// 每个源事务(同一批次内TransactionEnd相同的变更)在一个数据库事务中执行，并在同一事务中把最后一条变更的binlog位点
+// (文件、偏移、Entry内的行号和GTID)写入目标库的位点表；跨批次的源事务在批次结束时先提交已收到的部分。
+// 重启后位点之前(含)的变更会被跳过，因此批次在写入成功但确认失败后重投也不会重复回放，
+// 一个Entry的多行分多次写入时也不会丢行。
+// DDL默认跳过，开启透传后按库名和表名映射改写表名后执行，单独提交；注意MySQL的DDL会隐式提交当前事务。
+type SQLSink struct {
+	db              *sql.DB
+	dialect         SQLDialect
+	destination     string
+	checkpointTable string
+	upsert          bool
+	ddlPassthrough  bool
+	schemaMapping   map[string]string            // 源库 -> 目标库
+	tableMapping    map[string]string            // schema.table -> 目标表
+	columnMapping   map[string]map[string]string // schema.table -> 源列 -> 目标列
+
+	generator  *SQLGenerator
+	once       sync.Once
+	initErr    error
+	mutex      sync.Mutex
+	checkpoint binlogPosition
+	logger     *slog.Logger
+}
+
+type SQLSinkOption func(*SQLSink)
+
+// WithSQLLogger 设置日志
+func WithSQLLogger(logger *slog.Logger) SQLSinkOption {
+	return func(s *SQLSink) {
+		s.logger = logger
+	}
+}
+
+// WithCheckpointTable 设置位点表名
+func WithCheckpointTable(table string) SQLSinkOption {
+	return func(s *SQLSink) {
+		s.checkpointTable = table
+	}
+}
+
+// WithSQLUpsert 使用upsert回放INSERT和UPDATE
+func WithSQLUpsert(upsert bool) SQLSinkOption {
+	return func(s *SQLSink) {
+		s.upsert = upsert
+	}
+}
+
+// WithDDLPassthrough 是否在目标库执行DDL；表名按库名和表名映射改写，找不到表名时返回永久错误
+func WithDDLPassthrough(ddlPassthrough bool) SQLSinkOption {
+	return func(s *SQLSink) {
+		s.ddlPassthrough = ddlPassthrough
+	}
+}
+
+// WithSchemaMapping 库名映射，key为源库名；未配置的库不带库名写入连接的默认库
+func WithSchemaMapping(schemaMapping map[string]string) SQLSinkOption {
+	return func(s *SQLSink) {
+		s.schemaMapping = schemaMapping
+	}
+}
+
+// WithTableMapping 表名映射，key为"schema.table"，value为目标表名(不含库名)；未配置的表使用源表名
+func WithTableMapping(tableMapping map[string]string) SQLSinkOption {
+	return func(s *SQLSink) {
+		s.tableMapping = tableMapping
+	}
+}
+
+// WithColumnMapping 列名映射，key为"schema.table"，value为源列名到目标列名的映射
+func WithColumnMapping(columnMapping map[string]map[string]string) SQLSinkOption {
+	return func(s *SQLSink) {
+		s.columnMapping = columnMapping
+	}
+}
+
+// NewSQLSink 新建数据库sink；destination作为位点表的主键
+func NewSQLSink(db *sql.DB, dialect SQLDialect, destination string, opts ...SQLSinkOption) *SQLSink {
+	s := &SQLSink{
+		db:              db,
+		dialect:         dialect,
+		destination:     destination,
+		checkpointTable: CheckpointTableDefault,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.logger = loggerOrDefault(s.logger)
+
+	s.generator = NewSQLGenerator(dialect,
+		WithUpsert(s.upsert),
+		WithSchemaName(func(schema, _ string) string {
+			return s.schemaMapping[schema]
+		}),
+		WithTableName(func(schema, table string) string {
+			if target, ok := s.tableMapping[schema+"."+table]; ok {
+				return target
+			}
+			return table
+		}),
+		WithColumnName(func(schema, table, column string) string {
+			if target, ok := s.columnMapping[schema+"."+table][column]; ok {
+				return target
+			}
+			return column
+		}),
+	)
+
+	return s
+}
+
+// Init 创建位点表并加载位点；Write第一次调用时会自动执行
+func (s *SQLSink) Init(ctx context.Context) error {
+	s.once.Do(func() {
+		s.initErr = s.init(ctx)
+	})
+	return s.initErr
+}
+
+func (s *SQLSink) init(ctx context.Context) error {
+	table := s.dialect.quote(s.checkpointTable)
+	create := "CREATE TABLE IF NOT EXISTS " + table + " (" +
+		s.dialect.quote("destination") + " VARCHAR(255) NOT NULL PRIMARY KEY, " +
+		s.dialect.quote("logfile_name") + " VARCHAR(255) NOT NULL, " +
+		s.dialect.quote("logfile_offset") + " BIGINT NOT NULL, " +
+		s.dialect.quote("logfile_row") + " INTEGER NOT NULL DEFAULT 0, " +
+		s.dialect.quote("gtid") + " TEXT NOT NULL)"
+	if _, err := s.db.ExecContext(ctx, create); err != nil {
+		return err
+	}
+
+	if err := s.migrate(ctx, table); err != nil {
+		return err
+	}
+
+	query := "SELECT " + s.dialect.quote("logfile_name") + ", " + s.dialect.quote("logfile_offset") + ", " +
+		s.dialect.quote("logfile_row") + ", " + s.dialect.quote("gtid") +
+		" FROM " + table + " WHERE " + s.dialect.quote("destination") + " = " + s.dialect.placeholder(1)
+
+	var checkpoint binlogPosition
+	err := s.db.QueryRowContext(ctx, query, s.destination).
+		Scan(&checkpoint.file, &checkpoint.offset, &checkpoint.row, &checkpoint.gtid)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	s.mutex.Lock()
+	s.checkpoint = checkpoint
+	s.mutex.Unlock()
+
+	s.logger.InfoContext(ctx, "sql sink checkpoint loaded",
+		slog.String("destination", s.destination),
+		slog.String("logfileName", checkpoint.file),
+		slog.Int64("logfileOffset", checkpoint.offset),
+		slog.Int("logfileRow", checkpoint.row),
+		slog.String("gtid", checkpoint.gtid),
+	)
+
+	return nil
+}
+
+// migrate 早期版本的位点表没有行号列，补上该列
+func (s *SQLSink) migrate(ctx context.Context, table string) error {
+	rows, err := s.db.QueryContext(ctx, "SELECT * FROM "+table+" WHERE 1 = 0")
+	if err != nil {
+		return err
+	}
+	columns, err := rows.Columns()
+	_ = rows.Close()
+	if err != nil {
+		return err
+	}
+	if slices.Contains(columns, "logfile_row") {
+		return nil
+	}
+
+	alter := "ALTER TABLE " + table + " ADD COLUMN " + s.dialect.quote("logfile_row") + " INTEGER NOT NULL DEFAULT 0"
+	_, err = s.db.ExecContext(ctx, alter)
+	return err
+}
+
+// Handle 处理一批消息，可作为Handler使用
+func (s *SQLSink) Handle(ctx context.Context, message *Message) error {
+	events, err := ParseChangeEvents(message)
+	if err != nil {
+		return err
+	}
+	return s.Write(ctx, events)
+}
+
+// Write 按源事务回放变更，每个源事务在一个数据库事务中执行并记录位点
+func (s *SQLSink) Write(ctx context.Context, events []ChangeEvent) error {
+	if err := s.Init(ctx); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, transaction := range sourceTransactions(events) {
+		if err := s.write(ctx, transaction); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sourceTransactions 按源事务拆分变更：同一批次内相邻且事务id相同的变更为一组，DDL单独一组
+func sourceTransactions(events []ChangeEvent) [][]ChangeEvent {
+	var transactions [][]ChangeEvent
+	for i, event := range events {
+		if i > 0 && !event.IsDdl {
+			prev := events[i-1]
+			if !prev.IsDdl && prev.BatchId == event.BatchId && prev.TransactionId == event.TransactionId {
+				transactions[len(transactions)-1] = append(transactions[len(transactions)-1], event)
+				continue
+			}
+		}
+		transactions = append(transactions, []ChangeEvent{event})
+	}
+	return transactions
+}
+
+// write 在一个数据库事务中回放变更并记录位点
+func (s *SQLSink) write(ctx context.Context, events []ChangeEvent) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var (
+		last    *ChangeEvent
+		applied int
+	)
+	for i, event := range events {
+		if !eventPosition(event).after(s.checkpoint) {
+			continue // 已回放过
+		}
+
+		if err = s.apply(ctx, tx, event); err != nil {
+			return err
+		}
+		last = &events[i]
+		applied++
+	}
+
+	if last == nil {
+		return nil
+	}
+
+	if err = s.saveCheckpoint(ctx, tx, *last); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	s.checkpoint = eventPosition(*last)
+
+	s.logger.DebugContext(ctx, "sql sink applied",
+		slog.String("destination", s.destination),
+		slog.Int("events", applied),
+		slog.String("logfileName", last.Header.GetLogfileName()),
+		slog.Int64("logfileOffset", last.Header.GetLogfileOffset()),
+		slog.Int("logfileRow", last.Row),
+	)
+
+	return nil
+}
+
+func (s *SQLSink) apply(ctx context.Context, tx *sql.Tx, event ChangeEvent) error {
+	if event.IsDdl {
+		if !s.ddlPassthrough || event.Sql == "" {
+			return nil
+		}
+		ddl, err := s.mapDdl(event)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, ddl)
+		return err
+	}
+
+	statement, err := s.generator.Generate(event)
+	if err != nil || statement == nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, statement.Query, statement.Args...)
+	return err
+}
+
+// mapDdl 把DDL中的源表名替换为映射后的目标表名，与行变更写入的表一致；RENAME同时替换新表名。
+// 没有表名的DDL(如CREATE DATABASE)原样返回
+func (s *SQLSink) mapDdl(event ChangeEvent) (string, error) {
+	if event.TableName() == "" {
+		return event.Sql, nil
+	}
+
+	tables := [][2]string{{event.SchemaName(), event.TableName()}}
+	if event.EventType == EventType_RENAME {
+		if renames := renameTables(event.SchemaName(), event.Sql); len(renames) > 0 {
+			tables = tables[:0]
+			for _, rename := range renames {
+				for _, name := range rename {
+					schema, table, _ := strings.Cut(name, ".")
+					tables = append(tables, [2]string{schema, table})
+				}
+			}
+		}
+	}
+
+	ddl, start := event.Sql, 0
+	for _, t := range tables {
+		loc := ddlTablePattern(t[0], t[1]).FindStringSubmatchIndex(ddl[start:])
+		if loc == nil {
+			return "", Permanent(errors.Join(ErrDDLTable, fmt.Errorf("table %s.%s not found in %q", t[0], t[1], event.Sql)))
+		}
+		target := s.generator.qualifiedTable(t[0], t[1])
+		begin, end := start+loc[2], start+loc[3]
+		ddl = ddl[:begin] + target + ddl[end:]
+		start = begin + len(target)
+	}
+
+	return ddl, nil
+}
+
+// ddlTablePattern 匹配DDL中的表名，可以带库名和反引号
+func ddlTablePattern(schema, table string) *regexp.Regexp {
+	name := func(name string) string {
+		return "`?" + regexp.QuoteMeta(name) + "`?"
+	}
+	return regexp.MustCompile(`(?i)(?:^|[\s,(])((?:` + name(schema) + `\s*\.\s*)?` + name(table) + `)(?:$|[\s,(;])`)
+}
+
+// saveCheckpoint 在事务中保存位点
+func (s *SQLSink) saveCheckpoint(ctx context.Context, tx *sql.Tx, event ChangeEvent) error {
+	column := func(name string, isKey bool, value string) *Column {
+		return &Column{Name: name, IsKey: isKey, Value: value, Updated: true}
+	}
+
+	statement, err := NewSQLGenerator(s.dialect, WithUpsert(true)).Generate(ChangeEvent{
+		Header:    &Header{TableName: s.checkpointTable},
+		EventType: EventType_INSERT,
+		RowData: &RowData{AfterColumns: []*Column{
+			column("destination", true, s.destination),
+			column("logfile_name", false, event.Header.GetLogfileName()),
+			column("logfile_offset", false, strconv.FormatInt(event.Header.GetLogfileOffset(), 10)),
+			column("logfile_row", false, strconv.Itoa(event.Row)),
+			column("gtid", false, event.Header.GetGtid()),
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, statement.Query, statement.Args...)
+	return err
+}
@@ -0,0 +1,384 @@
+package icanal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqlRow 测试表user的一行，age为空时为NULL
+func sqlRow(id, name, age string, updated ...string) []*Column {
+	columns := []*Column{
+		{Index: 0, Name: "id", IsKey: true, MysqlType: "int", Value: id},
+		{Index: 1, Name: "name", MysqlType: "varchar(10)", Value: name},
+		{Index: 2, Name: "age", MysqlType: "int", Value: age, IsNullPresent: &Column_IsNull{IsNull: age == ""}},
+	}
+	for _, column := range columns {
+		for _, name := range updated {
+			if column.Name == name {
+				column.Updated = true
+			}
+		}
+	}
+	return columns
+}
+
+// sqlEntry 指定位点的行变更Entry；rows依次为变更前、变更后的列，INSERT只有变更后，DELETE只有变更前
+func sqlEntry(offset int64, eventType EventType, rows ...[]*Column) *Entry {
+	header := newTestHeader(eventType)
+	header.LogfileOffset = offset
+
+	rowChange := &RowChange{EventTypePresent: &RowChange_EventType{EventType: eventType}}
+	for _, row := range rows {
+		switch eventType {
+		case EventType_INSERT:
+			rowChange.RowDatas = append(rowChange.RowDatas, &RowData{AfterColumns: row})
+		case EventType_DELETE:
+			rowChange.RowDatas = append(rowChange.RowDatas, &RowData{BeforeColumns: row})
+		}
+	}
+	if eventType == EventType_UPDATE {
+		for i := 0; i+1 < len(rows); i += 2 {
+			rowChange.RowDatas = append(rowChange.RowDatas, &RowData{BeforeColumns: rows[i], AfterColumns: rows[i+1]})
+		}
+	}
+	return newRowDataEntry(header, rowChange)
+}
+
+// openSQLiteUser 打开dir下的sqlite数据库，创建user表
+func openSQLiteUser(t *testing.T, dir string) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(dir, "sink.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	if _, err = db.Exec(`CREATE TABLE IF NOT EXISTS user (id INTEGER PRIMARY KEY, name TEXT NOT NULL, age INTEGER)`); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// userRows user表的全部行，按id排序
+func userRows(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+
+	rows, err := db.Query(`SELECT id, name, age FROM user ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var got []string
+	for rows.Next() {
+		var (
+			id   int
+			name string
+			age  sql.NullInt64
+		)
+		if err = rows.Scan(&id, &name, &age); err != nil {
+			t.Fatal(err)
+		}
+		if age.Valid {
+			got = append(got, fmt.Sprintf("%d %s %d", id, name, age.Int64))
+		} else {
+			got = append(got, fmt.Sprintf("%d %s NULL", id, name))
+		}
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestSQLSink_SplitEntry(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	db := openSQLiteUser(t, dir)
+
+	message := &Message{Id: 1, Entries: []*Entry{
+		sqlEntry(100, EventType_INSERT, sqlRow("1", "a", ""), sqlRow("2", "b", ""), sqlRow("3", "c", "")),
+		sqlEntry(200, EventType_UPDATE,
+			sqlRow("1", "a", ""), sqlRow("1", "a", "10", "age"),
+			sqlRow("2", "b", ""), sqlRow("2", "b", "20", "age"),
+			sqlRow("3", "c", ""), sqlRow("3", "c", "30", "age"),
+		),
+	}}
+	events, err := ParseChangeEvents(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 多行UPDATE逐行写入：同一Entry的行共享位点，位点中的行号保证后面的行不被跳过
+	sink := NewSQLSink(db, DialectSQLite, "example")
+	for _, event := range events {
+		if err = sink.Write(ctx, []ChangeEvent{event}); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	want := []string{"1 a 10", "2 b 20", "3 c 30"}
+	if got := userRows(t, db); !reflect.DeepEqual(got, want) {
+		t.Fatalf("rows = %v, want %v", got, want)
+	}
+
+	// 重启后整批重投，全部跳过；重复INSERT会违反主键约束
+	if err = NewSQLSink(db, DialectSQLite, "example").Write(ctx, events); err != nil {
+		t.Fatalf("Write() after restart error = %v", err)
+	}
+	if got := userRows(t, db); !reflect.DeepEqual(got, want) {
+		t.Errorf("rows after restart = %v, want %v", got, want)
+	}
+}
+
+func TestSQLSink(t *testing.T) {
+	dir := t.TempDir()
+	server := newFakeCanalServer(t,
+		[]*Entry{sqlEntry(100, EventType_INSERT, sqlRow("1", "a", ""), sqlRow("2", "b", "20"), sqlRow("3", "c", "30"))},
+		[]*Entry{
+			sqlEntry(200, EventType_UPDATE, sqlRow("1", "a", ""), sqlRow("1", "a", "10", "age")),
+			sqlEntry(300, EventType_UPDATE, sqlRow("2", "b", "20"), sqlRow("20", "b", "20", "id")),
+			sqlEntry(400, EventType_DELETE, sqlRow("3", "c", "30")),
+		},
+		[]*Entry{sqlEntry(500, EventType_INSERT, sqlRow("4", "d", "40"), sqlRow("3", "c", "31"))},
+	)
+
+	errCrash := errors.New("crash before ack")
+	run := func(ctx context.Context, handler Handler) error {
+		connector := NewSimpleConnector(server.Address(), "example", WithLogger(QuietLogger()))
+		return NewConsumer(connector, handler,
+			WithConsumerFilter(`test\..*`),
+			WithIdleInterval(10*time.Millisecond),
+			WithConsumerLogger(QuietLogger()),
+		).Run(ctx)
+	}
+
+	// 第一次运行：第二批写入成功后在确认前崩溃，canal会重投该批
+	db := openSQLiteUser(t, dir)
+	sink := NewSQLSink(db, DialectSQLite, "example")
+	err := run(context.Background(), func(ctx context.Context, message *Message) error {
+		if err := sink.Handle(ctx, message); err != nil {
+			return err
+		}
+		if message.Id == 2 {
+			return errCrash
+		}
+		return nil
+	})
+	if !errors.Is(err, errCrash) {
+		t.Fatalf("Run() error = %v, want %v", err, errCrash)
+	}
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重启：从目标库的位点继续，重投的批次不会重复回放
+	db = openSQLiteUser(t, dir)
+	sink = NewSQLSink(db, DialectSQLite, "example")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- run(ctx, sink.Handle) }()
+	waitFor(t, "all batches acked", func() bool { return len(server.Acked()) == 3 })
+	cancel()
+	if err = <-done; err != nil {
+		t.Fatalf("Run() after restart error = %v", err)
+	}
+
+	want := []string{"1 a 10", "3 c 31", "4 d 40", "20 b 20"}
+	if got := userRows(t, db); !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %v, want %v", got, want)
+	}
+
+	var (
+		file   string
+		offset int64
+		row    int
+	)
+	err = db.QueryRow(`SELECT logfile_name, logfile_offset, logfile_row FROM icanal_checkpoint WHERE destination = ?`, "example").
+		Scan(&file, &offset, &row)
+	if err != nil || file != "mysql-bin.000001" || offset != 500 || row != 1 {
+		t.Errorf("checkpoint = %s:%d:%d, %v, want mysql-bin.000001:500:1", file, offset, row, err)
+	}
+}
+
+func TestSQLSink_MigrateCheckpoint(t *testing.T) {
+	db := openSQLiteUser(t, t.TempDir())
+	_, err := db.Exec(`CREATE TABLE icanal_checkpoint (destination VARCHAR(255) NOT NULL PRIMARY KEY,
+		logfile_name VARCHAR(255) NOT NULL, logfile_offset BIGINT NOT NULL, gtid TEXT NOT NULL)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.Exec(`INSERT INTO icanal_checkpoint VALUES ('example', 'mysql-bin.000001', 100, '')`); err != nil {
+		t.Fatal(err)
+	}
+
+	// 旧位点表补上行号列，位点之前的变更跳过
+	sink := NewSQLSink(db, DialectSQLite, "example")
+	events, err := ParseChangeEvents(&Message{Entries: []*Entry{
+		sqlEntry(100, EventType_INSERT, sqlRow("1", "a", "")),
+		sqlEntry(200, EventType_INSERT, sqlRow("2", "b", "")),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = sink.Write(context.Background(), events); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if got, want := userRows(t, db), []string{"2 b NULL"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %v, want %v", got, want)
+	}
+}
+
+// sqlTransactionEnd 指定位点的事务结束Entry
+func sqlTransactionEnd(offset int64, transactionId string) *Entry {
+	header := newTestHeader(EventType_QUERY)
+	header.LogfileOffset = offset
+	return &Entry{
+		Header:           header,
+		EntryTypePresent: &Entry_EntryType{EntryType: EntryType_TRANSACTIONEND},
+		StoreValue:       mustMarshal(&TransactionEnd{TransactionId: transactionId}),
+	}
+}
+
+// sqlDdl 指定位点的DDL Entry
+func sqlDdl(offset int64, eventType EventType, table, ddl string) *Entry {
+	header := newTestHeader(eventType)
+	header.LogfileOffset = offset
+	header.TableName = table
+	return newRowDataEntry(header, &RowChange{
+		EventTypePresent: &RowChange_EventType{EventType: eventType},
+		IsDdlPresent:     &RowChange_IsDdl{IsDdl: true},
+		Sql:              ddl,
+	})
+}
+
+func TestSQLSink_Transaction(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLiteUser(t, t.TempDir())
+
+	events, err := ParseChangeEvents(&Message{Id: 1, Entries: []*Entry{
+		sqlEntry(100, EventType_INSERT, sqlRow("1", "a", "")),
+		sqlTransactionEnd(150, "1"),
+		sqlEntry(200, EventType_INSERT, sqlRow("2", "b", "")),
+		sqlEntry(300, EventType_INSERT, sqlRow("1", "a", "")),
+		sqlTransactionEnd(350, "2"),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 第二个源事务违反主键约束整体回滚，第一个源事务已经提交
+	if err = NewSQLSink(db, DialectSQLite, "example").Write(ctx, events); err == nil {
+		t.Fatal("Write() error = nil, want constraint error")
+	}
+	if got, want := userRows(t, db), []string{"1 a NULL"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %v, want %v", got, want)
+	}
+
+	// 重投时跳过已提交的源事务
+	if err = NewSQLSink(db, DialectSQLite, "example").Write(ctx, events[:1]); err != nil {
+		t.Errorf("Write() committed transaction error = %v", err)
+	}
+}
+
+func TestSQLSink_DDLMapping(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLiteUser(t, t.TempDir())
+
+	sink := NewSQLSink(db, DialectSQLite, "example", WithDDLPassthrough(true),
+		WithTableMapping(map[string]string{"test.user": "member", "test.user_old": "member_old"}))
+	events, err := ParseChangeEvents(&Message{Id: 1, Entries: []*Entry{
+		sqlDdl(100, EventType_CREATE, "user", "CREATE TABLE `test`.`user` (id INTEGER PRIMARY KEY, name TEXT NOT NULL, age INTEGER)"),
+		sqlEntry(200, EventType_INSERT, sqlRow("1", "a", "")),
+		sqlDdl(300, EventType_RENAME, "user_old", "ALTER TABLE user RENAME TO user_old"),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = sink.Write(ctx, events); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	var name string
+	if err = db.QueryRow(`SELECT name FROM member_old WHERE id = 1`).Scan(&name); err != nil || name != "a" {
+		t.Errorf("member_old name = %q (%v), want a", name, err)
+	}
+	if got := userRows(t, db); len(got) != 0 {
+		t.Errorf("source table rows = %v, want none", got)
+	}
+
+	// 找不到表名时不能原样执行到源表
+	events, err = ParseChangeEvents(&Message{Id: 2, Entries: []*Entry{
+		sqlDdl(400, EventType_ALTER, "orders", "ALTER TABLE user ADD COLUMN email TEXT"),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = sink.Write(ctx, events); !errors.Is(err, ErrDDLTable) || !errors.Is(err, ErrPermanent) {
+		t.Errorf("Write() error = %v, want permanent %v", err, ErrDDLTable)
+	}
+}
+
+func TestBinlogPosition_After(t *testing.T) {
+	const (
+		uuid1 = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+		uuid2 = "4f22eb58-71ca-11e1-9e33-c80aa9429562"
+	)
+
+	tests := []struct {
+		name string
+		p, o binlogPosition
+		want bool
+	}{
+		{"empty checkpoint", binlogPosition{file: "mysql-bin.000001"}, binlogPosition{}, true},
+		{"later offset", binlogPosition{file: "mysql-bin.000001", offset: 200}, binlogPosition{file: "mysql-bin.000001", offset: 100}, true},
+		{"later row", binlogPosition{file: "mysql-bin.000001", offset: 100, row: 1}, binlogPosition{file: "mysql-bin.000001", offset: 100}, true},
+		{"longer file", binlogPosition{file: "mysql-bin.1000000"}, binlogPosition{file: "mysql-bin.999999", offset: 100}, true},
+		{
+			"failover newer gtid",
+			binlogPosition{file: "mysql-bin.000001", offset: 100, gtid: uuid1 + ":1-23," + uuid2 + ":1"},
+			binlogPosition{file: "mysql-bin.000009", offset: 900, gtid: uuid1 + ":1-23"},
+			true,
+		},
+		{
+			"failover replayed gtid",
+			binlogPosition{file: "mysql-bin.000020", offset: 100, gtid: uuid1 + ":1-22"},
+			binlogPosition{file: "mysql-bin.000009", offset: 900, gtid: uuid1 + ":1-23"},
+			false,
+		},
+		{
+			"failover same transaction",
+			binlogPosition{file: "mysql-bin.000020", offset: 100, gtid: uuid1 + ":1-23"},
+			binlogPosition{file: "mysql-bin.000009", offset: 900, gtid: uuid1 + ":1-23"},
+			false,
+		},
+		{
+			"same transaction later row",
+			binlogPosition{file: "mysql-bin.000009", offset: 900, row: 1, gtid: uuid1 + ":1-23"},
+			binlogPosition{file: "mysql-bin.000009", offset: 900, gtid: uuid1 + ":1-23"},
+			true,
+		},
+		{
+			"invalid gtid",
+			binlogPosition{file: "mysql-bin.000009", offset: 1000, gtid: "invalid"},
+			binlogPosition{file: "mysql-bin.000009", offset: 900, gtid: uuid1 + ":1-23"},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.p.after(tt.o); got != tt.want {
+				t.Errorf("after() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package icanal
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSinkRunner_Run(t *testing.T) {
+	errTransient := errors.New("connection reset")
+
+	tests := []struct {
+		name       string
+		err        error // 写入id为2的记录时返回的错误
+		deadLetter bool
+		wantErr    error
+		written    []string
+		dead       []string
+		acked      []int64
+	}{
+		{
+			name:       "permanent to dead letter",
+			err:        Permanent(errors.New("poison")),
+			deadLetter: true,
+			written:    []string{"1", "3"},
+			dead:       []string{"2"},
+			acked:      []int64{1, 2},
+		},
+		{
+			name:       "transient rollback",
+			err:        errTransient,
+			deadLetter: true,
+			wantErr:    errTransient,
+		},
+		{
+			name:    "permanent without dead letter",
+			err:     Permanent(errors.New("poison")),
+			wantErr: ErrPermanent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeCanalServer(t,
+				[]*Entry{sqlEntry(100, EventType_INSERT, sqlRow("1", "a", ""), sqlRow("2", "b", ""))},
+				[]*Entry{sqlEntry(200, EventType_INSERT, sqlRow("3", "c", ""))},
+			)
+
+			var written, dead []string
+			sink := SinkFunc(func(_ context.Context, events []ChangeEvent) error {
+				for _, event := range events {
+					if event.RowData.AfterColumns[0].Value == "2" && tt.err != nil {
+						return tt.err
+					}
+				}
+				for _, event := range events {
+					written = append(written, event.RowData.AfterColumns[0].Value)
+				}
+				return nil
+			})
+			opts := []SinkRunnerOption{
+				WithFlushCount(3),
+				WithMaxRetries(1),
+				WithRetryBackoff(time.Millisecond, time.Millisecond),
+				WithConsumerOptions(WithIdleInterval(10*time.Millisecond), WithConsumerLogger(QuietLogger())),
+			}
+			if tt.deadLetter {
+				opts = append(opts, WithDeadLetter(SinkFunc(func(_ context.Context, events []ChangeEvent) error {
+					dead = append(dead, events[0].RowData.AfterColumns[0].Value)
+					return nil
+				})))
+			}
+			run := func(ctx context.Context) error {
+				connector := NewSimpleConnector(server.Address(), "example", WithLogger(QuietLogger()))
+				return NewSinkRunner(connector, sink, opts...).Run(ctx)
+			}
+
+			if tt.wantErr != nil {
+				if err := run(context.Background()); !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Run() error = %v, want %v", err, tt.wantErr)
+				}
+				if written != nil || dead != nil || len(server.Acked()) != 0 {
+					t.Fatalf("written = %v, dead = %v, acked = %v, want nothing", written, dead, server.Acked())
+				}
+
+				// 回滚后canal以新的批次id重投全部记录
+				tt.err = nil
+				tt.written = []string{"1", "2", "3"}
+				tt.acked = []int64{3, 4}
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() { done <- run(ctx) }()
+			waitFor(t, "all batches acked", func() bool { return len(server.Acked()) == 2 })
+			cancel()
+			if err := <-done; err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(written, tt.written) {
+				t.Errorf("written = %v, want %v", written, tt.written)
+			}
+			if !reflect.DeepEqual(dead, tt.dead) {
+				t.Errorf("dead = %v, want %v", dead, tt.dead)
+			}
+			if got := server.Acked(); !reflect.DeepEqual(got, tt.acked) {
+				t.Errorf("acked = %v, want %v", got, tt.acked)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package icanal
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	WebhookTimeoutDefault    = 5 * time.Second        // 默认单次请求超时
+	WebhookMaxRetriesDefault = 3                      // 默认5xx/429重试次数
+	WebhookBackoffDefault    = 200 * time.Millisecond // 默认首次重试等待时间，之后指数增长
+
+	WebhookSignatureHeader = "X-Icanal-Signature" // 签名请求头，值为"sha256=<hex(hmac-sha256(secret, body))>"
+)
+
+// WebhookSink 把每条变更以JSON POST到指定URL的sink
+//
+// 同一张表的变更按顺序逐条发送，不同表之间并发发送。5xx和429会重试，优先使用响应的Retry-After作为等待时间；
+// 其他非2xx状态直接返回错误。部分表发送失败时整批返回错误，重试时已成功的变更会重复发送，接收方需要幂等。
+type WebhookSink struct {
+	url        string
+	client     *http.Client
+	timeout    time.Duration
+	maxRetries int
+	backoff    time.Duration
+	secret     []byte
+	format     SinkFormat
+	header     http.Header
+	logger     *slog.Logger
+}
+
+type WebhookOption func(*WebhookSink)
+
+// WithWebhookLogger 设置日志
+func WithWebhookLogger(logger *slog.Logger) WebhookOption {
+	return func(s *WebhookSink) {
+		s.logger = logger
+	}
+}
+
+// WithWebhookClient 设置http客户端
+func WithWebhookClient(client *http.Client) WebhookOption {
+	return func(s *WebhookSink) {
+		s.client = client
+	}
+}
+
+// WithWebhookTimeout 设置单次请求超时
+func WithWebhookTimeout(timeout time.Duration) WebhookOption {
+	return func(s *WebhookSink) {
+		s.timeout = timeout
+	}
+}
+
+// WithWebhookRetry 设置5xx/429的重试次数和首次等待时间
+func WithWebhookRetry(maxRetries int, backoff time.Duration) WebhookOption {
+	return func(s *WebhookSink) {
+		s.maxRetries = maxRetries
+		s.backoff = backoff
+	}
+}
+
+// WithWebhookSecret 设置HMAC签名密钥；为空时不签名
+func WithWebhookSecret(secret string) WebhookOption {
+	return func(s *WebhookSink) {
+		s.secret = []byte(secret)
+	}
+}
+
+// WithWebhookFormat 设置请求体格式，默认原生格式
+func WithWebhookFormat(format SinkFormat) WebhookOption {
+	return func(s *WebhookSink) {
+		s.format = format
+	}
+}
+
+// WithWebhookHeader 添加固定请求头
+func WithWebhookHeader(key, value string) WebhookOption {
+	return func(s *WebhookSink) {
+		s.header.Add(key, value)
+	}
+}
+
+// NewWebhookSink 新建webhook sink
+func NewWebhookSink(url string, opts ...WebhookOption) *WebhookSink {
+	s := &WebhookSink{
+		url:        url,
+		client:     http.DefaultClient,
+		timeout:    WebhookTimeoutDefault,
+		maxRetries: WebhookMaxRetriesDefault,
+		backoff:    WebhookBackoffDefault,
+		header:     make(http.Header),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.logger = loggerOrDefault(s.logger)
+	return s
+}
+
+// Write 按表分组发送，组内保持顺序
+func (s *WebhookSink) Write(ctx context.Context, events []ChangeEvent) error {
+	var (
+		tables [][]ChangeEvent
+		index  = make(map[string]int)
+	)
+	for _, event := range events {
+		key := event.SchemaName() + "." + event.TableName()
+		i, ok := index[key]
+		if !ok {
+			i = len(tables)
+			index[key] = i
+			tables = append(tables, nil)
+		}
+		tables[i] = append(tables[i], event)
+	}
+
+	// 一张表失败时停止其他表的发送
+	sendCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+		errs  []error
+	)
+	for _, table := range tables {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, event := range table {
+				if err := s.send(sendCtx, event); err != nil {
+					if sendCtx.Err() == nil {
+						mutex.Lock()
+						errs = append(errs, err)
+						mutex.Unlock()
+					}
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return ctx.Err()
+}
+
+// send 发送一条变更，5xx和429时重试
+func (s *WebhookSink) send(ctx context.Context, event ChangeEvent) error {
+	body, err := s.format.marshal(event)
+	if err != nil {
+		return err
+	}
+
+	backoff := s.backoff
+	for times := 0; ; times++ {
+		wait, err := s.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if wait < 0 || times >= s.maxRetries {
+			return err
+		}
+
+		if wait == 0 {
+			wait = backoff
+			backoff *= 2
+		}
+
+		s.logger.WarnContext(ctx, "webhook request failed, retrying",
+			slog.String("url", s.url),
+			slog.Int("retryTimes", times+1),
+			slog.Duration("wait", wait),
+			slog.Any("error", err),
+		)
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(ctx.Err(), err)
+		case <-time.After(wait):
+		}
+	}
+}
+
+// post 发送一次请求；返回的等待时间小于0表示不可重试，等于0表示使用默认退避
+func (s *WebhookSink) post(ctx context.Context, body []byte) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+	for key, values := range s.header {
+		request.Header[key] = values
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if len(s.secret) > 0 {
+		mac := hmac.New(sha256.New, s.secret)
+		mac.Write(body)
+		request.Header.Set(WebhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return 0, err // 网络错误和超时可以重试
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return 0, nil
+	}
+
+	err = fmt.Errorf("%w: %s", ErrWebhookStatus, response.Status)
+	if response.StatusCode != http.StatusTooManyRequests && response.StatusCode < 500 {
+		return -1, Permanent(err)
+	}
+	return retryAfter(response.Header.Get("Retry-After")), err
+}
+
+// retryAfter 解析Retry-After，支持秒数和HTTP日期；无法解析时返回0
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
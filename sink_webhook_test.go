@@ -0,0 +1,125 @@
+package icanal
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhookSink_Write(t *testing.T) {
+	const secret = "secret"
+
+	var (
+		mutex    sync.Mutex
+		received = make(map[string][]string) // table -> id
+		requests int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		if r.Header.Get(WebhookSignatureHeader) != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var flatMessage FlatMessage
+		if err := json.Unmarshal(body, &flatMessage); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		requests++
+		// 每三个请求中的第一个返回429
+		if requests%3 == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		received[flatMessage.Table] = append(received[flatMessage.Table], *flatMessage.Data[0]["id"])
+	}))
+	defer server.Close()
+
+	var events []ChangeEvent
+	want := make(map[string][]string)
+	for id := 0; id < 4; id++ {
+		for _, table := range []string{"orders", "users"} {
+			events = append(events, newRowEvent(table, fmt.Sprint(id), "0"))
+			want[table] = append(want[table], fmt.Sprint(id))
+		}
+	}
+
+	sink := NewWebhookSink(server.URL,
+		WithWebhookSecret(secret),
+		WithWebhookFormat(SinkFormatFlat),
+		WithWebhookRetry(3, time.Millisecond),
+		WithWebhookLogger(QuietLogger()),
+	)
+	if err := sink.Write(context.Background(), events); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if !reflect.DeepEqual(received, want) {
+		t.Errorf("received = %v, want %v", received, want)
+	}
+}
+
+func TestWebhookSink_WriteStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		requests int
+	}{
+		{name: "client error", status: http.StatusBadRequest, requests: 1},
+		{name: "server error", status: http.StatusServiceUnavailable, requests: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			sink := NewWebhookSink(server.URL, WithWebhookRetry(2, time.Millisecond), WithWebhookLogger(QuietLogger()))
+			err := sink.Write(context.Background(), []ChangeEvent{newRowEvent("orders", "1", "0")})
+			if !errors.Is(err, ErrWebhookStatus) {
+				t.Errorf("Write() error = %v, want %v", err, ErrWebhookStatus)
+			}
+			if requests != tt.requests {
+				t.Errorf("requests = %d, want %d", requests, tt.requests)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: 0},
+		{value: "3", want: 3 * time.Second},
+		{value: "invalid", want: 0},
+		{value: "Mon, 02 Jan 2006 15:04:05 GMT", want: 0},
+	}
+	for _, tt := range tests {
+		if got := retryAfter(tt.value); got != tt.want {
+			t.Errorf("retryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package icanal
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SQLDialect 目标数据库方言
+type SQLDialect int
+
+const (
+	DialectMySQL SQLDialect = iota
+	DialectPostgreSQL
+	DialectSQLite
+)
+
+// quote 引用单个标识符；名称中的"."是标识符的一部分
+func (d SQLDialect) quote(name string) string {
+	if d == DialectMySQL {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteTable 引用表名；schema为空时不带库名
+func (d SQLDialect) quoteTable(schema, table string) string {
+	if schema == "" {
+		return d.quote(table)
+	}
+	return d.quote(schema) + "." + d.quote(table)
+}
+
+// placeholder 第n个(从1开始)参数的占位符
+func (d SQLDialect) placeholder(n int) string {
+	if d == DialectPostgreSQL {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+// SQLStatement 带参数的sql语句
+type SQLStatement struct {
+	Query string
+	Args  []any
+}
+
+// SQLGenerator 把行变更转换为INSERT/UPDATE/DELETE语句，用于回放到其他数据库
+//
+// UPDATE只SET标记为Updated的列，UPDATE和DELETE用主键列(IsKey)生成WHERE条件；
+// upsert模式下INSERT和不修改主键的UPDATE生成插入或更新语句，目标表缺行时也能回放。
+type SQLGenerator struct {
+	dialect    SQLDialect
+	upsert     bool
+	schemaName func(schema, table string) string
+	tableName  func(schema, table string) string
+	columnName func(schema, table, column string) string
+}
+
+type SQLGeneratorOption func(*SQLGenerator)
+
+// WithUpsert 是否使用upsert模式
+func WithUpsert(upsert bool) SQLGeneratorOption {
+	return func(g *SQLGenerator) {
+		g.upsert = upsert
+	}
+}
+
+// WithSchemaName 目标库名映射；返回空时不带库名，默认不带库名
+func WithSchemaName(schemaName func(schema, table string) string) SQLGeneratorOption {
+	return func(g *SQLGenerator) {
+		g.schemaName = schemaName
+	}
+}
+
+// WithTableName 目标表名映射；默认使用源表名
+func WithTableName(tableName func(schema, table string) string) SQLGeneratorOption {
+	return func(g *SQLGenerator) {
+		g.tableName = tableName
+	}
+}
+
+// WithColumnName 目标列名映射；默认使用源列名
+func WithColumnName(columnName func(schema, table, column string) string) SQLGeneratorOption {
+	return func(g *SQLGenerator) {
+		g.columnName = columnName
+	}
+}
+
+// NewSQLGenerator 新建sql生成器
+func NewSQLGenerator(dialect SQLDialect, opts ...SQLGeneratorOption) *SQLGenerator {
+	g := &SQLGenerator{
+		dialect: dialect,
+		schemaName: func(_, _ string) string {
+			return ""
+		},
+		tableName: func(_, table string) string {
+			return table
+		},
+		columnName: func(_, _, column string) string {
+			return column
+		},
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Generate 生成语句；DDL、没有变化的UPDATE以及其他事件类型返回nil
+func (g *SQLGenerator) Generate(event ChangeEvent) (*SQLStatement, error) {
+	if event.IsDdl {
+		return nil, nil
+	}
+
+	schema, table := event.SchemaName(), event.TableName()
+	t := sqlTable{
+		SQLGenerator: g,
+		schema:       schema,
+		table:        table,
+		name:         g.qualifiedTable(schema, table),
+	}
+	rowData := event.RowData
+
+	switch event.EventType {
+	case EventType_INSERT:
+		if g.upsert {
+			return t.upsert(rowData.GetAfterColumns()), nil
+		}
+		return t.insert(rowData.GetAfterColumns()), nil
+	case EventType_UPDATE:
+		if g.upsert && !keyUpdated(rowData.GetAfterColumns()) {
+			return t.upsert(rowData.GetAfterColumns()), nil
+		}
+		return t.update(rowData.GetBeforeColumns(), rowData.GetAfterColumns())
+	case EventType_DELETE:
+		return t.delete(rowData.GetBeforeColumns())
+	}
+
+	return nil, nil
+}
+
+// qualifiedTable 源表映射后引用的目标表名
+func (g *SQLGenerator) qualifiedTable(schema, table string) string {
+	return g.dialect.quoteTable(g.schemaName(schema, table), g.tableName(schema, table))
+}
+
+// sqlTable 生成单张表的语句
+type sqlTable struct {
+	*SQLGenerator
+	schema string
+	table  string
+	name   string // 引用后的目标表名
+}
+
+// column 引用后的目标列名
+func (t sqlTable) column(column *Column) string {
+	return t.dialect.quote(t.columnName(t.schema, t.table, column.GetName()))
+}
+
+func (t sqlTable) insert(columns []*Column) *SQLStatement {
+	names := make([]string, 0, len(columns))
+	placeholders := make([]string, 0, len(columns))
+	args := make([]any, 0, len(columns))
+	for i, column := range columns {
+		names = append(names, t.column(column))
+		placeholders = append(placeholders, t.dialect.placeholder(i+1))
+		args = append(args, sqlValue(column))
+	}
+
+	return &SQLStatement{
+		Query: "INSERT INTO " + t.name + " (" + strings.Join(names, ", ") + ") VALUES (" + strings.Join(placeholders, ", ") + ")",
+		Args:  args,
+	}
+}
+
+func (t sqlTable) upsert(columns []*Column) *SQLStatement {
+	statement := t.insert(columns)
+
+	var keys, sets []string
+	for _, column := range columns {
+		name := t.column(column)
+		if column.GetIsKey() {
+			keys = append(keys, name)
+			continue
+		}
+		if t.dialect == DialectMySQL {
+			sets = append(sets, name+" = VALUES("+name+")")
+		} else {
+			sets = append(sets, name+" = EXCLUDED."+name)
+		}
+	}
+
+	switch {
+	case t.dialect == DialectMySQL && len(sets) > 0:
+		statement.Query += " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+	case t.dialect == DialectMySQL:
+		statement.Query = "INSERT IGNORE" + strings.TrimPrefix(statement.Query, "INSERT")
+	case len(keys) == 0:
+		// 没有主键无法判断冲突，退化为普通插入
+	case len(sets) > 0:
+		statement.Query += " ON CONFLICT (" + strings.Join(keys, ", ") + ") DO UPDATE SET " + strings.Join(sets, ", ")
+	default:
+		statement.Query += " ON CONFLICT (" + strings.Join(keys, ", ") + ") DO NOTHING"
+	}
+
+	return statement
+}
+
+func (t sqlTable) update(before, after []*Column) (*SQLStatement, error) {
+	var (
+		sets []string
+		args []any
+	)
+	for _, column := range after {
+		if !column.GetUpdated() {
+			continue
+		}
+		args = append(args, sqlValue(column))
+		sets = append(sets, t.column(column)+" = "+t.dialect.placeholder(len(args)))
+	}
+	if len(sets) == 0 {
+		return nil, nil
+	}
+
+	where, args, err := t.where(before, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQLStatement{
+		Query: "UPDATE " + t.name + " SET " + strings.Join(sets, ", ") + " WHERE " + where,
+		Args:  args,
+	}, nil
+}
+
+func (t sqlTable) delete(before []*Column) (*SQLStatement, error) {
+	where, args, err := t.where(before, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQLStatement{
+		Query: "DELETE FROM " + t.name + " WHERE " + where,
+		Args:  args,
+	}, nil
+}
+
+// where 用变更前的主键值生成条件
+func (t sqlTable) where(before []*Column, args []any) (string, []any, error) {
+	var conditions []string
+	for _, column := range before {
+		if !column.GetIsKey() {
+			continue
+		}
+		args = append(args, sqlValue(column))
+		conditions = append(conditions, t.column(column)+" = "+t.dialect.placeholder(len(args)))
+	}
+	if len(conditions) == 0 {
+		return "", nil, Permanent(ErrNoPrimaryKey)
+	}
+
+	return strings.Join(conditions, " AND "), args, nil
+}
+
+// keyUpdated 主键是否被修改
+func keyUpdated(columns []*Column) bool {
+	for _, column := range columns {
+		if column.GetIsKey() && column.GetUpdated() {
+			return true
+		}
+	}
+	return false
+}
+
+// sqlValue 绑定参数；NULL为nil，二进制列还原为字节，其他使用canal的字符串值，由数据库转换类型
+func sqlValue(column *Column) any {
+	if column.GetIsNull() {
+		return nil
+	}
+	if parseMysqlType(column.GetMysqlType()).isBinary() {
+		return latin1Bytes(column.GetValue())
+	}
+	return column.GetValue()
+}
@@ -0,0 +1,219 @@
+package icanal
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestSQLGenerator_Generate(t *testing.T) {
+	insert := ChangeEvent{
+		Header:    newTestHeader(EventType_INSERT),
+		EventType: EventType_INSERT,
+		RowData: &RowData{AfterColumns: []*Column{
+			newTestColumn(0, "id", true, "1", true),
+			newTestColumn(1, "name", false, "alice", true),
+			newTestColumn(2, "age", false, "NULL", true),
+		}},
+	}
+	update := ChangeEvent{
+		Header:    newTestHeader(EventType_UPDATE),
+		EventType: EventType_UPDATE,
+		RowData: &RowData{
+			BeforeColumns: []*Column{
+				newTestColumn(0, "id", true, "1", false),
+				newTestColumn(1, "name", false, "alice", false),
+				newTestColumn(2, "age", false, "NULL", false),
+			},
+			AfterColumns: []*Column{
+				newTestColumn(0, "id", true, "1", false),
+				newTestColumn(1, "name", false, "alice", false),
+				newTestColumn(2, "age", false, "18", true),
+			},
+		},
+	}
+	remove := ChangeEvent{
+		Header:    newTestHeader(EventType_DELETE),
+		EventType: EventType_DELETE,
+		RowData: &RowData{BeforeColumns: []*Column{
+			newTestColumn(0, "id", true, "1", false),
+			newTestColumn(1, "name", false, "alice", false),
+		}},
+	}
+
+	tests := []struct {
+		name    string
+		dialect SQLDialect
+		upsert  bool
+		event   ChangeEvent
+		want    *SQLStatement
+		wantErr error
+	}{
+		{
+			name:    "mysql insert",
+			dialect: DialectMySQL,
+			event:   insert,
+			want:    &SQLStatement{"INSERT INTO `user` (`id`, `name`, `age`) VALUES (?, ?, ?)", []any{"1", "alice", nil}},
+		},
+		{
+			name:    "mysql upsert",
+			dialect: DialectMySQL,
+			upsert:  true,
+			event:   insert,
+			want: &SQLStatement{"INSERT INTO `user` (`id`, `name`, `age`) VALUES (?, ?, ?) " +
+				"ON DUPLICATE KEY UPDATE `name` = VALUES(`name`), `age` = VALUES(`age`)", []any{"1", "alice", nil}},
+		},
+		{
+			name:    "postgres upsert",
+			dialect: DialectPostgreSQL,
+			upsert:  true,
+			event:   update,
+			want: &SQLStatement{`INSERT INTO "user" ("id", "name", "age") VALUES ($1, $2, $3) ` +
+				`ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name", "age" = EXCLUDED."age"`, []any{"1", "alice", "18"}},
+		},
+		{
+			name:    "postgres update",
+			dialect: DialectPostgreSQL,
+			event:   update,
+			want:    &SQLStatement{`UPDATE "user" SET "age" = $1 WHERE "id" = $2`, []any{"18", "1"}},
+		},
+		{
+			name:    "sqlite delete",
+			dialect: DialectSQLite,
+			event:   remove,
+			want:    &SQLStatement{`DELETE FROM "user" WHERE "id" = ?`, []any{"1"}},
+		},
+		{
+			name:    "no primary key",
+			dialect: DialectMySQL,
+			event: ChangeEvent{
+				Header:    newTestHeader(EventType_DELETE),
+				EventType: EventType_DELETE,
+				RowData:   &RowData{BeforeColumns: []*Column{newTestColumn(1, "name", false, "alice", false)}},
+			},
+			wantErr: ErrNoPrimaryKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewSQLGenerator(tt.dialect, WithUpsert(tt.upsert)).Generate(tt.event)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Generate() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Generate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLGenerator_SQLite(t *testing.T) {
+	columns := func(id, name, age string, updated ...string) []*Column {
+		values := []struct{ name, value, mysqlType string }{
+			{"id", id, "int"}, {"name", name, "varchar(10)"}, {"age", age, "int"}, {"data", "ÿ\u0000", "blob"},
+		}
+		result := make([]*Column, 0, len(values))
+		for i, v := range values {
+			result = append(result, &Column{
+				Index:         int32(i),
+				Name:          v.name,
+				IsKey:         v.name == "id",
+				Value:         v.value,
+				MysqlType:     v.mysqlType,
+				IsNullPresent: &Column_IsNull{IsNull: v.value == "NULL"},
+				Updated:       slices.Contains(updated, v.name),
+			})
+		}
+		return result
+	}
+	event := func(eventType EventType, before, after []*Column) ChangeEvent {
+		return ChangeEvent{
+			Header:    newTestHeader(eventType),
+			EventType: eventType,
+			RowData:   &RowData{BeforeColumns: before, AfterColumns: after},
+		}
+	}
+	changes := []ChangeEvent{
+		event(EventType_INSERT, nil, columns("1", "alice", "NULL")),
+		event(EventType_INSERT, nil, columns("2", "bob", "20")),
+		event(EventType_UPDATE, columns("1", "alice", "NULL"), columns("1", "alice", "18", "age")),
+		event(EventType_UPDATE, columns("2", "bob", "20"), columns("3", "bob", "20", "id")),
+		event(EventType_DELETE, columns("1", "alice", "18"), nil),
+	}
+
+	tests := []struct {
+		name   string
+		upsert bool
+		events []ChangeEvent
+		want   []string
+	}{
+		{"plain", false, changes, []string{"3 bob 20 ff00"}},
+		{
+			// 重放已经存在的行不会冲突
+			name:   "upsert",
+			upsert: true,
+			events: append(append(slices.Clone(changes[:2]), changes...), event(EventType_INSERT, nil, columns("3", "carol", "30"))),
+			want:   []string{"3 carol 30 ff00"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := sql.Open("sqlite", ":memory:")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			db.SetMaxOpenConns(1)
+
+			// 表名中的"."是表名的一部分，库名单独引用
+			if _, err = db.Exec(`CREATE TABLE "user.v2" (id INTEGER PRIMARY KEY, name TEXT, age INTEGER, data BLOB)`); err != nil {
+				t.Fatal(err)
+			}
+			generator := NewSQLGenerator(DialectSQLite,
+				WithUpsert(tt.upsert),
+				WithSchemaName(func(string, string) string { return "main" }),
+				WithTableName(func(string, string) string { return "user.v2" }),
+			)
+			for _, e := range tt.events {
+				statement, err := generator.Generate(e)
+				if err != nil {
+					t.Fatalf("Generate() error = %v", err)
+				}
+				if _, err = db.Exec(statement.Query, statement.Args...); err != nil {
+					t.Fatalf("Exec(%s) error = %v", statement.Query, err)
+				}
+			}
+
+			rows, err := db.Query(`SELECT id, name, age, data FROM "user.v2" ORDER BY id`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+
+			var got []string
+			for rows.Next() {
+				var (
+					id   int
+					name string
+					age  sql.NullInt64
+					data []byte
+				)
+				if err = rows.Scan(&id, &name, &age, &data); err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, fmt.Sprintf("%d %s %d %x", id, name, age.Int64, data))
+			}
+			if err = rows.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rows = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
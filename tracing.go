@@ -0,0 +1,138 @@
+package icanal
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName = "github.com/kalvinzhang/icanal"
+
+	AttributeDestination   = attribute.Key("icanal.destination")
+	AttributeBatchId       = attribute.Key("icanal.batch_id")
+	AttributeEntries       = attribute.Key("icanal.entries")
+	AttributeLogfileName   = attribute.Key("icanal.logfile_name")
+	AttributeLogfileOffset = attribute.Key("icanal.logfile_offset")
+	AttributeCommitLatency = attribute.Key("icanal.commit_latency_ms") // 从binlog提交到处理完成的毫秒数
+)
+
+// tracer 为nil时使用全局TracerProvider
+func tracer(tracerProvider trace.TracerProvider) trace.Tracer {
+	if tracerProvider == nil {
+		tracerProvider = otel.GetTracerProvider()
+	}
+	return tracerProvider.Tracer(tracerName)
+}
+
+// messageAttributes 批次的属性；位点取最后一个Entry
+func messageAttributes(message *Message) []attribute.KeyValue {
+	if message == nil {
+		return nil
+	}
+
+	attributes := []attribute.KeyValue{AttributeBatchId.Int64(message.Id)}
+	if message.Raw {
+		raws, _ := message.RawEntries.([][]byte)
+		return append(attributes, AttributeEntries.Int(len(raws)))
+	}
+
+	attributes = append(attributes, AttributeEntries.Int(len(message.Entries)))
+	if len(message.Entries) > 0 {
+		header := message.Entries[len(message.Entries)-1].GetHeader()
+		attributes = append(attributes,
+			AttributeLogfileName.String(header.GetLogfileName()),
+			AttributeLogfileOffset.Int64(header.GetLogfileOffset()),
+		)
+	}
+	return attributes
+}
+
+// endSpan 记录错误并结束span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tracingConnector 记录span的连接器
+type tracingConnector struct {
+	Connector
+	destination string
+	tracer      trace.Tracer
+}
+
+// NewTracingConnector 包装连接器，为Connect、Subscribe、GetWithoutAck、Ack和Rollback记录span；
+// tracerProvider为nil时使用全局TracerProvider
+func NewTracingConnector(connector Connector, destination string, tracerProvider trace.TracerProvider) Connector {
+	return &tracingConnector{
+		Connector:   connector,
+		destination: destination,
+		tracer:      tracer(tracerProvider),
+	}
+}
+
+func (c *tracingConnector) start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return c.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append(attributes, AttributeDestination.String(c.destination))...),
+	)
+}
+
+func (c *tracingConnector) Connect(ctx context.Context) (err error) {
+	ctx, span := c.start(ctx, "icanal.Connect")
+	defer func() { endSpan(span, err) }()
+
+	return c.Connector.Connect(ctx)
+}
+
+func (c *tracingConnector) Subscribe(ctx context.Context, filter string) (err error) {
+	ctx, span := c.start(ctx, "icanal.Subscribe", attribute.String("icanal.filter", filter))
+	defer func() { endSpan(span, err) }()
+
+	return c.Connector.Subscribe(ctx, filter)
+}
+
+func (c *tracingConnector) GetWithoutAck(ctx context.Context, batchSize int32, timeout time.Duration) (message *Message, err error) {
+	ctx, span := c.start(ctx, "icanal.GetWithoutAck")
+	defer func() {
+		span.SetAttributes(messageAttributes(message)...)
+		endSpan(span, err)
+	}()
+
+	return c.Connector.GetWithoutAck(ctx, batchSize, timeout)
+}
+
+func (c *tracingConnector) Ack(ctx context.Context, batchId int64) (err error) {
+	ctx, span := c.start(ctx, "icanal.Ack", AttributeBatchId.Int64(batchId))
+	defer func() { endSpan(span, err) }()
+
+	return c.Connector.Ack(ctx, batchId)
+}
+
+func (c *tracingConnector) Rollback(ctx context.Context, batchId int64) (err error) {
+	ctx, span := c.start(ctx, "icanal.Rollback", AttributeBatchId.Int64(batchId))
+	defer func() { endSpan(span, err) }()
+
+	return c.Connector.Rollback(ctx, batchId)
+}
+
+// commitLatency 批次中最早的binlog提交时间到现在的时长；没有Entry时返回false
+func commitLatency(message *Message) (time.Duration, bool) {
+	entries, err := message.GetEntries()
+	if err != nil || len(entries) == 0 {
+		return 0, false
+	}
+
+	earliest := entries[0].GetHeader().GetExecuteTime()
+	for _, entry := range entries[1:] {
+		earliest = min(earliest, entry.GetHeader().GetExecuteTime())
+	}
+	return time.Since(time.UnixMilli(earliest)), true
+}
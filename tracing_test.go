@@ -0,0 +1,84 @@
+package icanal
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestConsumer_Tracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	connector := NewTracingConnector(&messageConnector{
+		Connector: &recordConnector{},
+		messages:  []*Message{{Id: 1, Entries: testEntries()}},
+	}, "example", tracerProvider)
+
+	var handlerSpan trace.SpanContext
+	consumer := NewConsumer(connector, func(ctx context.Context, message *Message) error {
+		handlerSpan = trace.SpanContextFromContext(ctx)
+		return nil
+	}, WithTracerProvider(tracerProvider))
+
+	if err := consumer.consume(context.Background()); err != nil {
+		t.Fatalf("consume() error = %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+	want := []string{"icanal.GetWithoutAck", "icanal.Ack", "icanal.batch"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("spans = %v, want %v", names, want)
+	}
+
+	batch, ack := spans[2], spans[1]
+	if handlerSpan.SpanID() != batch.SpanContext.SpanID() {
+		t.Errorf("handler span = %s, want batch span %s", handlerSpan.SpanID(), batch.SpanContext.SpanID())
+	}
+	if ack.Parent.SpanID() != batch.SpanContext.SpanID() {
+		t.Errorf("ack parent = %s, want batch span %s", ack.Parent.SpanID(), batch.SpanContext.SpanID())
+	}
+
+	attributes := make(map[string]any)
+	for _, kv := range batch.Attributes {
+		attributes[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	if attributes[string(AttributeBatchId)] != int64(1) || attributes[string(AttributeLogfileName)] != "mysql-bin.000001" {
+		t.Errorf("batch attributes = %v", attributes)
+	}
+	if _, ok := attributes[string(AttributeCommitLatency)]; !ok {
+		t.Errorf("batch attributes = %v, want %s", attributes, AttributeCommitLatency)
+	}
+	if attributes[string(AttributeDestination)] != "example" {
+		t.Errorf("batch attributes = %v, want %s example", attributes, AttributeDestination)
+	}
+}
+
+func TestConnectorDestination(t *testing.T) {
+	simple := NewSimpleConnector("127.0.0.1:11111", "example")
+	tests := []struct {
+		name      string
+		connector Connector
+		want      string
+	}{
+		{"simple", simple, "example"},
+		{"wrapped", NewMetricsConnector(simple, "metrics", nil), "example"},
+		{"tracing", NewTracingConnector(&messageConnector{}, "tracing", nil), "tracing"},
+		{"replay", NewReplayConnector("session.rec"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := connectorDestination(tt.connector); got != tt.want {
+				t.Errorf("connectorDestination() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}